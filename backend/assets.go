@@ -0,0 +1,473 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+// Asset is an entry of the information asset inventory (Control-5.9).
+// Confidentiality, integrity and availability are rated 1 (lowest) to 5;
+// Value is the highest of the three.
+type Asset struct {
+	ID              int    `json:"id"`
+	AssetID         string `json:"asset_id"`
+	Name            string `json:"name"`
+	Type            string `json:"type"`
+	Description     string `json:"description"`
+	Owner           string `json:"owner"`
+	Location        string `json:"location"`
+	Classification  string `json:"classification"`
+	Confidentiality int    `json:"confidentiality"`
+	Integrity       int    `json:"integrity"`
+	Availability    int    `json:"availability"`
+	Value           int    `json:"value"`
+	CreatedAt       string `json:"created_at"`
+	UpdatedAt       string `json:"updated_at"`
+}
+
+// AssetImportReport summarises a CSV import. When any row is invalid nothing
+// is imported and Errors lists every problem.
+type AssetImportReport struct {
+	Created int                `json:"created"`
+	Updated int                `json:"updated"`
+	Errors  []AssetImportError `json:"errors"`
+}
+
+// AssetImportError is a problem on one CSV line.
+type AssetImportError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+var assetTypes = []string{"Information", "Software", "Hardware", "Service", "People", "Site", "Other"}
+
+var assetClassifications = []string{"Public", "Internal", "Confidential", "Restricted"}
+
+// maxAssetRating is the top of the confidentiality/integrity/availability scale.
+const maxAssetRating = 5
+
+const assetColumns = "id, asset_id, name, type, COALESCE(description, ''), COALESCE(owner, ''), COALESCE(location, ''), classification, confidentiality, integrity, availability, created_at, updated_at"
+
+func scanAsset(row rowScanner, a *Asset) error {
+	if err := row.Scan(&a.ID, &a.AssetID, &a.Name, &a.Type, &a.Description, &a.Owner, &a.Location, &a.Classification,
+		&a.Confidentiality, &a.Integrity, &a.Availability, &a.CreatedAt, &a.UpdatedAt); err != nil {
+		return err
+	}
+	a.Value = assetValue(a.Confidentiality, a.Integrity, a.Availability)
+	return nil
+}
+
+func assetValue(c, i, a int) int {
+	v := c
+	if i > v {
+		v = i
+	}
+	if a > v {
+		v = a
+	}
+	return v
+}
+
+// oneOf returns the canonical spelling of s from options, matched
+// case-insensitively.
+func oneOf(options []string, s string) (string, bool) {
+	for _, o := range options {
+		if strings.EqualFold(o, strings.TrimSpace(s)) {
+			return o, true
+		}
+	}
+	return "", false
+}
+
+// validate checks required fields and normalises type and classification.
+func (a *Asset) validate() error {
+	a.AssetID = strings.TrimSpace(a.AssetID)
+	a.Name = strings.TrimSpace(a.Name)
+	if a.AssetID == "" {
+		return errors.New("asset_id is required")
+	}
+	if a.Name == "" {
+		return errors.New("name is required")
+	}
+	t, ok := oneOf(assetTypes, a.Type)
+	if !ok {
+		return fmt.Errorf("type must be one of: %s", strings.Join(assetTypes, ", "))
+	}
+	a.Type = t
+	c, ok := oneOf(assetClassifications, a.Classification)
+	if !ok {
+		return fmt.Errorf("classification must be one of: %s", strings.Join(assetClassifications, ", "))
+	}
+	a.Classification = c
+	for _, r := range []struct {
+		name  string
+		value int
+	}{{"confidentiality", a.Confidentiality}, {"integrity", a.Integrity}, {"availability", a.Availability}} {
+		if r.value < 1 || r.value > maxAssetRating {
+			return fmt.Errorf("%s must be between 1 and %d", r.name, maxAssetRating)
+		}
+	}
+	a.Value = assetValue(a.Confidentiality, a.Integrity, a.Availability)
+	return nil
+}
+
+// isUniqueViolation reports whether err is a Postgres unique constraint violation.
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// Asset Handlers
+func (app *App) getAssets(w http.ResponseWriter, r *http.Request) {
+	rows, err := app.DB.Query("SELECT " + assetColumns + " FROM assets ORDER BY asset_id")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	assets := []Asset{}
+	for rows.Next() {
+		var a Asset
+		if err := scanAsset(rows, &a); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		assets = append(assets, a)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(assets)
+}
+
+func (app *App) getAsset(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	var a Asset
+	err = scanAsset(app.DB.QueryRow("SELECT "+assetColumns+" FROM assets WHERE id = $1", id), &a)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Asset not found", http.StatusNotFound)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(a)
+}
+
+func (app *App) createAsset(w http.ResponseWriter, r *http.Request) {
+	var a Asset
+	if err := json.NewDecoder(r.Body).Decode(&a); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := a.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err := app.DB.QueryRow(
+		"INSERT INTO assets (asset_id, name, type, description, owner, location, classification, confidentiality, integrity, availability) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id, created_at, updated_at",
+		a.AssetID, a.Name, a.Type, a.Description, a.Owner, a.Location, a.Classification, a.Confidentiality, a.Integrity, a.Availability,
+	).Scan(&a.ID, &a.CreatedAt, &a.UpdatedAt)
+	if err != nil {
+		if isUniqueViolation(err) {
+			http.Error(w, fmt.Sprintf("Asset %s already exists", a.AssetID), http.StatusConflict)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(a)
+}
+
+func (app *App) updateAsset(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	var a Asset
+	if err := json.NewDecoder(r.Body).Decode(&a); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := a.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tx, err := app.DB.Begin()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(
+		"UPDATE assets SET asset_id = $1, name = $2, type = $3, description = $4, owner = $5, location = $6, classification = $7, confidentiality = $8, integrity = $9, availability = $10, updated_at = CURRENT_TIMESTAMP WHERE id = $11 RETURNING id, created_at, updated_at",
+		a.AssetID, a.Name, a.Type, a.Description, a.Owner, a.Location, a.Classification, a.Confidentiality, a.Integrity, a.Availability, id,
+	).Scan(&a.ID, &a.CreatedAt, &a.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Asset not found", http.StatusNotFound)
+		} else if isUniqueViolation(err) {
+			http.Error(w, fmt.Sprintf("Asset %s already exists", a.AssetID), http.StatusConflict)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	// A changed CIA rating changes the impact of risks derived from this asset
+	if err := refreshAssetRisks(tx, "SELECT risk_register_id FROM risk_assets WHERE asset_id = $1", id); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(a)
+}
+
+func (app *App) deleteAsset(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := app.DB.Begin()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	riskIDs, err := queryIDs(tx, "SELECT risk_register_id FROM risk_assets WHERE asset_id = $1", id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	result, err := tx.Exec("DELETE FROM assets WHERE id = $1", id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if rowsAffected == 0 {
+		http.Error(w, "Asset not found", http.StatusNotFound)
+		return
+	}
+	if err := deriveRiskImpacts(tx, riskIDs); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// assetCSVColumns are the columns understood by importAssets, listed in the
+// error for a header without asset_id.
+var assetCSVColumns = []string{"asset_id", "name", "type", "description", "owner", "location", "classification", "confidentiality", "integrity", "availability"}
+
+// importAssets loads assets from a CSV file (multipart `file` part) whose
+// header names the columns in assetCSVColumns, in any order. Rows are matched
+// on asset_id, ignoring case as everywhere else: new IDs are created and
+// existing ones updated. The import is
+// all-or-nothing; invalid rows are reported with 400 and nothing is written.
+func (app *App) importAssets(w http.ResponseWriter, r *http.Request) {
+	if err := app.parseUploadForm(w, r); err != nil {
+		writeUploadError(w, err)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "file is required", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	assets, report, err := parseAssetCSV(file)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(report.Errors) > 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(report)
+		return
+	}
+
+	tx, err := app.DB.Begin()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	for _, a := range assets {
+		var inserted bool
+		err := tx.QueryRow(
+			`INSERT INTO assets (asset_id, name, type, description, owner, location, classification, confidentiality, integrity, availability)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+			 ON CONFLICT ((LOWER(asset_id))) DO UPDATE SET name = EXCLUDED.name, type = EXCLUDED.type, description = EXCLUDED.description,
+			     owner = EXCLUDED.owner, location = EXCLUDED.location, classification = EXCLUDED.classification,
+			     confidentiality = EXCLUDED.confidentiality, integrity = EXCLUDED.integrity, availability = EXCLUDED.availability,
+			     updated_at = CURRENT_TIMESTAMP
+			 RETURNING xmax = 0`,
+			a.AssetID, a.Name, a.Type, a.Description, a.Owner, a.Location, a.Classification, a.Confidentiality, a.Integrity, a.Availability,
+		).Scan(&inserted)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if inserted {
+			report.Created++
+		} else {
+			report.Updated++
+		}
+	}
+
+	ids := make([]string, len(assets))
+	for i, a := range assets {
+		ids[i] = strings.ToLower(a.AssetID)
+	}
+	if err := refreshAssetRisks(tx,
+		"SELECT DISTINCT ra.risk_register_id FROM risk_assets ra JOIN assets a ON a.id = ra.asset_id WHERE LOWER(a.asset_id) = ANY($1)", pq.Array(ids)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// parseAssetCSV reads and validates every row. Problems with individual rows
+// are collected in the report; a malformed file is returned as an error.
+func parseAssetCSV(r io.Reader) ([]Asset, AssetImportReport, error) {
+	report := AssetImportReport{Errors: []AssetImportError{}}
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, report, errors.New("CSV file is empty")
+	}
+	if err != nil {
+		return nil, report, fmt.Errorf("invalid CSV: %v", err)
+	}
+	columns := map[string]int{}
+	for i, h := range header {
+		name := strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))
+		columns[strings.ReplaceAll(name, " ", "_")] = i
+	}
+	if _, ok := columns["asset_id"]; !ok {
+		return nil, report, fmt.Errorf("CSV header must include asset_id; expected columns: %s", strings.Join(assetCSVColumns, ", "))
+	}
+
+	var assets []Asset
+	seen := map[string]int{}
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		line, _ := cr.FieldPos(0)
+		if err != nil {
+			return nil, report, fmt.Errorf("invalid CSV: %v", err)
+		}
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		if strings.TrimSpace(strings.Join(record, "")) == "" {
+			continue
+		}
+
+		a := Asset{
+			AssetID:        field("asset_id"),
+			Name:           field("name"),
+			Type:           field("type"),
+			Description:    field("description"),
+			Owner:          field("owner"),
+			Location:       field("location"),
+			Classification: field("classification"),
+		}
+		rowErr := ""
+		for _, r := range []struct {
+			name string
+			dest *int
+		}{{"confidentiality", &a.Confidentiality}, {"integrity", &a.Integrity}, {"availability", &a.Availability}} {
+			n, err := strconv.Atoi(field(r.name))
+			if err != nil {
+				rowErr = fmt.Sprintf("%s must be a number between 1 and %d", r.name, maxAssetRating)
+				break
+			}
+			*r.dest = n
+		}
+		if rowErr == "" {
+			if err := a.validate(); err != nil {
+				rowErr = err.Error()
+			}
+		}
+		if rowErr == "" {
+			if first, dup := seen[strings.ToLower(a.AssetID)]; dup {
+				rowErr = fmt.Sprintf("asset_id %s is repeated (first on line %d)", a.AssetID, first)
+			}
+		}
+		if rowErr != "" {
+			report.Errors = append(report.Errors, AssetImportError{line, rowErr})
+			continue
+		}
+		seen[strings.ToLower(a.AssetID)] = line
+		assets = append(assets, a)
+	}
+	return assets, report, nil
+}
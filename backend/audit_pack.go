@@ -0,0 +1,512 @@
+package main
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// AuditPackManifest describes the contents of an audit pack ZIP.
+type AuditPackManifest struct {
+	GeneratedAt string                `json:"generated_at"`
+	Scope       string                `json:"scope"`
+	Cycle       string                `json:"cycle"`
+	Controls    []AuditPackControl    `json:"controls"`
+	Evidence    int                   `json:"evidence_files"`
+	Problems    []AuditPackFileResult `json:"problems"`
+}
+
+// AuditPackControl is one clause or control and the evidence packed for it.
+type AuditPackControl struct {
+	GapAssessmentID int                   `json:"gap_assessment_id"`
+	StandardRef     string                `json:"standard_ref"`
+	Category        string                `json:"category"`
+	Section         string                `json:"section"`
+	Compliance      string                `json:"compliance"`
+	Document        string                `json:"document"`
+	Evidence        []AuditPackFileResult `json:"evidence"`
+}
+
+// AuditPackFileResult is one evidence file as written into the pack. Status is
+// "ok", "modified" (stored bytes no longer match the recorded digest),
+// "missing" or "no_file" (evidence recorded without an uploaded file).
+type AuditPackFileResult struct {
+	StandardRef    string  `json:"standard_ref,omitempty"`
+	EvidenceID     int     `json:"evidence_id"`
+	Title          string  `json:"title"`
+	FileName       string  `json:"file_name"`
+	Path           string  `json:"path,omitempty"`
+	SHA256         string  `json:"sha256,omitempty"`
+	RecordedSHA256 *string `json:"recorded_sha256,omitempty"`
+	Owner          string  `json:"owner"`
+	UploadedAt     string  `json:"uploaded_at"`
+	ValidUntil     *string `json:"valid_until,omitempty"`
+	Status         string  `json:"status"`
+}
+
+// auditPackScope turns ?scope= into standard_ref prefixes. Empty or "all"
+// selects everything, "clauses" and "controls" select the two halves of the
+// standard, anything else is a comma-separated list such as
+// "Clause-5,Control-8.1".
+func auditPackScope(scope string) []string {
+	switch strings.ToLower(strings.TrimSpace(scope)) {
+	case "", "all":
+		return nil
+	case "clauses":
+		return []string{"Clause"}
+	case "controls":
+		return []string{"Control"}
+	}
+	var prefixes []string
+	for _, p := range strings.Split(scope, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			prefixes = append(prefixes, p)
+		}
+	}
+	return prefixes
+}
+
+// exportAuditPack streams a ZIP with, per clause/control in scope, the
+// generated clause document and every linked evidence file, plus a
+// manifest.json and manifest.csv mapping control -> evidence -> hash -> owner.
+func (app *App) exportAuditPack(w http.ResponseWriter, r *http.Request) {
+	scope := r.URL.Query().Get("scope")
+	prefixes := auditPackScope(scope)
+	if scope == "" {
+		scope = "all"
+	}
+
+	cycle, err := resolveCycle(app.DB, r.URL.Query().Get("cycle"))
+	if err != nil {
+		writeRiskError(w, err)
+		return
+	}
+
+	patterns := make([]string, len(prefixes))
+	for i, p := range prefixes {
+		patterns[i] = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(p) + "%"
+	}
+
+	scale, err := loadComplianceScale(app.DB)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	query := "SELECT id, standard_ref, category, section, compliance, assessment_question, notes, target_date FROM gap_assessments WHERE cycle_id = $1"
+	args := []interface{}{cycle.ID}
+	if len(patterns) > 0 {
+		conds := make([]string, len(patterns))
+		for i, p := range patterns {
+			args = append(args, p)
+			conds[i] = fmt.Sprintf("standard_ref ILIKE $%d", i+2)
+		}
+		query += " AND (" + strings.Join(conds, " OR ") + ")"
+	}
+	query += " ORDER BY standard_ref"
+
+	rows, err := app.DB.Query(query, args...)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var controls []AuditPackControl
+	var clauseData []map[string]interface{}
+	for rows.Next() {
+		var c AuditPackControl
+		var question string
+		var notes, targetDate sql.NullString
+		if err := rows.Scan(&c.GapAssessmentID, &c.StandardRef, &c.Category, &c.Section, &c.Compliance, &question, &notes, &targetDate); err != nil {
+			rows.Close()
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		controls = append(controls, c)
+		clauseData = append(clauseData, auditPackGapData(c, question, notes, targetDate, scale))
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(controls) == 0 {
+		http.Error(w, "No clauses or controls match the requested scope", http.StatusNotFound)
+		return
+	}
+
+	// Everything that can fail with a clean error status is loaded up front;
+	// once the ZIP starts streaming only file problems remain, and those are
+	// recorded in the manifest instead.
+	ids := make([]int, len(controls))
+	byID := make(map[int]map[string]interface{}, len(controls))
+	for i, c := range controls {
+		ids[i] = c.GapAssessmentID
+		byID[c.GapAssessmentID] = clauseData[i]
+	}
+	if err := app.loadAuditPackLinks(ids, byID); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	packed, err := app.auditPackEvidence(ids)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	documents := make([]string, len(controls))
+	for i, c := range controls {
+		documents[i] = generateClauseDocument(c.StandardRef, clauseData[i])
+	}
+
+	manifest := AuditPackManifest{
+		GeneratedAt: time.Now().UTC().Format(time.RFC3339),
+		Scope:       scope,
+		Cycle:       cycle.Name,
+		Problems:    []AuditPackFileResult{},
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"ISO27001-Audit-Pack-%s.zip\"", time.Now().Format("2006-01-02")))
+
+	zw := zip.NewWriter(w)
+	for i, c := range controls {
+		dir := sanitizeFileName(c.StandardRef)
+		c.Document = path.Join(dir, dir+".md")
+		if err := writeZipFile(zw, c.Document, strings.NewReader(documents[i])); err != nil {
+			log.Printf("audit pack: %v", err)
+			return
+		}
+
+		c.Evidence = []AuditPackFileResult{}
+		for _, item := range packed[c.GapAssessmentID] {
+			ev := item.AuditPackFileResult
+			ev.StandardRef = c.StandardRef
+			if item.filePath == "" {
+				ev.Status = "no_file"
+			} else {
+				ev.Path = path.Join(dir, "evidence", fmt.Sprintf("%d-%s", ev.EvidenceID, sanitizeFileName(ev.FileName)))
+				if err := app.writeAuditPackEvidence(zw, item.filePath, &ev); err != nil {
+					log.Printf("audit pack: %v", err)
+					return
+				}
+				if ev.Status == "ok" {
+					manifest.Evidence++
+				}
+			}
+			if ev.Status != "ok" {
+				manifest.Problems = append(manifest.Problems, ev)
+			}
+			c.Evidence = append(c.Evidence, ev)
+		}
+		manifest.Controls = append(manifest.Controls, c)
+	}
+
+	manifestJSON, err := json.MarshalIndent(manifest, "", "  ")
+	if err == nil {
+		err = writeZipFile(zw, "manifest.json", strings.NewReader(string(manifestJSON)))
+	}
+	if err == nil {
+		err = writeAuditPackCSV(zw, manifest)
+	}
+	if err == nil {
+		err = zw.Close()
+	}
+	if err != nil {
+		log.Printf("audit pack: %v", err)
+	}
+}
+
+// auditPackGapData is the part of loadClauseData's result that comes from the
+// gap assessment row itself.
+func auditPackGapData(c AuditPackControl, question string, notes, targetDate sql.NullString, scale *ComplianceScale) map[string]interface{} {
+	data := map[string]interface{}{
+		"gap_assessment_id":   c.GapAssessmentID,
+		"standard_ref":        c.StandardRef,
+		"category":            c.Category,
+		"section":             c.Section,
+		"assessment_question": question,
+		"compliance":          c.Compliance,
+	}
+	if level, ok := scale.level(c.Compliance); ok {
+		data["implementation_status"] = level.SoAStatus
+	}
+	if notes.Valid {
+		data["notes"] = notes.String
+	}
+	if targetDate.Valid {
+		data["target_date"] = targetDate.String
+	}
+	return data
+}
+
+// loadAuditPackLinks adds what loadClauseData finds for a control - the
+// maturity assessment and the linked action items, evidence and risks - to
+// the clause data of every gap assessment in ids, with one query per kind of
+// record rather than per control.
+func (app *App) loadAuditPackLinks(ids []int, byID map[int]map[string]interface{}) error {
+	// The closest maturity assessment of the same cycle, as loadClauseData
+	// picks it
+	rows, err := app.DB.Query(
+		`SELECT DISTINCT ON (g.id) g.id,
+		        m.current_maturity_level, m.current_maturity_score, m.current_maturity_comments,
+		        m.target_maturity_level, m.target_maturity_score, m.target_maturity_comments
+		 FROM gap_assessments g
+		 JOIN maturity_assessments m ON m.cycle_id = g.cycle_id AND m.standard_ref ILIKE '%' || g.standard_ref || '%'
+		 WHERE g.id = ANY($1)
+		 ORDER BY g.id, length(m.standard_ref)`, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var id int
+		var currentLevel, currentComments, targetLevel, targetComments sql.NullString
+		var currentScore, targetScore sql.NullInt64
+		if err := rows.Scan(&id, &currentLevel, &currentScore, &currentComments, &targetLevel, &targetScore, &targetComments); err != nil {
+			rows.Close()
+			return err
+		}
+		data := byID[id]
+		if currentLevel.Valid {
+			data["current_maturity_level"] = currentLevel.String
+		}
+		if currentScore.Valid {
+			data["current_maturity_score"] = int(currentScore.Int64)
+		}
+		if currentComments.Valid {
+			data["current_maturity_comments"] = currentComments.String
+		}
+		if targetLevel.Valid {
+			data["target_maturity_level"] = targetLevel.String
+		}
+		if targetScore.Valid {
+			data["target_maturity_score"] = int(targetScore.Int64)
+		}
+		if targetComments.Valid {
+			data["target_maturity_comments"] = targetComments.String
+		}
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return err
+	}
+
+	rows, err = app.DB.Query(
+		`SELECT g.id, a.id, a.title, a.status, a.priority, a.assigned_to, a.due_date
+		 FROM gap_assessments g
+		 JOIN action_items a ON a.gap_assessment_id = g.id OR a.clause_reference ILIKE '%' || g.standard_ref || '%'
+		 WHERE g.id = ANY($1)
+		 ORDER BY a.due_date NULLS LAST, a.priority DESC, a.created_at DESC`, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	items := map[int][]map[string]interface{}{}
+	for rows.Next() {
+		var gapID, id int
+		var title, status, priority, assignedTo string
+		var due sql.NullString
+		if err := rows.Scan(&gapID, &id, &title, &status, &priority, &assignedTo, &due); err != nil {
+			continue
+		}
+		m := map[string]interface{}{
+			"id":          id,
+			"title":       title,
+			"status":      status,
+			"priority":    priority,
+			"assigned_to": assignedTo,
+		}
+		if due.Valid {
+			m["due_date"] = due.String
+		}
+		items[gapID] = append(items[gapID], m)
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return err
+	}
+	for id, list := range items {
+		byID[id]["action_items"] = list
+	}
+
+	rows, err = app.DB.Query(
+		`SELECT ec.gap_assessment_id, e.id, e.title, e.file_name, e.file_type, e.uploaded_by, e.uploaded_at
+		 FROM evidence e
+		 JOIN evidence_controls ec ON ec.evidence_id = e.id
+		 WHERE ec.gap_assessment_id = ANY($1)
+		 ORDER BY e.uploaded_at DESC`, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	items = map[int][]map[string]interface{}{}
+	for rows.Next() {
+		var gapID, id int
+		var title, fileName, fileType, uploadedBy, uploadedAt string
+		if err := rows.Scan(&gapID, &id, &title, &fileName, &fileType, &uploadedBy, &uploadedAt); err != nil {
+			continue
+		}
+		items[gapID] = append(items[gapID], map[string]interface{}{
+			"id":          id,
+			"title":       title,
+			"file_name":   fileName,
+			"file_type":   fileType,
+			"uploaded_by": uploadedBy,
+			"uploaded_at": uploadedAt,
+		})
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return err
+	}
+	for id, list := range items {
+		byID[id]["evidence"] = list
+	}
+
+	rows, err = app.DB.Query(
+		`SELECT rc.gap_assessment_id, r.risk_id, r.title, r.risk_level, r.treatment_status, r.owner, r.target_date
+		 FROM risk_register r
+		 JOIN risk_controls rc ON rc.risk_register_id = r.id
+		 WHERE rc.gap_assessment_id = ANY($1)
+		 ORDER BY r.risk_score DESC NULLS LAST, r.created_at DESC`, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	items = map[int][]map[string]interface{}{}
+	for rows.Next() {
+		var gapID int
+		var riskID, title, level, status, owner string
+		var target sql.NullString
+		if err := rows.Scan(&gapID, &riskID, &title, &level, &status, &owner, &target); err != nil {
+			continue
+		}
+		m := map[string]interface{}{
+			"risk_id":          riskID,
+			"title":            title,
+			"risk_level":       level,
+			"treatment_status": status,
+			"owner":            owner,
+		}
+		if target.Valid {
+			m["target_date"] = target.String
+		}
+		items[gapID] = append(items[gapID], m)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for id, list := range items {
+		byID[id]["risks"] = list
+	}
+	return nil
+}
+
+// auditPackFile is an evidence item to pack with the storage key of its
+// current file ("" when there is none).
+type auditPackFile struct {
+	AuditPackFileResult
+	filePath string
+}
+
+// auditPackEvidence returns the evidence linked to each of the gap
+// assessments in ids.
+func (app *App) auditPackEvidence(ids []int) (map[int][]auditPackFile, error) {
+	rows, err := app.DB.Query(
+		`SELECT ec.gap_assessment_id, e.id, e.title, e.file_name, e.file_path, e.sha256, e.uploaded_by, e.uploaded_at, to_char(e.valid_until, 'YYYY-MM-DD')
+		 FROM evidence e
+		 JOIN evidence_controls ec ON ec.evidence_id = e.id
+		 WHERE ec.gap_assessment_id = ANY($1)
+		 ORDER BY e.id`, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	files := map[int][]auditPackFile{}
+	for rows.Next() {
+		var gapID int
+		var f auditPackFile
+		var validUntil sql.NullString
+		if err := rows.Scan(&gapID, &f.EvidenceID, &f.Title, &f.FileName, &f.filePath, &f.RecordedSHA256, &f.Owner, &f.UploadedAt, &validUntil); err != nil {
+			return nil, err
+		}
+		if validUntil.Valid {
+			f.ValidUntil = &validUntil.String
+		}
+		files[gapID] = append(files[gapID], f)
+	}
+	return files, rows.Err()
+}
+
+// writeAuditPackEvidence copies a stored file into the ZIP, hashing it on the
+// way and comparing against the recorded digest. A file that cannot be opened
+// is reported through ev.Status; only ZIP write failures are returned.
+func (app *App) writeAuditPackEvidence(zw *zip.Writer, key string, ev *AuditPackFileResult) error {
+	f, _, err := app.Storage.Open(key)
+	if err != nil {
+		ev.Path = ""
+		ev.Status = "missing"
+		return nil
+	}
+	defer f.Close()
+
+	hasher := sha256.New()
+	if err := writeZipFile(zw, ev.Path, io.TeeReader(f, hasher)); err != nil {
+		return err
+	}
+	ev.SHA256 = hex.EncodeToString(hasher.Sum(nil))
+	ev.Status = "ok"
+	if ev.RecordedSHA256 != nil && *ev.RecordedSHA256 != ev.SHA256 {
+		ev.Status = "modified"
+	}
+	return nil
+}
+
+func writeZipFile(zw *zip.Writer, name string, r io.Reader) error {
+	fw, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: time.Now()})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(fw, r)
+	return err
+}
+
+// writeAuditPackCSV writes manifest.csv with one row per control/evidence pair;
+// controls without evidence get a single row so gaps are visible.
+func writeAuditPackCSV(zw *zip.Writer, manifest AuditPackManifest) error {
+	fw, err := zw.CreateHeader(&zip.FileHeader{Name: "manifest.csv", Method: zip.Deflate, Modified: time.Now()})
+	if err != nil {
+		return err
+	}
+	cw := csv.NewWriter(fw)
+	cw.Write([]string{"standard_ref", "compliance", "document", "evidence_id", "evidence_title", "file", "sha256", "owner", "uploaded_at", "valid_until", "status"})
+	for _, c := range manifest.Controls {
+		if len(c.Evidence) == 0 {
+			cw.Write([]string{c.StandardRef, c.Compliance, c.Document, "", "", "", "", "", "", "", "no_evidence"})
+			continue
+		}
+		for _, ev := range c.Evidence {
+			validUntil := ""
+			if ev.ValidUntil != nil {
+				validUntil = *ev.ValidUntil
+			}
+			cw.Write([]string{c.StandardRef, c.Compliance, c.Document, strconv.Itoa(ev.EvidenceID), ev.Title, ev.Path, ev.SHA256, ev.Owner, ev.UploadedAt, validUntil, ev.Status})
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
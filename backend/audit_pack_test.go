@@ -0,0 +1,126 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestExportAuditPackLinksRecordsPerControl(t *testing.T) {
+	db := openTestDB(t)
+	storage := &LocalStorage{Root: t.TempDir()}
+	app := &App{DB: db, Storage: storage}
+	if err := app.createTables(); err != nil {
+		t.Fatal(err)
+	}
+
+	var cycle, mfa, logging, evidence, risk int
+	if err := db.QueryRow("SELECT MIN(id) FROM assessment_cycles").Scan(&cycle); err != nil {
+		t.Fatal(err)
+	}
+	gap := func(ref, compliance string) int {
+		t.Helper()
+		var id int
+		err := db.QueryRow(`INSERT INTO gap_assessments (cycle_id, category, section, standard_ref, assessment_question, compliance, notes)
+			VALUES ($1, 'Annex A', 'A.5 - Organizational controls', $2, 'Is it in place?', $3, '') RETURNING id`, cycle, ref, compliance).Scan(&id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	mfa = gap("Control-5.15", "Partially Compliant")
+	logging = gap("Control-8.15", "Fully Compliant")
+
+	_, err := db.Exec(`
+		INSERT INTO maturity_assessments (cycle_id, category, section, standard_ref, assessment_question, current_maturity_level)
+		VALUES ($1, 'Annex A', 'A.5 - Organizational controls', 'Control-5.15', 'How mature is it?', '2 - Documented');
+		INSERT INTO action_items (title, status, priority, assigned_to, gap_assessment_id)
+		VALUES ('Roll out MFA', 'Open', 'High', 'IT', $2)`, cycle, mfa)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content := []byte("Access control policy")
+	sum := sha256.Sum256(content)
+	if err := storage.Put("evidence/policy.txt", bytes.NewReader(content), int64(len(content)), "text/plain"); err != nil {
+		t.Fatal(err)
+	}
+	err = db.QueryRow(`INSERT INTO evidence (title, file_name, file_path, file_size, file_type, sha256, uploaded_by)
+		VALUES ('Access policy', 'policy.txt', 'evidence/policy.txt', $1, 'text/plain', $2, 'auditor') RETURNING id`,
+		len(content), hex.EncodeToString(sum[:])).Scan(&evidence)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("INSERT INTO evidence_controls (evidence_id, gap_assessment_id) VALUES ($1, $2)", evidence, mfa); err != nil {
+		t.Fatal(err)
+	}
+	err = db.QueryRow(`INSERT INTO risk_register (risk_id, title, category, likelihood, impact, risk_level, treatment_status, owner)
+		VALUES ('RISK-001', 'Unauthorised access', 'Operational', 'High', 'Medium', 'High', 'Open', 'CISO') RETURNING id`).Scan(&risk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := linkRiskControl(db, risk, mfa); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	app.exportAuditPack(rec, httptest.NewRequest(http.MethodGet, "/api/export/audit-pack?scope=controls", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body.String())
+	}
+	zr, err := zip.NewReader(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	read := func(name string) string {
+		t.Helper()
+		f, err := zr.Open(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		data, err := io.ReadAll(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+
+	var manifest AuditPackManifest
+	if err := json.Unmarshal([]byte(read("manifest.json")), &manifest); err != nil {
+		t.Fatal(err)
+	}
+	if len(manifest.Controls) != 2 || manifest.Evidence != 1 || len(manifest.Problems) != 0 {
+		t.Fatalf("manifest has %d controls, %d evidence files and problems %+v; want 2, 1 and none",
+			len(manifest.Controls), manifest.Evidence, manifest.Problems)
+	}
+
+	for _, c := range manifest.Controls {
+		doc := read(c.Document)
+		linked := []string{"Partially Implemented", "2 - Documented", "Roll out MFA", "Access policy", "RISK-001"}
+		for _, want := range linked {
+			if got := strings.Contains(doc, want); got != (c.GapAssessmentID == mfa) {
+				t.Errorf("%s: document mentions %q: %v", c.StandardRef, want, got)
+			}
+		}
+		switch c.GapAssessmentID {
+		case mfa:
+			if len(c.Evidence) != 1 || c.Evidence[0].Status != "ok" || read(c.Evidence[0].Path) != string(content) {
+				t.Errorf("%s: evidence %+v", c.StandardRef, c.Evidence)
+			}
+		case logging:
+			if len(c.Evidence) != 0 || !strings.Contains(doc, "| Implementation Status | Implemented |") {
+				t.Errorf("%s: evidence %+v, document:\n%s", c.StandardRef, c.Evidence, doc)
+			}
+		default:
+			t.Errorf("unexpected control %+v", c)
+		}
+	}
+}
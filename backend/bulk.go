@@ -0,0 +1,309 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// maxBulkOperations caps the size of one bulk request.
+const maxBulkOperations = 1000
+
+// BulkOperation is one step of a bulk request. Create takes the full record
+// in data; update takes a JSON merge patch, like PATCH; delete takes only id.
+type BulkOperation struct {
+	Op   string          `json:"op"`
+	ID   *int            `json:"id,omitempty"`
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+// BulkRequest is the body of POST /api/{resource}/bulk.
+type BulkRequest struct {
+	Operations []BulkOperation `json:"operations"`
+}
+
+// BulkResult reports the outcome of one operation with the status code the
+// single-record endpoint would have answered.
+type BulkResult struct {
+	Index  int         `json:"index"`
+	Op     string      `json:"op"`
+	ID     *int        `json:"id,omitempty"`
+	Status int         `json:"status"`
+	Error  string      `json:"error,omitempty"`
+	Data   interface{} `json:"data,omitempty"`
+}
+
+// BulkResponse lists the result of every operation. Committed is false when
+// any operation failed, in which case nothing was changed.
+type BulkResponse struct {
+	Committed bool         `json:"committed"`
+	Results   []BulkResult `json:"results"`
+}
+
+// bulkResource is the transactional create, update and delete of one
+// resource. Each returns sql.ErrNoRows for a missing record,
+// *cycleClosedError for a record of a closed assessment cycle and
+// *riskInputError for invalid input.
+type bulkResource struct {
+	notFound string
+	create   func(db dbExecutor, data []byte) (int, interface{}, error)
+	update   func(db dbExecutor, id int, patch []byte) (interface{}, error)
+	delete   func(db dbExecutor, id int) error
+}
+
+// runBulk applies every operation of the request in one transaction. Each
+// operation runs in its own savepoint, so after a failure the remaining
+// operations are still checked and every error is reported, but the
+// transaction is only committed when all of them succeed. afterCommit, if
+// set, runs once the changes are committed.
+func (app *App) runBulk(w http.ResponseWriter, r *http.Request, res bulkResource, afterCommit func()) {
+	var req BulkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(req.Operations) == 0 {
+		http.Error(w, "operations must list at least one operation", http.StatusBadRequest)
+		return
+	}
+	if len(req.Operations) > maxBulkOperations {
+		http.Error(w, fmt.Sprintf("A bulk request takes at most %d operations", maxBulkOperations), http.StatusBadRequest)
+		return
+	}
+
+	tx, err := app.DB.Begin()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	resp := BulkResponse{Results: make([]BulkResult, len(req.Operations))}
+	failed := 0
+	for i, op := range req.Operations {
+		result := &resp.Results[i]
+		result.Index, result.Op, result.ID = i, strings.ToLower(strings.TrimSpace(op.Op)), op.ID
+
+		if _, err := tx.Exec("SAVEPOINT bulk_operation"); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		err := applyBulkOperation(tx, res, op, result)
+		if err == nil {
+			_, err = tx.Exec("RELEASE SAVEPOINT bulk_operation")
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			continue
+		}
+
+		failed++
+		result.Data = nil
+		var ie *riskInputError
+		var ce *cycleClosedError
+		switch {
+		case err == sql.ErrNoRows:
+			result.Status, result.Error = http.StatusNotFound, res.notFound
+		case errors.As(err, &ce):
+			result.Status, result.Error = http.StatusConflict, ce.Error()
+		case errors.As(err, &ie):
+			result.Status, result.Error = http.StatusBadRequest, ie.Message
+		default:
+			result.Status, result.Error = http.StatusInternalServerError, err.Error()
+		}
+		if _, err := tx.Exec("ROLLBACK TO SAVEPOINT bulk_operation"); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	status := http.StatusOK
+	if failed == 0 {
+		if err := tx.Commit(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		resp.Committed = true
+		if afterCommit != nil {
+			afterCommit()
+		}
+	} else {
+		// Nothing is kept, so records created along the way do not exist
+		for i := range resp.Results {
+			result := &resp.Results[i]
+			if result.Error == "" {
+				result.Data = nil
+				if result.Op == "create" {
+					result.ID = nil
+				}
+			} else if status == http.StatusOK {
+				status = result.Status
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// applyBulkOperation runs one operation and fills in its result.
+func applyBulkOperation(db dbExecutor, res bulkResource, op BulkOperation, result *BulkResult) error {
+	switch result.Op {
+	case "create":
+		if len(op.Data) == 0 {
+			return &riskInputError{"create needs data"}
+		}
+		id, data, err := res.create(db, op.Data)
+		if err != nil {
+			return err
+		}
+		result.ID, result.Status, result.Data = &id, http.StatusCreated, data
+		return nil
+	case "update", "delete":
+		if op.ID == nil {
+			return &riskInputError{result.Op + " needs an id"}
+		}
+		if result.Op == "delete" {
+			result.Status = http.StatusNoContent
+			return res.delete(db, *op.ID)
+		}
+		if len(op.Data) == 0 {
+			return &riskInputError{"update needs data"}
+		}
+		data, err := res.update(db, *op.ID, op.Data)
+		if err != nil {
+			return err
+		}
+		result.Status, result.Data = http.StatusOK, data
+		return nil
+	default:
+		return &riskInputError{fmt.Sprintf("Unknown op %q; expected create, update or delete", op.Op)}
+	}
+}
+
+// decodeBulkData decodes the record of a create or a merged update.
+func decodeBulkData(data []byte, v interface{}) error {
+	if err := json.Unmarshal(data, v); err != nil {
+		return &riskInputError{err.Error()}
+	}
+	return nil
+}
+
+// deleteBulkRow deletes one row, reporting a missing row as sql.ErrNoRows.
+func deleteBulkRow(db dbExecutor, table string, id int) error {
+	result, err := db.Exec("DELETE FROM "+table+" WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// bulkGapAssessments applies create, update and delete operations to gap
+// assessments in one transaction, e.g. to mark a whole clause Not Applicable.
+// Created rows go into the cycle named by the cycle parameter, unless their
+// data sets cycle_id.
+func (app *App) bulkGapAssessments(w http.ResponseWriter, r *http.Request) {
+	var cycleID *int
+	if err := applyCycleParam(app.DB, r, &cycleID); err != nil {
+		writeRiskError(w, err)
+		return
+	}
+	app.runBulk(w, r, bulkResource{
+		notFound: "Assessment not found",
+		create: func(db dbExecutor, data []byte) (int, interface{}, error) {
+			var a GapAssessment
+			if err := decodeBulkData(data, &a); err != nil {
+				return 0, nil, err
+			}
+			if a.CycleID == nil {
+				a.CycleID = cycleID
+			}
+			err := insertGapAssessment(db, &a)
+			return a.ID, a, err
+		},
+		update: func(db dbExecutor, id int, patch []byte) (interface{}, error) {
+			var current GapAssessment
+			if err := lockRow(db, "gap_assessments", id); err != nil {
+				return nil, err
+			}
+			if err := scanGapAssessment(db.QueryRow("SELECT "+gapAssessmentColumns+" FROM gap_assessments WHERE id = $1", id), &current); err != nil {
+				return nil, err
+			}
+			merged, err := applyMergePatch(current, patch)
+			if err != nil {
+				return nil, err
+			}
+			var a GapAssessment
+			if err := decodeBulkData(merged, &a); err != nil {
+				return nil, err
+			}
+			err = saveGapAssessment(db, id, &a)
+			return a, err
+		},
+		delete: func(db dbExecutor, id int) error {
+			return removeGapAssessment(db, id)
+		},
+	}, nil)
+}
+
+// bulkActionItems applies create, update and delete operations to action
+// items in one transaction, e.g. to reassign every item of someone who left.
+// Files of deleted items are removed once the transaction is committed.
+func (app *App) bulkActionItems(w http.ResponseWriter, r *http.Request) {
+	var removed []string
+	app.runBulk(w, r, bulkResource{
+		notFound: "Action item not found",
+		create: func(db dbExecutor, data []byte) (int, interface{}, error) {
+			var item ActionItem
+			if err := decodeBulkData(data, &item); err != nil {
+				return 0, nil, err
+			}
+			err := insertActionItem(db, &item)
+			return item.ID, item, err
+		},
+		update: func(db dbExecutor, id int, patch []byte) (interface{}, error) {
+			var current ActionItem
+			if err := lockRow(db, "action_items", id); err != nil {
+				return nil, err
+			}
+			if err := scanActionItem(db.QueryRow("SELECT "+actionItemColumns+" FROM action_items WHERE id = $1", id), &current); err != nil {
+				return nil, err
+			}
+			merged, err := applyMergePatch(current, patch)
+			if err != nil {
+				return nil, err
+			}
+			var item ActionItem
+			if err := decodeBulkData(merged, &item); err != nil {
+				return nil, err
+			}
+			err = saveActionItem(db, id, &item)
+			return item, err
+		},
+		delete: func(db dbExecutor, id int) error {
+			var filePath sql.NullString
+			if err := db.QueryRow("DELETE FROM action_items WHERE id = $1 RETURNING file_path", id).Scan(&filePath); err != nil {
+				return err
+			}
+			if filePath.Valid && filePath.String != "" {
+				removed = append(removed, filePath.String)
+			}
+			return nil
+		},
+	}, func() {
+		// Best-effort cleanup, as for a single delete
+		app.removeOwnedFiles("deleted action items", "action-items", removed)
+	})
+}
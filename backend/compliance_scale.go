@@ -0,0 +1,263 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+)
+
+// ComplianceLevel is one rating a gap assessment row can have. Weight is how
+// far a control at this level reduces the likelihood of the risks it
+// mitigates (0 to 1). Levels that are not Applicable are excluded in the SoA
+// and left out of residual risk; SoAStatus is the implementation status the
+// SoA reports for applicable controls.
+type ComplianceLevel struct {
+	Label      string  `json:"label"`
+	Weight     float64 `json:"weight"`
+	Applicable bool    `json:"applicable"`
+	SoAStatus  string  `json:"soa_status"`
+}
+
+// ComplianceScale lists the levels in display order.
+type ComplianceScale struct {
+	Levels    []ComplianceLevel `json:"levels"`
+	UpdatedAt *string           `json:"updated_at,omitempty"`
+}
+
+// defaultComplianceScale is the scale the gap assessment UI has always used.
+func defaultComplianceScale() *ComplianceScale {
+	return &ComplianceScale{Levels: []ComplianceLevel{
+		{"Fully Compliant", 1.0, true, "Implemented"},
+		{"Partially Compliant", 0.5, true, "Partially Implemented"},
+		{"Not Compliant", 0.0, true, "Planned"},
+		{"Not Applicable", 0.0, false, "Not Applicable"},
+	}}
+}
+
+// validate checks labels are present and unique and weights lie in [0, 1].
+func (s *ComplianceScale) validate() error {
+	if len(s.Levels) == 0 {
+		return &riskInputError{"The compliance scale needs at least one level"}
+	}
+	seen := map[string]bool{}
+	for i := range s.Levels {
+		l := &s.Levels[i]
+		l.Label, l.SoAStatus = strings.TrimSpace(l.Label), strings.TrimSpace(l.SoAStatus)
+		if l.Label == "" {
+			return &riskInputError{"Every compliance level needs a label"}
+		}
+		if len(l.Label) > 50 {
+			return &riskInputError{fmt.Sprintf("Compliance label %q is longer than 50 characters", l.Label)}
+		}
+		if seen[strings.ToLower(l.Label)] {
+			return &riskInputError{fmt.Sprintf("Duplicate compliance label %q", l.Label)}
+		}
+		seen[strings.ToLower(l.Label)] = true
+		if l.Weight < 0 || l.Weight > 1 || math.IsNaN(l.Weight) {
+			return &riskInputError{fmt.Sprintf("Weight of %q must be between 0 and 1", l.Label)}
+		}
+		if l.SoAStatus == "" {
+			return &riskInputError{fmt.Sprintf("Compliance level %q needs a soa_status", l.Label)}
+		}
+	}
+	return nil
+}
+
+// level finds a label on the scale, case-insensitively.
+func (s *ComplianceScale) level(label string) (ComplianceLevel, bool) {
+	for _, l := range s.Levels {
+		if strings.EqualFold(l.Label, strings.TrimSpace(label)) {
+			return l, true
+		}
+	}
+	return ComplianceLevel{}, false
+}
+
+// soaStatus is the SoA implementation status of a label, or a note naming a
+// label the scale no longer has.
+func (s *ComplianceScale) soaStatus(label string) string {
+	if l, ok := s.level(label); ok {
+		return l.SoAStatus
+	}
+	return fmt.Sprintf("Unknown compliance status %q", label)
+}
+
+func (s *ComplianceScale) labels() string {
+	names := make([]string, len(s.Levels))
+	for i, l := range s.Levels {
+		names[i] = l.Label
+	}
+	return strings.Join(names, ", ")
+}
+
+// notApplicableLabels names the levels the SoA excludes.
+func (s *ComplianceScale) notApplicableLabels() []string {
+	var names []string
+	for _, l := range s.Levels {
+		if !l.Applicable {
+			names = append(names, l.Label)
+		}
+	}
+	return names
+}
+
+// loadComplianceScale returns the stored scale, or the default one if none
+// has been saved yet.
+func loadComplianceScale(db dbExecutor) (*ComplianceScale, error) {
+	rows, err := db.Query("SELECT label, weight, applicable, soa_status, updated_at FROM compliance_scale ORDER BY position, id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	s := &ComplianceScale{}
+	for rows.Next() {
+		var l ComplianceLevel
+		var updatedAt string
+		if err := rows.Scan(&l.Label, &l.Weight, &l.Applicable, &l.SoAStatus, &updatedAt); err != nil {
+			return nil, err
+		}
+		s.Levels = append(s.Levels, l)
+		s.UpdatedAt = &updatedAt
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(s.Levels) == 0 {
+		return defaultComplianceScale(), nil
+	}
+	return s, nil
+}
+
+// normalizeCompliance checks a gap assessment's compliance is on the scale
+// and normalises it to the scale's spelling.
+func normalizeCompliance(db dbExecutor, a *GapAssessment) error {
+	s, err := loadComplianceScale(db)
+	if err != nil {
+		return err
+	}
+	l, ok := s.level(a.Compliance)
+	if !ok {
+		return &riskInputError{fmt.Sprintf("Unknown compliance %q; expected one of: %s", a.Compliance, s.labels())}
+	}
+	a.Compliance = l.Label
+	return nil
+}
+
+// checkComplianceLabels reports gap assessments of open cycles rated with
+// labels missing from s as a *riskInputError. Closed cycles keep their
+// labels as recorded, so they don't hold on to a label.
+func checkComplianceLabels(db dbExecutor, s *ComplianceScale) error {
+	rows, err := db.Query(`SELECT DISTINCT compliance FROM gap_assessments
+		WHERE cycle_id IN (SELECT id FROM assessment_cycles WHERE status = 'open') ORDER BY compliance`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var unknown []string
+	for rows.Next() {
+		var c string
+		if err := rows.Scan(&c); err != nil {
+			return err
+		}
+		if _, ok := s.level(c); !ok {
+			unknown = append(unknown, fmt.Sprintf("%q", c))
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(unknown) > 0 {
+		return &riskInputError{fmt.Sprintf("Gap assessments use compliance %s, which the new scale drops", strings.Join(unknown, ", "))}
+	}
+	return nil
+}
+
+// Compliance Scale Handlers
+func (app *App) getComplianceScale(w http.ResponseWriter, r *http.Request) {
+	s, err := loadComplianceScale(app.DB)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s)
+}
+
+// updateComplianceScale replaces the scale and recalculates every residual
+// risk with the new weights. A scale that drops labels still used by gap
+// assessments of open cycles is refused with 409.
+func (app *App) updateComplianceScale(w http.ResponseWriter, r *http.Request) {
+	var s ComplianceScale
+	if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := s.validate(); err != nil {
+		writeRiskError(w, err)
+		return
+	}
+
+	tx, err := app.DB.Begin()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	if err := checkComplianceLabels(tx, &s); err != nil {
+		var ie *riskInputError
+		if errors.As(err, &ie) {
+			http.Error(w, ie.Message, http.StatusConflict)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	if _, err := tx.Exec("DELETE FROM compliance_scale"); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var updatedAt string
+	for i, l := range s.Levels {
+		err := tx.QueryRow(
+			"INSERT INTO compliance_scale (label, weight, applicable, soa_status, position) VALUES ($1, $2, $3, $4, $5) RETURNING updated_at",
+			l.Label, l.Weight, l.Applicable, l.SoAStatus, i,
+		).Scan(&updatedAt)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	// Existing ratings keep their meaning but take the scale's spelling,
+	// except in closed cycles, which stay as they were
+	for _, l := range s.Levels {
+		if _, err := tx.Exec("UPDATE gap_assessments SET compliance = $1 WHERE LOWER(compliance) = LOWER($2) AND compliance <> $1 AND cycle_id IN (SELECT id FROM assessment_cycles WHERE status = 'open')", l.Label, l.Label); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	m, err := loadRiskMatrix(tx)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := refreshResidualRisks(tx, m, nil); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.UpdatedAt = &updatedAt
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s)
+}
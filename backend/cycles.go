@@ -0,0 +1,422 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// currentCycleSQL selects the cycle used when a request names none: the
+// newest open cycle, or the newest cycle when every cycle is closed.
+const currentCycleSQL = "(SELECT id FROM assessment_cycles ORDER BY status = 'open' DESC, id DESC LIMIT 1)"
+
+// AssessmentCycle is one run of the gap and maturity assessment, e.g. a
+// yearly reassessment. Closed cycles are kept as read-only history.
+type AssessmentCycle struct {
+	ID                  int     `json:"id"`
+	Name                string  `json:"name"`
+	Description         string  `json:"description"`
+	Status              string  `json:"status"`
+	CopiedFromID        *int    `json:"copied_from_id,omitempty"`
+	ClosedAt            *string `json:"closed_at,omitempty"`
+	GapAssessments      int     `json:"gap_assessments"`
+	MaturityAssessments int     `json:"maturity_assessments"`
+	Current             bool    `json:"current"`
+	CreatedAt           string  `json:"created_at"`
+	UpdatedAt           string  `json:"updated_at"`
+}
+
+// AssessmentCycleRequest is the body of POST /api/assessment-cycles.
+// CopyFrom names the cycle (by id or name) whose answers are copied forward
+// and defaults to the current cycle; Blank starts the cycle empty instead.
+type AssessmentCycleRequest struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	CopyFrom    interface{} `json:"copy_from,omitempty"`
+	Blank       bool        `json:"blank"`
+}
+
+// assessmentCycleColumns is the column list scanned by scanAssessmentCycle,
+// selected from assessment_cycles c.
+const assessmentCycleColumns = `c.id, c.name, COALESCE(c.description, ''), c.status, c.copied_from_id, c.closed_at,
+	(SELECT COUNT(*) FROM gap_assessments g WHERE g.cycle_id = c.id),
+	(SELECT COUNT(*) FROM maturity_assessments m WHERE m.cycle_id = c.id),
+	c.id = ` + currentCycleSQL + `, c.created_at, c.updated_at`
+
+func scanAssessmentCycle(row rowScanner, c *AssessmentCycle) error {
+	return row.Scan(&c.ID, &c.Name, &c.Description, &c.Status, &c.CopiedFromID, &c.ClosedAt, &c.GapAssessments, &c.MaturityAssessments, &c.Current, &c.CreatedAt, &c.UpdatedAt)
+}
+
+// cycleClosedError reports a change to an assessment in a closed cycle.
+type cycleClosedError struct {
+	name string
+}
+
+func (e *cycleClosedError) Error() string {
+	return fmt.Sprintf("Assessment cycle %q is closed and read-only", e.name)
+}
+
+// resolveCycle finds a cycle by id or name (case-insensitively); an empty
+// ref is the current cycle. An unknown cycle is reported as *riskInputError.
+func resolveCycle(db dbExecutor, ref string) (*AssessmentCycle, error) {
+	ref = strings.TrimSpace(ref)
+	var c AssessmentCycle
+	var err error
+	if ref == "" {
+		err = scanAssessmentCycle(db.QueryRow("SELECT "+assessmentCycleColumns+" FROM assessment_cycles c WHERE c.id = "+currentCycleSQL), &c)
+	} else {
+		// A name wins over an id, so a cycle named "2026" stays reachable
+		err = scanAssessmentCycle(db.QueryRow(
+			"SELECT "+assessmentCycleColumns+" FROM assessment_cycles c WHERE LOWER(c.name) = LOWER($1) OR c.id::text = $2 ORDER BY LOWER(c.name) = LOWER($1) DESC LIMIT 1",
+			ref, ref,
+		), &c)
+	}
+	if err == sql.ErrNoRows {
+		if ref == "" {
+			return nil, &riskInputError{"No assessment cycle exists"}
+		}
+		return nil, &riskInputError{fmt.Sprintf("Unknown assessment cycle %q", ref)}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// applyCycleParam points id at the cycle named by the cycle query parameter
+// of r, if there is one.
+func applyCycleParam(db dbExecutor, r *http.Request, id **int) error {
+	ref := r.URL.Query().Get("cycle")
+	if ref == "" {
+		return nil
+	}
+	c, err := resolveCycle(db, ref)
+	if err != nil {
+		return err
+	}
+	*id = &c.ID
+	return nil
+}
+
+// openCycleID returns the cycle a new assessment row goes into: cycle id, or
+// the current cycle when id is nil. The cycle must be open.
+func openCycleID(db dbExecutor, id *int) (int, error) {
+	var cycleID int
+	var name, status string
+	err := db.QueryRow("SELECT id, name, status FROM assessment_cycles WHERE id = COALESCE($1, "+currentCycleSQL+")", id).
+		Scan(&cycleID, &name, &status)
+	if err == sql.ErrNoRows {
+		if id == nil {
+			return 0, &riskInputError{"No assessment cycle exists"}
+		}
+		return 0, &riskInputError{fmt.Sprintf("Unknown assessment cycle %d", *id)}
+	}
+	if err != nil {
+		return 0, err
+	}
+	if status != "open" {
+		return 0, &cycleClosedError{name}
+	}
+	return cycleID, nil
+}
+
+// checkCycleOpen reports sql.ErrNoRows if row id of table (gap_assessments or
+// maturity_assessments) does not exist and *cycleClosedError if it belongs to
+// a closed cycle.
+func checkCycleOpen(db dbExecutor, table string, id int) error {
+	var name, status string
+	err := db.QueryRow("SELECT c.name, c.status FROM "+table+" t JOIN assessment_cycles c ON c.id = t.cycle_id WHERE t.id = $1", id).
+		Scan(&name, &status)
+	if err != nil {
+		return err
+	}
+	if status != "open" {
+		return &cycleClosedError{name}
+	}
+	return nil
+}
+
+// writeCycleError answers a failed assessment write: sql.ErrNoRows with 404
+// and notFound, a closed cycle with 409, and anything else like
+// writeRiskError.
+func writeCycleError(w http.ResponseWriter, err error, notFound string) {
+	var ce *cycleClosedError
+	switch {
+	case err == sql.ErrNoRows:
+		http.Error(w, notFound, http.StatusNotFound)
+	case errors.As(err, &ce):
+		http.Error(w, ce.Error(), http.StatusConflict)
+	default:
+		writeRiskError(w, err)
+	}
+}
+
+// scopeListToCycle turns the cycle parameter of a gap or maturity assessment
+// list request into a cycle_id filter. Without either parameter the list
+// shows the current cycle; cycle=all lists every cycle.
+func scopeListToCycle(db dbExecutor, r *http.Request) error {
+	q := r.URL.Query()
+	ref := q.Get("cycle")
+	q.Del("cycle")
+	if strings.EqualFold(ref, "all") {
+		r.URL.RawQuery = q.Encode()
+		return nil
+	}
+	if ref == "" {
+		for name := range q {
+			if strings.HasPrefix(name, "cycle_id") {
+				r.URL.RawQuery = q.Encode()
+				return nil
+			}
+		}
+	}
+	c, err := resolveCycle(db, ref)
+	if err != nil {
+		return err
+	}
+	q.Set("cycle_id", strconv.Itoa(c.ID))
+	r.URL.RawQuery = q.Encode()
+	return nil
+}
+
+// gapLineageSQL names every gap assessment row with the root of its
+// previous_id chain (lineage), and the row of cycle $1 in each chain (copies).
+const gapLineageSQL = `WITH RECURSIVE lineage (id, root) AS (
+		SELECT id, id FROM gap_assessments WHERE previous_id IS NULL
+		UNION ALL
+		SELECT g.id, l.root FROM gap_assessments g JOIN lineage l ON g.previous_id = l.id
+	), copies (root, id) AS (
+		SELECT DISTINCT ON (l.root) l.root, g.id FROM gap_assessments g JOIN lineage l ON l.id = g.id
+		WHERE g.cycle_id = $1 ORDER BY l.root, g.id
+	) `
+
+// copyCycleForward copies every gap and maturity assessment of cycle from
+// into cycle to. The copies link to their originals through previous_id and
+// take over the evidence links of the originals, so the new cycle starts
+// where the old one left off while the old rows stay as they were. Risks
+// follow the newest cycle: a risk mitigated by any row of a control's
+// previous_id chain (the original, an older row or a copy in another cycle,
+// as when copying from a cycle that is not the current one) is linked to the
+// control's copy as well, and a risk whose primary control is in the chain
+// takes the copy as its primary control. The old links are kept, so earlier
+// cycles still show the risks they mitigated.
+func copyCycleForward(db dbExecutor, from, to int) error {
+	_, err := db.Exec(
+		`INSERT INTO gap_assessments (cycle_id, previous_id, category, section, standard_ref, assessment_question, compliance, notes, target_date, action_item_id, evidence_refresh_days)
+		 SELECT $1, id, category, section, standard_ref, assessment_question, compliance, notes, target_date, action_item_id, evidence_refresh_days
+		 FROM gap_assessments WHERE cycle_id = $2 ORDER BY id`,
+		to, from,
+	)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(
+		`INSERT INTO maturity_assessments (cycle_id, previous_id, category, section, standard_ref, assessment_question, current_maturity_level, current_maturity_score, current_maturity_comments, target_maturity_level, target_maturity_score, target_maturity_comments)
+		 SELECT $1, id, category, section, standard_ref, assessment_question, current_maturity_level, current_maturity_score, current_maturity_comments, target_maturity_level, target_maturity_score, target_maturity_comments
+		 FROM maturity_assessments WHERE cycle_id = $2 ORDER BY id`,
+		to, from,
+	)
+	if err != nil {
+		return err
+	}
+
+	// The copies are the only rows of cycle to, so previous_id maps each
+	// original to its copy
+	links := []string{
+		`INSERT INTO evidence_controls (evidence_id, gap_assessment_id)
+		 SELECT ec.evidence_id, g.id FROM evidence_controls ec
+		 JOIN gap_assessments g ON g.previous_id = ec.gap_assessment_id
+		 WHERE g.cycle_id = $1
+		 ON CONFLICT DO NOTHING`,
+		gapLineageSQL + `INSERT INTO risk_controls (risk_register_id, gap_assessment_id)
+		 SELECT rc.risk_register_id, c.id FROM risk_controls rc
+		 JOIN lineage l ON l.id = rc.gap_assessment_id
+		 JOIN copies c ON c.root = l.root
+		 WHERE rc.gap_assessment_id <> c.id
+		 ON CONFLICT DO NOTHING`,
+		gapLineageSQL + `UPDATE risk_register r SET gap_assessment_id = c.id
+		 FROM lineage l JOIN copies c ON c.root = l.root
+		 WHERE l.id = r.gap_assessment_id AND r.gap_assessment_id <> c.id`,
+	}
+	for _, stmt := range links {
+		if _, err := db.Exec(stmt, to); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Assessment Cycle Handlers
+func (app *App) getAssessmentCycles(w http.ResponseWriter, r *http.Request) {
+	rows, err := app.DB.Query("SELECT " + assessmentCycleColumns + " FROM assessment_cycles c ORDER BY c.id")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	cycles := []AssessmentCycle{}
+	for rows.Next() {
+		var c AssessmentCycle
+		if err := scanAssessmentCycle(rows, &c); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		cycles = append(cycles, c)
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cycles)
+}
+
+func (app *App) getAssessmentCycle(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	var c AssessmentCycle
+	err = scanAssessmentCycle(app.DB.QueryRow("SELECT "+assessmentCycleColumns+" FROM assessment_cycles c WHERE c.id = $1", id), &c)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Assessment cycle not found", http.StatusNotFound)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(c)
+}
+
+// createAssessmentCycle starts a new cycle, which becomes the current one.
+// Unless the request asks for a blank cycle, the answers of copy_from (the
+// current cycle by default) are copied forward.
+func (app *App) createAssessmentCycle(w http.ResponseWriter, r *http.Request) {
+	var req AssessmentCycleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		http.Error(w, "name is required", http.StatusBadRequest)
+		return
+	}
+	if len(req.Name) > 255 {
+		http.Error(w, "name is longer than 255 characters", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := app.DB.Begin()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	var source *AssessmentCycle
+	if !req.Blank {
+		ref := ""
+		if req.CopyFrom != nil {
+			ref = fmt.Sprint(req.CopyFrom)
+		}
+		if source, err = resolveCycle(tx, ref); err != nil {
+			writeRiskError(w, err)
+			return
+		}
+	}
+
+	var id int
+	var copiedFrom *int
+	if source != nil {
+		copiedFrom = &source.ID
+	}
+	err = tx.QueryRow(
+		"INSERT INTO assessment_cycles (name, description, copied_from_id) VALUES ($1, $2, $3) RETURNING id",
+		req.Name, req.Description, copiedFrom,
+	).Scan(&id)
+	if err != nil {
+		if isUniqueViolation(err) {
+			http.Error(w, fmt.Sprintf("An assessment cycle named %q already exists", req.Name), http.StatusConflict)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	if source != nil {
+		if err := copyCycleForward(tx, source.ID, id); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	var c AssessmentCycle
+	if err := scanAssessmentCycle(tx.QueryRow("SELECT "+assessmentCycleColumns+" FROM assessment_cycles c WHERE c.id = $1", id), &c); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(c)
+}
+
+// closeAssessmentCycle makes a cycle read-only. Closing a closed cycle
+// changes nothing; there is no way to reopen one.
+func (app *App) closeAssessmentCycle(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := app.DB.Begin()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	// Closing a closed cycle again changes nothing
+	err = tx.QueryRow(`UPDATE assessment_cycles SET status = 'closed', closed_at = COALESCE(closed_at, CURRENT_TIMESTAMP),
+		updated_at = CASE WHEN status = 'closed' THEN updated_at ELSE CURRENT_TIMESTAMP END
+		WHERE id = $1 RETURNING id`, id).Scan(&id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Assessment cycle not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var c AssessmentCycle
+	if err := scanAssessmentCycle(tx.QueryRow("SELECT "+assessmentCycleColumns+" FROM assessment_cycles c WHERE c.id = $1", id), &c); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(c)
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestCopyCycleForwardKeepsRiskLinksOfEarlierCycles(t *testing.T) {
+	db := openTestDB(t)
+	app := &App{DB: db}
+	if err := app.createTables(); err != nil {
+		t.Fatal(err)
+	}
+
+	var initial, original, other, risk int
+	if err := db.QueryRow("SELECT MIN(id) FROM assessment_cycles").Scan(&initial); err != nil {
+		t.Fatal(err)
+	}
+	gap := func(ref string) int {
+		t.Helper()
+		var id int
+		err := db.QueryRow(`INSERT INTO gap_assessments (cycle_id, category, section, standard_ref, assessment_question, compliance, notes)
+			VALUES ($1, 'Annex A', 'A.5 - Organizational controls', $2, 'Is it in place?', 'Partially Compliant', '') RETURNING id`, initial, ref).
+			Scan(&id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	original = gap("Control-5.15")
+	other = gap("Control-5.16")
+	err := db.QueryRow(`INSERT INTO risk_register (risk_id, title, category, likelihood, impact, risk_level, treatment_status, owner, gap_assessment_id)
+		VALUES ('RISK-001', 'Unauthorised access', 'Operational', 'High', 'Medium', 'High', 'Open', 'CISO', $1) RETURNING id`, original).Scan(&risk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := linkRiskControl(db, risk, original); err != nil {
+		t.Fatal(err)
+	}
+
+	// linkedCycles lists the cycles of the controls the risk is linked to
+	linkedCycles := func() []int {
+		t.Helper()
+		rows, err := db.Query(`SELECT g.cycle_id FROM risk_controls rc JOIN gap_assessments g ON g.id = rc.gap_assessment_id
+			WHERE rc.risk_register_id = $1 ORDER BY g.cycle_id`, risk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rows.Close()
+		var cycles []int
+		for rows.Next() {
+			var c int
+			if err := rows.Scan(&c); err != nil {
+				t.Fatal(err)
+			}
+			cycles = append(cycles, c)
+		}
+		return cycles
+	}
+	primaryCycle := func() int {
+		t.Helper()
+		var c int
+		err := db.QueryRow("SELECT g.cycle_id FROM risk_register r JOIN gap_assessments g ON g.id = r.gap_assessment_id WHERE r.id = $1", risk).Scan(&c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+	newCycle := func(name string) int {
+		t.Helper()
+		var id int
+		if err := db.QueryRow("INSERT INTO assessment_cycles (name) VALUES ($1) RETURNING id", name).Scan(&id); err != nil {
+			t.Fatal(err)
+		}
+		if err := copyCycleForward(db, initial, id); err != nil {
+			t.Fatalf("copy into %s: %v", name, err)
+		}
+		return id
+	}
+
+	second := newCycle("second")
+	if got, want := fmt.Sprint(linkedCycles()), fmt.Sprint([]int{initial, second}); got != want {
+		t.Errorf("after copying into the second cycle the risk is linked in cycles %s, want %s", got, want)
+	}
+	if got := primaryCycle(); got != second {
+		t.Errorf("primary control in cycle %d, want %d", got, second)
+	}
+
+	// Copying the initial cycle again, while the risk's primary control is
+	// the second cycle's copy, still brings the risk along
+	third := newCycle("third")
+	if got, want := fmt.Sprint(linkedCycles()), fmt.Sprint([]int{initial, second, third}); got != want {
+		t.Errorf("after copying into the third cycle the risk is linked in cycles %s, want %s", got, want)
+	}
+	if got := primaryCycle(); got != third {
+		t.Errorf("primary control in cycle %d, want %d", got, third)
+	}
+
+	if _, err := db.Exec("UPDATE assessment_cycles SET status = 'closed' WHERE id = $1", initial); err != nil {
+		t.Fatal(err)
+	}
+	var copy int
+	if err := db.QueryRow("SELECT id FROM gap_assessments WHERE cycle_id = $1 AND previous_id = $2", third, original).Scan(&copy); err != nil {
+		t.Fatal(err)
+	}
+	// Replacing the links keeps those of the closed cycle
+	if err := setRiskControls(db, risk, []int{copy}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := fmt.Sprint(linkedCycles()), fmt.Sprint([]int{initial, third}); got != want {
+		t.Errorf("after replacing the links the risk is linked in cycles %s, want %s", got, want)
+	}
+	var ce *cycleClosedError
+	if err := setRiskControls(db, risk, []int{copy, other}); !errors.As(err, &ce) {
+		t.Errorf("linking a control of a closed cycle: got %v, want *cycleClosedError", err)
+	}
+}
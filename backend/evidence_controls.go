@@ -0,0 +1,244 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// EvidenceControl is a gap assessment row (clause or Annex A control) that an
+// evidence item supports.
+type EvidenceControl struct {
+	EvidenceID         int    `json:"evidence_id"`
+	GapAssessmentID    int    `json:"gap_assessment_id"`
+	StandardRef        string `json:"standard_ref"`
+	Category           string `json:"category"`
+	Section            string `json:"section"`
+	AssessmentQuestion string `json:"assessment_question"`
+	Compliance         string `json:"compliance"`
+	LinkedAt           string `json:"linked_at"`
+}
+
+// EvidenceControlRequest identifies the control to link, either by gap
+// assessment ID or by its standard reference (e.g. "Control-5.15").
+type EvidenceControlRequest struct {
+	GapAssessmentID *int   `json:"gap_assessment_id,omitempty"`
+	StandardRef     string `json:"standard_ref,omitempty"`
+}
+
+// execer is satisfied by both *sql.DB and *sql.Tx.
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// linkEvidenceControl records that an evidence item supports a control.
+// Linking twice is harmless; a new link to a control of a closed cycle is
+// reported as *cycleClosedError.
+func linkEvidenceControl(db dbExecutor, evidenceID, gapAssessmentID int) error {
+	var linked bool
+	err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM evidence_controls WHERE evidence_id = $1 AND gap_assessment_id = $2)", evidenceID, gapAssessmentID).Scan(&linked)
+	if err != nil || linked {
+		return err
+	}
+	if err := checkCycleOpen(db, "gap_assessments", gapAssessmentID); err != nil {
+		return err
+	}
+	_, err = db.Exec(
+		"INSERT INTO evidence_controls (evidence_id, gap_assessment_id) VALUES ($1, $2) ON CONFLICT DO NOTHING",
+		evidenceID, gapAssessmentID,
+	)
+	return err
+}
+
+// Evidence Control Handlers
+func (app *App) getEvidenceControls(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	var exists bool
+	if err := app.DB.QueryRow("SELECT EXISTS (SELECT 1 FROM evidence WHERE id = $1)", id).Scan(&exists); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !exists {
+		http.Error(w, "Evidence not found", http.StatusNotFound)
+		return
+	}
+
+	rows, err := app.DB.Query(
+		`SELECT ec.evidence_id, g.id, g.standard_ref, g.category, g.section, g.assessment_question, g.compliance, ec.created_at
+		 FROM evidence_controls ec
+		 JOIN gap_assessments g ON g.id = ec.gap_assessment_id
+		 WHERE ec.evidence_id = $1
+		 ORDER BY g.standard_ref`, id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	controls := []EvidenceControl{}
+	for rows.Next() {
+		var c EvidenceControl
+		if err := rows.Scan(&c.EvidenceID, &c.GapAssessmentID, &c.StandardRef, &c.Category, &c.Section, &c.AssessmentQuestion, &c.Compliance, &c.LinkedAt); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		controls = append(controls, c)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(controls)
+}
+
+func (app *App) attachEvidenceControl(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	var req EvidenceControlRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.GapAssessmentID == nil && strings.TrimSpace(req.StandardRef) == "" {
+		http.Error(w, "gap_assessment_id or standard_ref is required", http.StatusBadRequest)
+		return
+	}
+
+	var exists bool
+	if err := app.DB.QueryRow("SELECT EXISTS (SELECT 1 FROM evidence WHERE id = $1)", id).Scan(&exists); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !exists {
+		http.Error(w, "Evidence not found", http.StatusNotFound)
+		return
+	}
+
+	var c EvidenceControl
+	if req.GapAssessmentID != nil {
+		err = app.DB.QueryRow("SELECT id, standard_ref, category, section, assessment_question, compliance FROM gap_assessments WHERE id = $1", *req.GapAssessmentID).
+			Scan(&c.GapAssessmentID, &c.StandardRef, &c.Category, &c.Section, &c.AssessmentQuestion, &c.Compliance)
+	} else {
+		err = app.DB.QueryRow("SELECT id, standard_ref, category, section, assessment_question, compliance FROM gap_assessments WHERE standard_ref ILIKE $1 ORDER BY cycle_id = "+currentCycleSQL+" DESC, id LIMIT 1", strings.TrimSpace(req.StandardRef)).
+			Scan(&c.GapAssessmentID, &c.StandardRef, &c.Category, &c.Section, &c.AssessmentQuestion, &c.Compliance)
+	}
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Control not found", http.StatusNotFound)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if err := linkEvidenceControl(app.DB, id, c.GapAssessmentID); err != nil {
+		writeCycleError(w, err, "Control not found")
+		return
+	}
+	c.EvidenceID = id
+	if err := app.DB.QueryRow("SELECT created_at FROM evidence_controls WHERE evidence_id = $1 AND gap_assessment_id = $2", id, c.GapAssessmentID).Scan(&c.LinkedAt); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(c)
+}
+
+func (app *App) detachEvidenceControl(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+	gapID, err := strconv.Atoi(vars["gapId"])
+	if err != nil {
+		http.Error(w, "Invalid control ID", http.StatusBadRequest)
+		return
+	}
+
+	// Links of a closed cycle's controls are as read-only as the controls
+	if err := checkCycleOpen(app.DB, "gap_assessments", gapID); err != nil {
+		writeCycleError(w, err, "Link not found")
+		return
+	}
+
+	result, err := app.DB.Exec("DELETE FROM evidence_controls WHERE evidence_id = $1 AND gap_assessment_id = $2", id, gapID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if rowsAffected == 0 {
+		http.Error(w, "Link not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *App) getControlEvidence(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	var exists bool
+	if err := app.DB.QueryRow("SELECT EXISTS (SELECT 1 FROM gap_assessments WHERE id = $1)", id).Scan(&exists); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !exists {
+		http.Error(w, "Assessment not found", http.StatusNotFound)
+		return
+	}
+
+	rows, err := app.DB.Query(
+		`SELECT e.id, e.title, e.description, e.file_name, e.file_path, e.file_size, e.file_type, e.sha256, e.current_version_id, e.gap_assessment_id, e.maturity_assessment_id, e.clause_reference, e.annex_reference, e.uploaded_by, e.uploaded_at, e.collected_at, e.valid_until, e.created_at, e.updated_at
+		 FROM evidence e
+		 JOIN evidence_controls ec ON ec.evidence_id = e.id
+		 WHERE ec.gap_assessment_id = $1
+		 ORDER BY e.uploaded_at DESC`, id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	items := []Evidence{}
+	for rows.Next() {
+		var item Evidence
+		err := rows.Scan(&item.ID, &item.Title, &item.Description, &item.FileName, &item.FilePath, &item.FileSize, &item.FileType, &item.SHA256, &item.CurrentVersionID, &item.GapAssessmentID, &item.MaturityAssessmentID, &item.ClauseReference, &item.AnnexReference, &item.UploadedBy, &item.UploadedAt, &item.CollectedAt, &item.ValidUntil, &item.CreatedAt, &item.UpdatedAt)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		items = append(items, item)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(items)
+}
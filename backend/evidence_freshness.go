@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/lib/pq"
+)
+
+// StaleEvidence is an evidence item that has expired or is about to.
+type StaleEvidence struct {
+	EvidenceID     int      `json:"evidence_id"`
+	Title          string   `json:"title"`
+	FileName       string   `json:"file_name"`
+	Controls       []string `json:"controls"`
+	CollectedAt    string   `json:"collected_at"`
+	ValidUntil     string   `json:"valid_until"`
+	ValiditySource string   `json:"validity_source"`
+	DaysRemaining  int      `json:"days_remaining"`
+	Status         string   `json:"status"`
+}
+
+// getStaleEvidence lists evidence that has expired or expires within ?days=N
+// (default 30). An explicit valid_until wins; otherwise validity is derived
+// from the collection date plus the shortest evidence_refresh_days among the
+// controls of the current assessment cycle the evidence is linked to.
+// Collection falls back to the upload date when collected_at is not set.
+func (app *App) getStaleEvidence(w http.ResponseWriter, r *http.Request) {
+	days := 30
+	if v := r.URL.Query().Get("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "Invalid days", http.StatusBadRequest)
+			return
+		}
+		days = n
+	}
+
+	rows, err := app.DB.Query(`
+		SELECT id, title, file_name, controls,
+		       to_char(collected, 'YYYY-MM-DD'), to_char(expires, 'YYYY-MM-DD'), source,
+		       expires - CURRENT_DATE
+		FROM (
+			SELECT e.id, e.title, e.file_name, COALESCE(c.refs, '{}') AS controls,
+			       COALESCE(e.collected_at, e.uploaded_at::date) AS collected,
+			       COALESCE(e.valid_until, COALESCE(e.collected_at, e.uploaded_at::date) + c.refresh_days) AS expires,
+			       CASE WHEN e.valid_until IS NOT NULL THEN 'valid_until' ELSE 'refresh_interval' END AS source
+			FROM evidence e
+			LEFT JOIN LATERAL (
+				SELECT array_agg(g.standard_ref ORDER BY g.standard_ref) AS refs, MIN(g.evidence_refresh_days) AS refresh_days
+				FROM evidence_controls ec
+				JOIN gap_assessments g ON g.id = ec.gap_assessment_id
+				WHERE ec.evidence_id = e.id AND g.cycle_id = `+currentCycleSQL+`
+			) c ON true
+		) ev
+		WHERE expires IS NOT NULL AND expires <= CURRENT_DATE + $1::integer
+		ORDER BY expires, id`, days)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	items := []StaleEvidence{}
+	for rows.Next() {
+		var item StaleEvidence
+		err := rows.Scan(&item.EvidenceID, &item.Title, &item.FileName, pq.Array(&item.Controls), &item.CollectedAt, &item.ValidUntil, &item.ValiditySource, &item.DaysRemaining)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		item.Status = "expiring"
+		if item.DaysRemaining < 0 {
+			item.Status = "expired"
+		}
+		items = append(items, item)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(items)
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// EvidenceSearchResult is an evidence item matching a full-text query.
+type EvidenceSearchResult struct {
+	EvidenceID int                   `json:"evidence_id"`
+	Title      string                `json:"title"`
+	FileName   string                `json:"file_name"`
+	Snippet    string                `json:"snippet"`
+	Rank       float64               `json:"rank"`
+	Controls   []EvidenceSearchMatch `json:"controls"`
+}
+
+// EvidenceSearchMatch is a control linked to a matching evidence item.
+type EvidenceSearchMatch struct {
+	GapAssessmentID int    `json:"gap_assessment_id"`
+	StandardRef     string `json:"standard_ref"`
+}
+
+// EvidenceReindexReport summarises a text re-extraction run.
+type EvidenceReindexReport struct {
+	Checked int              `json:"checked"`
+	Indexed int              `json:"indexed"`
+	Errors  []IntegrityIssue `json:"errors"`
+}
+
+// searchEvidence runs a web-style query (?q=password length, quoted phrases,
+// -exclusions) over evidence titles, descriptions and extracted file text.
+// Snippets mark matched words with ** on either side.
+func (app *App) searchEvidence(w http.ResponseWriter, r *http.Request) {
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if q == "" {
+		http.Error(w, "q is required", http.StatusBadRequest)
+		return
+	}
+	limit := 20
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > 100 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	rows, err := app.DB.Query(`
+		SELECT e.id, e.title, e.file_name,
+		       ts_headline('english', COALESCE(NULLIF(e.content_text, ''), NULLIF(e.description, ''), e.title), query,
+		                   'StartSel=**, StopSel=**, MaxWords=35, MinWords=15, MaxFragments=2, FragmentDelimiter=" ... "'),
+		       ts_rank(e.search_vector, query),
+		       COALESCE(c.controls, '[]')
+		FROM evidence e
+		CROSS JOIN websearch_to_tsquery('english', $1) query
+		LEFT JOIN LATERAL (
+			SELECT json_agg(json_build_object('gap_assessment_id', g.id, 'standard_ref', g.standard_ref) ORDER BY g.standard_ref) AS controls
+			FROM evidence_controls ec
+			JOIN gap_assessments g ON g.id = ec.gap_assessment_id
+			WHERE ec.evidence_id = e.id
+		) c ON true
+		WHERE e.search_vector @@ query
+		ORDER BY 5 DESC, e.id
+		LIMIT $2`, q, limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	results := []EvidenceSearchResult{}
+	for rows.Next() {
+		var res EvidenceSearchResult
+		var controls []byte
+		if err := rows.Scan(&res.EvidenceID, &res.Title, &res.FileName, &res.Snippet, &res.Rank, &controls); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := json.Unmarshal(controls, &res.Controls); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		results = append(results, res)
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
+
+// reindexEvidenceText re-extracts the text of every stored evidence revision,
+// e.g. for files uploaded before search existed or after extraction improves.
+func (app *App) reindexEvidenceText(w http.ResponseWriter, r *http.Request) {
+	report := EvidenceReindexReport{Errors: []IntegrityIssue{}}
+
+	sources := []struct {
+		query  string
+		update string
+	}{
+		{"SELECT v.id, v.evidence_id, v.version_number, e.title, v.file_name, v.file_path FROM evidence_versions v JOIN evidence e ON e.id = v.evidence_id ORDER BY v.id",
+			"UPDATE evidence_versions SET content_text = $1 WHERE id = $2"},
+		{"SELECT id, id, 0, title, file_name, file_path FROM evidence WHERE current_version_id IS NULL AND file_path <> '' ORDER BY id",
+			"UPDATE evidence SET content_text = $1 WHERE id = $2"},
+	}
+
+	for _, src := range sources {
+		rows, err := app.DB.Query(src.query)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		type target struct {
+			rowID    int
+			issue    IntegrityIssue
+			fileName string
+		}
+		var targets []target
+		for rows.Next() {
+			var t target
+			if err := rows.Scan(&t.rowID, &t.issue.ID, &t.issue.Version, &t.issue.Title, &t.fileName, &t.issue.FilePath); err != nil {
+				rows.Close()
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			t.issue.Resource = "evidence"
+			targets = append(targets, t)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		for _, t := range targets {
+			report.Checked++
+			text, err := app.extractStoredText(t.issue.FilePath, t.fileName)
+			if err != nil {
+				t.issue.Error = err.Error()
+				report.Errors = append(report.Errors, t.issue)
+				continue
+			}
+			if _, err := app.DB.Exec(src.update, text, t.rowID); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			report.Indexed++
+		}
+	}
+
+	// Refresh the current revision's text on each evidence row
+	_, err := app.DB.Exec(`UPDATE evidence e SET content_text = v.content_text
+		FROM evidence_versions v WHERE v.id = e.current_version_id`)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// extractStoredText reads a stored file and extracts its text.
+func (app *App) extractStoredText(key, name string) (string, error) {
+	f, _, err := app.Storage.Open(key)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, app.Uploads.MaxSize+1))
+	if err != nil {
+		return "", err
+	}
+	return extractText(name, bytes.NewReader(data), int64(len(data)))
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// EvidenceVersion is an immutable revision of an evidence file. The evidence
+// row mirrors the file fields of whichever revision is current.
+type EvidenceVersion struct {
+	ID            int     `json:"id"`
+	EvidenceID    int     `json:"evidence_id"`
+	VersionNumber int     `json:"version_number"`
+	FileName      string  `json:"file_name"`
+	FilePath      string  `json:"file_path"`
+	FileSize      *int    `json:"file_size,omitempty"`
+	FileType      string  `json:"file_type"`
+	SHA256        *string `json:"sha256,omitempty"`
+	UploadedBy    string  `json:"uploaded_by"`
+	ChangeNote    string  `json:"change_note"`
+	UploadedAt    string  `json:"uploaded_at"`
+	IsCurrent     bool    `json:"is_current"`
+}
+
+// addEvidenceVersion records a stored file as the next revision of an evidence
+// item and makes it current. The evidence row is locked for the duration of
+// the transaction so concurrent uploads get distinct version numbers.
+func addEvidenceVersion(tx *sql.Tx, evidenceID int, stored *storedFile, uploadedBy, changeNote string) (*EvidenceVersion, error) {
+	var locked int
+	if err := tx.QueryRow("SELECT id FROM evidence WHERE id = $1 FOR UPDATE", evidenceID).Scan(&locked); err != nil {
+		return nil, err
+	}
+
+	size := int(stored.Size)
+	v := EvidenceVersion{
+		EvidenceID: evidenceID,
+		FileName:   stored.Name,
+		FilePath:   stored.Path,
+		FileSize:   &size,
+		FileType:   stored.Type,
+		SHA256:     &stored.SHA256,
+		UploadedBy: uploadedBy,
+		ChangeNote: changeNote,
+		IsCurrent:  true,
+	}
+
+	err := tx.QueryRow(
+		`INSERT INTO evidence_versions (evidence_id, version_number, file_name, file_path, file_size, file_type, sha256, uploaded_by, change_note, content_text)
+		 VALUES ($1, (SELECT COALESCE(MAX(version_number), 0) + 1 FROM evidence_versions WHERE evidence_id = $1), $2, $3, $4, $5, $6, $7, $8, $9)
+		 RETURNING id, version_number, uploaded_at`,
+		evidenceID, v.FileName, v.FilePath, v.FileSize, v.FileType, v.SHA256, v.UploadedBy, v.ChangeNote, stored.Text,
+	).Scan(&v.ID, &v.VersionNumber, &v.UploadedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := setCurrentEvidenceVersion(tx, evidenceID, v.ID); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// setCurrentEvidenceVersion points the evidence row at a revision and copies
+// that revision's file fields and extracted text onto it.
+func setCurrentEvidenceVersion(tx *sql.Tx, evidenceID, versionID int) error {
+	result, err := tx.Exec(
+		`UPDATE evidence e
+		 SET current_version_id = v.id, file_name = v.file_name, file_path = v.file_path,
+		     file_size = v.file_size, file_type = v.file_type, sha256 = v.sha256, content_text = v.content_text
+		 FROM evidence_versions v
+		 WHERE e.id = $1 AND v.id = $2 AND v.evidence_id = e.id`,
+		evidenceID, versionID,
+	)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// evidenceVersionVars parses the {id} and {version} route variables.
+func evidenceVersionVars(r *http.Request) (int, int, error) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		return 0, 0, errors.New("Invalid ID")
+	}
+	version, err := strconv.Atoi(vars["version"])
+	if err != nil {
+		return 0, 0, errors.New("Invalid version")
+	}
+	return id, version, nil
+}
+
+// Evidence Version Handlers
+func (app *App) getEvidenceVersions(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	var currentID sql.NullInt64
+	err = app.DB.QueryRow("SELECT current_version_id FROM evidence WHERE id = $1", id).Scan(&currentID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Evidence not found", http.StatusNotFound)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	rows, err := app.DB.Query("SELECT id, evidence_id, version_number, file_name, file_path, file_size, file_type, sha256, uploaded_by, COALESCE(change_note, ''), uploaded_at FROM evidence_versions WHERE evidence_id = $1 ORDER BY version_number DESC", id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	versions := []EvidenceVersion{}
+	for rows.Next() {
+		var v EvidenceVersion
+		err := rows.Scan(&v.ID, &v.EvidenceID, &v.VersionNumber, &v.FileName, &v.FilePath, &v.FileSize, &v.FileType, &v.SHA256, &v.UploadedBy, &v.ChangeNote, &v.UploadedAt)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		v.IsCurrent = currentID.Valid && int(currentID.Int64) == v.ID
+		versions = append(versions, v)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(versions)
+}
+
+func (app *App) uploadEvidenceVersion(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := app.parseUploadForm(w, r); err != nil {
+		writeUploadError(w, err)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	uploadedBy := strings.TrimSpace(r.FormValue("uploaded_by"))
+	if uploadedBy == "" {
+		http.Error(w, "uploaded_by is required", http.StatusBadRequest)
+		return
+	}
+
+	var exists bool
+	if err := app.DB.QueryRow("SELECT EXISTS (SELECT 1 FROM evidence WHERE id = $1)", id).Scan(&exists); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !exists {
+		http.Error(w, "Evidence not found", http.StatusNotFound)
+		return
+	}
+
+	stored, err := app.saveUploadedFile(r, "file", "evidence")
+	if err != nil {
+		writeUploadError(w, err)
+		return
+	}
+
+	tx, err := app.DB.Begin()
+	if err != nil {
+		app.removeStoredFile(stored.Path)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	version, err := addEvidenceVersion(tx, id, stored, uploadedBy, r.FormValue("change_note"))
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		app.removeStoredFile(stored.Path)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Evidence not found", http.StatusNotFound)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(version)
+}
+
+func (app *App) downloadEvidenceVersionFile(w http.ResponseWriter, r *http.Request) {
+	id, version, err := evidenceVersionVars(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var path, name, fileType string
+	err = app.DB.QueryRow("SELECT file_path, file_name, file_type FROM evidence_versions WHERE evidence_id = $1 AND version_number = $2", id, version).Scan(&path, &name, &fileType)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Evidence version not found", http.StatusNotFound)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	app.serveStoredFile(w, r, path, name, fileType)
+}
+
+func (app *App) setCurrentEvidenceVersionHandler(w http.ResponseWriter, r *http.Request) {
+	id, version, err := evidenceVersionVars(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tx, err := app.DB.Begin()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	var versionID int
+	err = tx.QueryRow("SELECT id FROM evidence_versions WHERE evidence_id = $1 AND version_number = $2", id, version).Scan(&versionID)
+	if err == nil {
+		err = setCurrentEvidenceVersion(tx, id, versionID)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Evidence version not found", http.StatusNotFound)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	app.getEvidenceItem(w, r)
+}
+
+// removeEvidenceFiles deletes every stored revision of an evidence item. It is
+// called after the evidence row (and, by cascade, its versions) is gone.
+func (app *App) removeEvidenceFiles(evidenceID int, paths []string) {
+	app.removeOwnedFiles(fmt.Sprintf("evidence %d", evidenceID), "evidence", paths)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+// IntegrityIssue describes a stored file that failed verification.
+type IntegrityIssue struct {
+	Resource       string `json:"resource"`
+	ID             int    `json:"id"`
+	Version        int    `json:"version,omitempty"`
+	Title          string `json:"title"`
+	FilePath       string `json:"file_path"`
+	ExpectedSHA256 string `json:"expected_sha256"`
+	ActualSHA256   string `json:"actual_sha256,omitempty"`
+	Error          string `json:"error,omitempty"`
+}
+
+// IntegrityReport is the result of re-hashing every stored file.
+type IntegrityReport struct {
+	VerifiedAt string           `json:"verified_at"`
+	Checked    int              `json:"checked"`
+	Passed     int              `json:"passed"`
+	Missing    []IntegrityIssue `json:"missing"`
+	Modified   []IntegrityIssue `json:"modified"`
+	Errors     []IntegrityIssue `json:"errors"`
+}
+
+// hashStoredFile returns the hex SHA-256 of a stored file.
+func (app *App) hashStoredFile(key string) (string, error) {
+	f, _, err := app.Storage.Open(key)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// verifyEvidence re-hashes every stored evidence revision and action item file
+// that has a recorded digest and reports files that are missing or no longer match.
+func (app *App) verifyEvidence(w http.ResponseWriter, r *http.Request) {
+	report := IntegrityReport{
+		VerifiedAt: time.Now().UTC().Format(time.RFC3339),
+		Missing:    []IntegrityIssue{},
+		Modified:   []IntegrityIssue{},
+		Errors:     []IntegrityIssue{},
+	}
+
+	sources := []struct {
+		resource string
+		query    string
+	}{
+		{"evidence", "SELECT v.evidence_id, v.version_number, e.title, v.file_path, v.sha256 FROM evidence_versions v JOIN evidence e ON e.id = v.evidence_id WHERE v.sha256 IS NOT NULL ORDER BY v.evidence_id, v.version_number"},
+		{"evidence", "SELECT id, 0, title, file_path, sha256 FROM evidence WHERE sha256 IS NOT NULL AND current_version_id IS NULL ORDER BY id"},
+		{"action_item", "SELECT id, 0, title, file_path, sha256 FROM action_items WHERE sha256 IS NOT NULL ORDER BY id"},
+	}
+
+	for _, src := range sources {
+		rows, err := app.DB.Query(src.query)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		var issues []IntegrityIssue
+		for rows.Next() {
+			var issue IntegrityIssue
+			if err := rows.Scan(&issue.ID, &issue.Version, &issue.Title, &issue.FilePath, &issue.ExpectedSHA256); err != nil {
+				rows.Close()
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			issue.Resource = src.resource
+			issues = append(issues, issue)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		for _, issue := range issues {
+			report.Checked++
+			actual, err := app.hashStoredFile(issue.FilePath)
+			switch {
+			case errors.Is(err, os.ErrNotExist):
+				report.Missing = append(report.Missing, issue)
+			case err != nil:
+				issue.Error = err.Error()
+				report.Errors = append(report.Errors, issue)
+			case actual != issue.ExpectedSHA256:
+				issue.ActualSHA256 = actual
+				report.Modified = append(report.Modified, issue)
+			default:
+				report.Passed++
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,384 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxListLimit caps the page size of list endpoints.
+const maxListLimit = 1000
+
+type fieldKind int
+
+const (
+	textField    fieldKind = iota // case-insensitive; a trailing * matches a prefix
+	sectionField                  // like textField, but "A.8" also matches "A.8 - Technological controls"
+	intField
+	dateField // compared by calendar day, so timestamps work too
+	boolField
+)
+
+// listField is a column clients may filter and sort a list on.
+type listField struct {
+	column   string
+	kind     fieldKind
+	nullable bool // "null" as a value matches missing values
+}
+
+// listSpec is the allowlist of a list endpoint. Query parameters naming
+// anything else are rejected, so only these column expressions ever reach
+// the SQL text; values are always passed as arguments.
+type listSpec struct {
+	table        string
+	fields       map[string]listField
+	defaultOrder string
+}
+
+// listQuery is a validated filter, sort and page request.
+type listQuery struct {
+	conditions []string
+	args       []interface{}
+	orderBy    string
+	limit      int // 0 means no limit
+	offset     int
+}
+
+// listParam splits "risk_score[gte]" into field and operator.
+var listParam = regexp.MustCompile(`^([a-z_]+)(?:\[(gt|gte|lt|lte|ne)\])?$`)
+
+var listOperators = map[string]string{"gt": ">", "gte": ">=", "lt": "<", "lte": "<=", "ne": "<>"}
+
+// parseListQuery validates filter parameters (field=value, repeated for any
+// of several values; field[gt|gte|lt|lte|ne]=value), sort (comma-separated
+// fields, "-" for descending) and limit/offset against spec.
+func parseListQuery(values url.Values, spec listSpec) (*listQuery, error) {
+	q := &listQuery{}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		vals := values[name]
+		switch name {
+		case "sort":
+			var terms []string
+			for _, term := range strings.Split(strings.Join(vals, ","), ",") {
+				term = strings.TrimSpace(term)
+				dir := "ASC"
+				if strings.HasPrefix(term, "-") {
+					term, dir = term[1:], "DESC"
+				}
+				f, ok := spec.fields[term]
+				if !ok {
+					return nil, fmt.Errorf("cannot sort by %q; allowed: %s", term, spec.allowed())
+				}
+				terms = append(terms, fmt.Sprintf("%s %s NULLS LAST", f.column, dir))
+			}
+			q.orderBy = strings.Join(terms, ", ")
+			continue
+		case "limit", "offset":
+			n, err := strconv.Atoi(vals[len(vals)-1])
+			if err != nil || n < 0 || (name == "limit" && (n < 1 || n > maxListLimit)) {
+				if name == "limit" {
+					return nil, fmt.Errorf("limit must be between 1 and %d", maxListLimit)
+				}
+				return nil, fmt.Errorf("offset must be a non-negative number")
+			}
+			if name == "limit" {
+				q.limit = n
+			} else {
+				q.offset = n
+			}
+			continue
+		}
+
+		m := listParam.FindStringSubmatch(name)
+		if m == nil {
+			return nil, fmt.Errorf("unknown parameter %q", name)
+		}
+		f, ok := spec.fields[m[1]]
+		if !ok {
+			return nil, fmt.Errorf("cannot filter by %q; allowed: %s", m[1], spec.allowed())
+		}
+		if op := m[2]; op != "" {
+			for _, v := range vals {
+				if err := q.compare(f, m[1], listOperators[op], v); err != nil {
+					return nil, err
+				}
+			}
+			continue
+		}
+		if err := q.match(f, m[1], vals); err != nil {
+			return nil, err
+		}
+	}
+
+	if q.orderBy == "" {
+		q.orderBy = spec.defaultOrder
+	}
+	if q.orderBy != "id" && !strings.HasPrefix(q.orderBy, "id ") {
+		// Break ties on id so pages never overlap
+		q.orderBy += ", id"
+	}
+	return q, nil
+}
+
+func (s listSpec) allowed() string {
+	names := make([]string, 0, len(s.fields))
+	for name := range s.fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// value converts a filter value to the field's type.
+func (f listField) value(name, v string) (interface{}, error) {
+	switch f.kind {
+	case intField:
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be a whole number", name)
+		}
+		return n, nil
+	case dateField:
+		d, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be a date (YYYY-MM-DD)", name)
+		}
+		return d.Format("2006-01-02"), nil
+	case boolField:
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be true or false", name)
+		}
+		return b, nil
+	}
+	return v, nil
+}
+
+func (f listField) expr() string {
+	switch f.kind {
+	case textField, sectionField:
+		return "LOWER(" + f.column + ")"
+	case dateField:
+		return "(" + f.column + ")::date"
+	}
+	return f.column
+}
+
+func (q *listQuery) arg(v interface{}) string {
+	q.args = append(q.args, v)
+	return fmt.Sprintf("$%d", len(q.args))
+}
+
+// compare adds a range or inequality condition.
+func (q *listQuery) compare(f listField, name, op, v string) error {
+	if (f.kind == textField || f.kind == sectionField || f.kind == boolField) && op != "<>" {
+		return fmt.Errorf("%s does not support range filters", name)
+	}
+	val, err := f.value(name, v)
+	if err != nil {
+		return err
+	}
+	if f.kind == textField || f.kind == sectionField {
+		val = strings.ToLower(v)
+	}
+	cond := fmt.Sprintf("%s %s %s", f.expr(), op, q.arg(val))
+	if op == "<>" && f.nullable {
+		cond = "(" + cond + " OR " + f.column + " IS NULL)"
+	}
+	q.conditions = append(q.conditions, cond)
+	return nil
+}
+
+// match adds a condition matching any of vals.
+func (q *listQuery) match(f listField, name string, vals []string) error {
+	var alternatives []string
+	for _, v := range vals {
+		v = strings.TrimSpace(v)
+		if f.nullable && strings.EqualFold(v, "null") {
+			alternatives = append(alternatives, f.column+" IS NULL")
+			continue
+		}
+		switch f.kind {
+		case textField, sectionField:
+			lower := strings.ToLower(v)
+			if strings.HasSuffix(lower, "*") {
+				alternatives = append(alternatives, fmt.Sprintf("%s LIKE %s", f.expr(), q.arg(likePrefix(strings.TrimSuffix(lower, "*")))))
+			} else if f.kind == sectionField {
+				alternatives = append(alternatives, fmt.Sprintf("(%s = %s OR %s LIKE %s)", f.expr(), q.arg(lower), f.expr(), q.arg(likePrefix(lower+" "))))
+			} else {
+				alternatives = append(alternatives, fmt.Sprintf("%s = %s", f.expr(), q.arg(lower)))
+			}
+		default:
+			val, err := f.value(name, v)
+			if err != nil {
+				return err
+			}
+			alternatives = append(alternatives, fmt.Sprintf("%s = %s", f.expr(), q.arg(val)))
+		}
+	}
+	q.conditions = append(q.conditions, "("+strings.Join(alternatives, " OR ")+")")
+	return nil
+}
+
+// likePrefix escapes LIKE wildcards in s and matches anything starting with it.
+func likePrefix(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s) + "%"
+}
+
+func (q *listQuery) where() string {
+	if len(q.conditions) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(q.conditions, " AND ")
+}
+
+// queryList runs selectSQL (a SELECT ... FROM spec.table without WHERE or
+// ORDER BY) with the filters, sort and page requested in r, and sets
+// X-Total-Count to the number of matching rows. On failure it answers the
+// request and returns nil.
+func (app *App) queryList(w http.ResponseWriter, r *http.Request, spec listSpec, selectSQL string) *sql.Rows {
+	q, err := parseListQuery(r.URL.Query(), spec)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil
+	}
+
+	var total int
+	if err := app.DB.QueryRow("SELECT COUNT(*) FROM "+spec.table+q.where(), q.args...).Scan(&total); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil
+	}
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+
+	query := selectSQL + q.where() + " ORDER BY " + q.orderBy
+	args := q.args
+	if q.limit > 0 {
+		args = append(args, q.limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+	if q.offset > 0 {
+		args = append(args, q.offset)
+		query += fmt.Sprintf(" OFFSET $%d", len(args))
+	}
+	rows, err := app.DB.Query(query, args...)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil
+	}
+	return rows
+}
+
+var gapAssessmentList = listSpec{
+	table: "gap_assessments",
+	fields: map[string]listField{
+		"id":                    {"id", intField, false},
+		"category":              {"category", textField, false},
+		"section":               {"section", sectionField, false},
+		"standard_ref":          {"standard_ref", textField, false},
+		"compliance":            {"compliance", textField, false},
+		"target_date":           {"target_date", dateField, true},
+		"action_item_id":        {"action_item_id", intField, true},
+		"evidence_refresh_days": {"evidence_refresh_days", intField, true},
+		"cycle_id":              {"cycle_id", intField, false},
+		"previous_id":           {"previous_id", intField, true},
+		"created_at":            {"created_at", dateField, false},
+		"updated_at":            {"updated_at", dateField, false},
+	},
+	defaultOrder: "id",
+}
+
+var maturityAssessmentList = listSpec{
+	table: "maturity_assessments",
+	fields: map[string]listField{
+		"id":                     {"id", intField, false},
+		"category":               {"category", textField, false},
+		"section":                {"section", sectionField, false},
+		"standard_ref":           {"standard_ref", textField, false},
+		"current_maturity_level": {"current_maturity_level", textField, false},
+		"current_maturity_score": {"current_maturity_score", intField, true},
+		"target_maturity_level":  {"target_maturity_level", textField, false},
+		"target_maturity_score":  {"target_maturity_score", intField, true},
+		"cycle_id":               {"cycle_id", intField, false},
+		"previous_id":            {"previous_id", intField, true},
+		"created_at":             {"created_at", dateField, false},
+		"updated_at":             {"updated_at", dateField, false},
+	},
+	defaultOrder: "id",
+}
+
+var actionItemList = listSpec{
+	table: "action_items",
+	fields: map[string]listField{
+		"id":                     {"id", intField, false},
+		"title":                  {"title", textField, false},
+		"status":                 {"status", textField, false},
+		"priority":               {"priority", textField, false},
+		"assigned_to":            {"assigned_to", textField, true},
+		"category":               {"category", textField, true},
+		"due_date":               {"due_date", dateField, true},
+		"completed_date":         {"completed_date", dateField, true},
+		"gap_assessment_id":      {"gap_assessment_id", intField, true},
+		"maturity_assessment_id": {"maturity_assessment_id", intField, true},
+		"created_at":             {"created_at", dateField, false},
+		"updated_at":             {"updated_at", dateField, false},
+	},
+	defaultOrder: "due_date NULLS LAST, priority DESC, created_at DESC",
+}
+
+var evidenceList = listSpec{
+	table: "evidence",
+	fields: map[string]listField{
+		"id":                     {"id", intField, false},
+		"title":                  {"title", textField, false},
+		"file_type":              {"file_type", textField, true},
+		"uploaded_by":            {"uploaded_by", textField, true},
+		"clause_reference":       {"clause_reference", textField, true},
+		"annex_reference":        {"annex_reference", textField, true},
+		"gap_assessment_id":      {"gap_assessment_id", intField, true},
+		"maturity_assessment_id": {"maturity_assessment_id", intField, true},
+		"uploaded_at":            {"uploaded_at", dateField, false},
+		"collected_at":           {"collected_at", dateField, true},
+		"valid_until":            {"valid_until", dateField, true},
+		"created_at":             {"created_at", dateField, false},
+		"updated_at":             {"updated_at", dateField, false},
+	},
+	defaultOrder: "created_at DESC",
+}
+
+var riskList = listSpec{
+	table: "risk_register",
+	fields: map[string]listField{
+		"id":                 {"id", intField, false},
+		"risk_id":            {"risk_id", textField, false},
+		"title":              {"title", textField, false},
+		"category":           {"category", textField, false},
+		"likelihood":         {"likelihood", textField, false},
+		"impact":             {"impact", textField, false},
+		"impact_from_assets": {"impact_from_assets", boolField, false},
+		"risk_level":         {"risk_level", textField, false},
+		"risk_score":         {"risk_score", intField, true},
+		"residual_level":     {"residual_level", textField, true},
+		"residual_score":     {"residual_score", intField, true},
+		"treatment_option":   {"treatment_option", textField, true},
+		"treatment_status":   {"treatment_status", textField, false},
+		"owner":              {"owner", textField, false},
+		"target_date":        {"target_date", dateField, true},
+		"gap_assessment_id":  {"gap_assessment_id", intField, true},
+		"created_at":         {"created_at", dateField, false},
+		"updated_at":         {"updated_at", dateField, false},
+	},
+	defaultOrder: "risk_score DESC NULLS LAST, created_at DESC",
+}
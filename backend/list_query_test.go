@@ -0,0 +1,228 @@
+package main
+
+import (
+	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var testList = listSpec{
+	table: "things",
+	fields: map[string]listField{
+		"id":          {"id", intField, false},
+		"title":       {"title", textField, false},
+		"owner":       {"owner", textField, true},
+		"section":     {"section", sectionField, false},
+		"score":       {"score", intField, true},
+		"due_date":    {"due_date", dateField, true},
+		"archived":    {"archived", boolField, false},
+		"description": {"description", textField, false},
+	},
+	defaultOrder: "due_date NULLS LAST",
+}
+
+func TestParseListQuery(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		conditions []string
+		args       []interface{}
+		orderBy    string
+		limit      int
+		offset     int
+	}{
+		{
+			name:    "no parameters",
+			orderBy: "due_date NULLS LAST, id",
+		},
+		{
+			name:       "text match is case-insensitive",
+			query:      "title=Access%20Policy",
+			conditions: []string{"(LOWER(title) = $1)"},
+			args:       []interface{}{"access policy"},
+			orderBy:    "due_date NULLS LAST, id",
+		},
+		{
+			name:       "repeated values are alternatives",
+			query:      "score=3&score=5",
+			conditions: []string{"(score = $1 OR score = $2)"},
+			args:       []interface{}{3, 5},
+			orderBy:    "due_date NULLS LAST, id",
+		},
+		{
+			name:       "prefix match escapes LIKE wildcards",
+			query:      "title=100%25_off%5C*",
+			conditions: []string{"(LOWER(title) LIKE $1)"},
+			args:       []interface{}{`100\%\_off\\%`},
+			orderBy:    "due_date NULLS LAST, id",
+		},
+		{
+			name:       "section matches its heading",
+			query:      "section=A.8",
+			conditions: []string{"((LOWER(section) = $1 OR LOWER(section) LIKE $2))"},
+			args:       []interface{}{"a.8", `a.8 %`},
+			orderBy:    "due_date NULLS LAST, id",
+		},
+		{
+			name:       "null on a nullable field",
+			query:      "owner=NULL&owner=ciso",
+			conditions: []string{"(owner IS NULL OR LOWER(owner) = $1)"},
+			args:       []interface{}{"ciso"},
+			orderBy:    "due_date NULLS LAST, id",
+		},
+		{
+			name:       "null on a required field is a value",
+			query:      "title=null",
+			conditions: []string{"(LOWER(title) = $1)"},
+			args:       []interface{}{"null"},
+			orderBy:    "due_date NULLS LAST, id",
+		},
+		{
+			name:       "not equal keeps missing values",
+			query:      "owner%5Bne%5D=CISO",
+			conditions: []string{"(LOWER(owner) <> $1 OR owner IS NULL)"},
+			args:       []interface{}{"ciso"},
+			orderBy:    "due_date NULLS LAST, id",
+		},
+		{
+			name:       "ranges",
+			query:      "score%5Bgte%5D=10&due_date%5Blt%5D=2025-01-31",
+			conditions: []string{"(due_date)::date < $1", "score >= $2"},
+			args:       []interface{}{"2025-01-31", 10},
+			orderBy:    "due_date NULLS LAST, id",
+		},
+		{
+			name:       "bool",
+			query:      "archived=true",
+			conditions: []string{"(archived = $1)"},
+			args:       []interface{}{true},
+			orderBy:    "due_date NULLS LAST, id",
+		},
+		{
+			name:    "sort",
+			query:   "sort=-score,%20title",
+			orderBy: "score DESC NULLS LAST, title ASC NULLS LAST, id",
+		},
+		{
+			name:    "sort by id needs no tie-break",
+			query:   "sort=id",
+			orderBy: "id ASC NULLS LAST",
+		},
+		{
+			name:    "page",
+			query:   "limit=1&offset=0&limit=1000",
+			orderBy: "due_date NULLS LAST, id",
+			limit:   maxListLimit,
+		},
+		{
+			name:    "offset",
+			query:   "offset=40",
+			orderBy: "due_date NULLS LAST, id",
+			offset:  40,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			q, err := parseListQuery(values, testList)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(q.conditions, tt.conditions) {
+				t.Errorf("conditions %q, want %q", q.conditions, tt.conditions)
+			}
+			if !reflect.DeepEqual(q.args, tt.args) {
+				t.Errorf("args %#v, want %#v", q.args, tt.args)
+			}
+			if q.orderBy != tt.orderBy {
+				t.Errorf("order by %q, want %q", q.orderBy, tt.orderBy)
+			}
+			if q.limit != tt.limit || q.offset != tt.offset {
+				t.Errorf("limit %d offset %d, want %d and %d", q.limit, q.offset, tt.limit, tt.offset)
+			}
+		})
+	}
+}
+
+func TestParseListQueryRejects(t *testing.T) {
+	tests := []struct {
+		name, query, err string
+	}{
+		{"unknown column", "password=x", `cannot filter by "password"`},
+		{"column outside the allowlist", "title%20OR%201=1", `unknown parameter "title OR 1"`},
+		{"quoted parameter", "title%27=x", `unknown parameter "title'"`},
+		{"unknown operator", "score%5Blike%5D=1", `unknown parameter "score[like]"`},
+		{"sort injection", "sort=id%3Bdrop%20table%20things", `cannot sort by "id;drop table things"`},
+		{"sort by expression", "sort=(select%201)", `cannot sort by "(select 1)"`},
+		{"sort by unknown column", "sort=-password", `cannot sort by "password"`},
+		{"empty sort term", "sort=title,", `cannot sort by ""`},
+		{"double minus", "sort=--id", `cannot sort by "-id"`},
+		{"range on text", "title%5Bgt%5D=a", "title does not support range filters"},
+		{"range on bool", "archived%5Blt%5D=true", "archived does not support range filters"},
+		{"not a number", "score=1%3Bdrop", "score must be a whole number"},
+		{"not a date", "due_date=tomorrow", "due_date must be a date"},
+		{"not a bool", "archived=maybe", "archived must be true or false"},
+		{"null on a required int", "id=null", "id must be a whole number"},
+		{"limit zero", "limit=0", "limit must be between 1 and 1000"},
+		{"limit too large", "limit=1001", "limit must be between 1 and 1000"},
+		{"limit not a number", "limit=ten", "limit must be between 1 and 1000"},
+		{"negative offset", "offset=-1", "offset must be a non-negative number"},
+		{"offset not a number", "offset=1e3", "offset must be a non-negative number"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			q, err := parseListQuery(values, testList)
+			if err == nil {
+				t.Fatalf("accepted, order by %q, conditions %q", q.orderBy, q.conditions)
+			}
+			if !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("error %q, want it to contain %q", err, tt.err)
+			}
+		})
+	}
+}
+
+// Only the column expressions of the list specs reach the SQL text, so they
+// must be plain identifiers.
+func TestListSpecsUsePlainColumns(t *testing.T) {
+	ident := regexp.MustCompile(`^[a-z_]+$`)
+	for _, spec := range []listSpec{gapAssessmentList, maturityAssessmentList, actionItemList, evidenceList, riskList} {
+		if !ident.MatchString(spec.table) {
+			t.Errorf("table %q", spec.table)
+		}
+		for name, f := range spec.fields {
+			if !ident.MatchString(name) || !ident.MatchString(f.column) {
+				t.Errorf("%s: field %q has column %q", spec.table, name, f.column)
+			}
+			if !listParam.MatchString(name) {
+				t.Errorf("%s: field %q cannot be named in a query", spec.table, name)
+			}
+		}
+	}
+}
+
+func TestLikePrefix(t *testing.T) {
+	tests := map[string]string{
+		"":          "%",
+		"a.8":       "a.8%",
+		"50%":       `50\%%`,
+		"a_b":       `a\_b%`,
+		`c:\temp`:   `c:\\temp%`,
+		`\%_`:       `\\\%\_%`,
+		"plain ref": "plain ref%",
+	}
+	for in, want := range tests {
+		if got := likePrefix(in); got != want {
+			t.Errorf("likePrefix(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
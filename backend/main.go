@@ -17,17 +17,20 @@ import (
 )
 
 type GapAssessment struct {
-	ID                 int     `json:"id"`
-	Category           string  `json:"category"`
-	Section            string  `json:"section"`
-	StandardRef        string  `json:"standard_ref"`
-	AssessmentQuestion string  `json:"assessment_question"`
-	Compliance         string  `json:"compliance"`
-	Notes              string  `json:"notes"`
-	TargetDate         *string `json:"target_date,omitempty"`
-	ActionItemID       *int    `json:"action_item_id,omitempty"`
-	CreatedAt          string  `json:"created_at"`
-	UpdatedAt          string  `json:"updated_at"`
+	ID                  int     `json:"id"`
+	Category            string  `json:"category"`
+	Section             string  `json:"section"`
+	StandardRef         string  `json:"standard_ref"`
+	AssessmentQuestion  string  `json:"assessment_question"`
+	Compliance          string  `json:"compliance"`
+	Notes               string  `json:"notes"`
+	TargetDate          *string `json:"target_date,omitempty"`
+	ActionItemID        *int    `json:"action_item_id,omitempty"`
+	EvidenceRefreshDays *int    `json:"evidence_refresh_days,omitempty"`
+	CycleID             *int    `json:"cycle_id,omitempty"`
+	PreviousID          *int    `json:"previous_id,omitempty"`
+	CreatedAt           string  `json:"created_at"`
+	UpdatedAt           string  `json:"updated_at"`
 }
 
 type ActionItem struct {
@@ -46,6 +49,7 @@ type ActionItem struct {
 	FilePath             *string `json:"file_path,omitempty"`
 	FileSize             *int    `json:"file_size,omitempty"`
 	FileType             *string `json:"file_type,omitempty"`
+	SHA256               *string `json:"sha256,omitempty"`
 	ClauseReference      *string `json:"clause_reference,omitempty"`
 	AnnexReference       *string `json:"annex_reference,omitempty"`
 	CreatedAt            string  `json:"created_at"`
@@ -53,41 +57,59 @@ type ActionItem struct {
 }
 
 type Evidence struct {
-	ID                 int     `json:"id"`
-	Title              string  `json:"title"`
-	Description        string  `json:"description"`
-	FileName           string  `json:"file_name"`
-	FilePath            string  `json:"file_path"`
-	FileSize           *int    `json:"file_size,omitempty"`
-	FileType           string  `json:"file_type"`
-	GapAssessmentID    *int    `json:"gap_assessment_id,omitempty"`
-	MaturityAssessmentID *int  `json:"maturity_assessment_id,omitempty"`
-	ClauseReference    string  `json:"clause_reference"`
-	AnnexReference     string  `json:"annex_reference"`
-	UploadedBy         string  `json:"uploaded_by"`
-	UploadedAt         string  `json:"uploaded_at"`
-	CreatedAt          string  `json:"created_at"`
-	UpdatedAt          string  `json:"updated_at"`
+	ID                   int     `json:"id"`
+	Title                string  `json:"title"`
+	Description          string  `json:"description"`
+	FileName             string  `json:"file_name"`
+	FilePath             string  `json:"file_path"`
+	FileSize             *int    `json:"file_size,omitempty"`
+	FileType             string  `json:"file_type"`
+	SHA256               *string `json:"sha256,omitempty"`
+	CurrentVersionID     *int    `json:"current_version_id,omitempty"`
+	GapAssessmentID      *int    `json:"gap_assessment_id,omitempty"`
+	MaturityAssessmentID *int    `json:"maturity_assessment_id,omitempty"`
+	ClauseReference      string  `json:"clause_reference"`
+	AnnexReference       string  `json:"annex_reference"`
+	UploadedBy           string  `json:"uploaded_by"`
+	UploadedAt           string  `json:"uploaded_at"`
+	CollectedAt          *string `json:"collected_at,omitempty"`
+	ValidUntil           *string `json:"valid_until,omitempty"`
+	CreatedAt            string  `json:"created_at"`
+	UpdatedAt            string  `json:"updated_at"`
 }
 
 type RiskRegister struct {
-	ID                int     `json:"id"`
-	RiskID            string  `json:"risk_id"`
-	Title             string  `json:"title"`
-	Description       string  `json:"description"`
-	Category          string  `json:"category"`
-	Likelihood        string  `json:"likelihood"`
-	Impact            string  `json:"impact"`
-	RiskLevel         string  `json:"risk_level"`
-	CurrentControls   string  `json:"current_controls"`
-	TreatmentPlan     string  `json:"treatment_plan"`
-	TreatmentStatus   string  `json:"treatment_status"`
-	Owner             string  `json:"owner"`
-	TargetDate        *string `json:"target_date,omitempty"`
-	GapAssessmentID   *int    `json:"gap_assessment_id,omitempty"`
-	AnnexAControls    string  `json:"annex_a_controls"`
-	CreatedAt         string  `json:"created_at"`
-	UpdatedAt         string  `json:"updated_at"`
+	ID                   int      `json:"id"`
+	RiskID               string   `json:"risk_id"`
+	Title                string   `json:"title"`
+	Description          string   `json:"description"`
+	Category             string   `json:"category"`
+	Likelihood           string   `json:"likelihood"`
+	Impact               string   `json:"impact"`
+	ImpactFromAssets     bool     `json:"impact_from_assets"`
+	RiskLevel            string   `json:"risk_level"`
+	RiskScore            *int     `json:"risk_score,omitempty"`
+	ResidualLikelihood   *string  `json:"residual_likelihood,omitempty"`
+	ResidualImpact       *string  `json:"residual_impact,omitempty"`
+	ResidualScore        *int     `json:"residual_score,omitempty"`
+	ResidualLevel        *string  `json:"residual_level,omitempty"`
+	ControlEffectiveness *float64 `json:"control_effectiveness,omitempty"`
+	CurrentControls      string   `json:"current_controls"`
+	TreatmentPlan        string   `json:"treatment_plan"`
+	TreatmentOption      *string  `json:"treatment_option,omitempty"`
+	TreatmentStatus      string   `json:"treatment_status"`
+	Owner                string   `json:"owner"`
+	TargetDate           *string  `json:"target_date,omitempty"`
+	GapAssessmentID      *int     `json:"gap_assessment_id,omitempty"`
+	FrequencyMin         *float64 `json:"frequency_min,omitempty"`
+	FrequencyMostLikely  *float64 `json:"frequency_most_likely,omitempty"`
+	FrequencyMax         *float64 `json:"frequency_max,omitempty"`
+	LossMin              *float64 `json:"loss_min,omitempty"`
+	LossMostLikely       *float64 `json:"loss_most_likely,omitempty"`
+	LossMax              *float64 `json:"loss_max,omitempty"`
+	AnnexAControls       string   `json:"annex_a_controls"`
+	CreatedAt            string   `json:"created_at"`
+	UpdatedAt            string   `json:"updated_at"`
 }
 
 type MaturityAssessment struct {
@@ -102,12 +124,17 @@ type MaturityAssessment struct {
 	TargetMaturityLevel     string `json:"target_maturity_level"`
 	TargetMaturityScore     *int   `json:"target_maturity_score"`
 	TargetMaturityComments string `json:"target_maturity_comments"`
+	CycleID                 *int   `json:"cycle_id,omitempty"`
+	PreviousID              *int   `json:"previous_id,omitempty"`
 	CreatedAt               string `json:"created_at"`
 	UpdatedAt               string `json:"updated_at"`
 }
 
 type App struct {
-	DB *sql.DB
+	DB      *sql.DB
+	Storage Storage
+	Uploads *UploadPolicy
+	RiskIDs *RiskIDPolicy
 }
 
 func getEnv(key, defaultValue string) string {
@@ -145,8 +172,9 @@ func connectDB() (*sql.DB, error) {
 func (app *App) enableCORS(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Range")
+		w.Header().Set("Access-Control-Expose-Headers", "Content-Disposition, Content-Range, Accept-Ranges, Content-Length, X-Total-Count")
 
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
@@ -159,18 +187,20 @@ func (app *App) enableCORS(next http.Handler) http.Handler {
 
 // Gap Assessment Handlers
 func (app *App) getGapAssessments(w http.ResponseWriter, r *http.Request) {
-	rows, err := app.DB.Query("SELECT id, category, section, standard_ref, assessment_question, compliance, notes, target_date, action_item_id, created_at, updated_at FROM gap_assessments ORDER BY id")
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := scopeListToCycle(app.DB, r); err != nil {
+		writeRiskError(w, err)
+		return
+	}
+	rows := app.queryList(w, r, gapAssessmentList, "SELECT "+gapAssessmentColumns+" FROM gap_assessments")
+	if rows == nil {
 		return
 	}
 	defer rows.Close()
 
-	var assessments []GapAssessment
+	assessments := []GapAssessment{}
 	for rows.Next() {
 		var a GapAssessment
-		err := rows.Scan(&a.ID, &a.Category, &a.Section, &a.StandardRef, &a.AssessmentQuestion, &a.Compliance, &a.Notes, &a.TargetDate, &a.ActionItemID, &a.CreatedAt, &a.UpdatedAt)
-		if err != nil {
+		if err := scanGapAssessment(rows, &a); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
@@ -181,6 +211,13 @@ func (app *App) getGapAssessments(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(assessments)
 }
 
+// gapAssessmentColumns is the column list scanned by scanGapAssessment.
+const gapAssessmentColumns = "id, category, section, standard_ref, assessment_question, compliance, notes, target_date, action_item_id, evidence_refresh_days, cycle_id, previous_id, created_at, updated_at"
+
+func scanGapAssessment(row rowScanner, a *GapAssessment) error {
+	return row.Scan(&a.ID, &a.Category, &a.Section, &a.StandardRef, &a.AssessmentQuestion, &a.Compliance, &a.Notes, &a.TargetDate, &a.ActionItemID, &a.EvidenceRefreshDays, &a.CycleID, &a.PreviousID, &a.CreatedAt, &a.UpdatedAt)
+}
+
 func (app *App) getGapAssessment(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
@@ -190,8 +227,7 @@ func (app *App) getGapAssessment(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var a GapAssessment
-	err = app.DB.QueryRow("SELECT id, category, section, standard_ref, assessment_question, compliance, notes, target_date, action_item_id, created_at, updated_at FROM gap_assessments WHERE id = $1", id).
-		Scan(&a.ID, &a.Category, &a.Section, &a.StandardRef, &a.AssessmentQuestion, &a.Compliance, &a.Notes, &a.TargetDate, &a.ActionItemID, &a.CreatedAt, &a.UpdatedAt)
+	err = scanGapAssessment(app.DB.QueryRow("SELECT "+gapAssessmentColumns+" FROM gap_assessments WHERE id = $1", id), &a)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			http.Error(w, "Assessment not found", http.StatusNotFound)
@@ -205,19 +241,87 @@ func (app *App) getGapAssessment(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(a)
 }
 
+// insertGapAssessment validates and stores a new gap assessment row in cycle
+// a.CycleID, or the current cycle if it is unset.
+func insertGapAssessment(db dbExecutor, a *GapAssessment) error {
+	if a.EvidenceRefreshDays != nil && *a.EvidenceRefreshDays <= 0 {
+		return &riskInputError{"evidence_refresh_days must be a positive number of days"}
+	}
+	if err := normalizeCompliance(db, a); err != nil {
+		return err
+	}
+	cycleID, err := openCycleID(db, a.CycleID)
+	if err != nil {
+		return err
+	}
+	a.CycleID, a.PreviousID = &cycleID, nil
+	return db.QueryRow(
+		"INSERT INTO gap_assessments (category, section, standard_ref, assessment_question, compliance, notes, target_date, action_item_id, evidence_refresh_days, cycle_id) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id, created_at, updated_at",
+		a.Category, a.Section, a.StandardRef, a.AssessmentQuestion, a.Compliance, a.Notes, a.TargetDate, a.ActionItemID, a.EvidenceRefreshDays, cycleID,
+	).Scan(&a.ID, &a.CreatedAt, &a.UpdatedAt)
+}
+
+// saveGapAssessment validates and stores gap assessment id, then re-rates the
+// risks it mitigates. A missing row is reported as sql.ErrNoRows and a row of
+// a closed cycle as *cycleClosedError; a row never moves to another cycle.
+func saveGapAssessment(db dbExecutor, id int, a *GapAssessment) error {
+	if a.EvidenceRefreshDays != nil && *a.EvidenceRefreshDays <= 0 {
+		return &riskInputError{"evidence_refresh_days must be a positive number of days"}
+	}
+	if err := checkCycleOpen(db, "gap_assessments", id); err != nil {
+		return err
+	}
+	if err := normalizeCompliance(db, a); err != nil {
+		return err
+	}
+	err := db.QueryRow(
+		"UPDATE gap_assessments SET category = $1, section = $2, standard_ref = $3, assessment_question = $4, compliance = $5, notes = $6, target_date = $7, action_item_id = $8, evidence_refresh_days = $9 WHERE id = $10 RETURNING id, cycle_id, previous_id, created_at, updated_at",
+		a.Category, a.Section, a.StandardRef, a.AssessmentQuestion, a.Compliance, a.Notes, a.TargetDate, a.ActionItemID, a.EvidenceRefreshDays, id,
+	).Scan(&a.ID, &a.CycleID, &a.PreviousID, &a.CreatedAt, &a.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	// Compliance drives the residual rating of the risks this control mitigates
+	return refreshResidualForControl(db, a.ID)
+}
+
+// removeGapAssessment deletes gap assessment id and recalculates the residual
+// rating of the risks it mitigated, whose links go with it by cascade. A
+// missing row is reported as sql.ErrNoRows.
+func removeGapAssessment(db dbExecutor, id int) error {
+	if err := checkCycleOpen(db, "gap_assessments", id); err != nil {
+		return err
+	}
+	risks, err := queryIDs(db, "SELECT risk_register_id FROM risk_controls WHERE gap_assessment_id = $1", id)
+	if err != nil {
+		return err
+	}
+	if err := deleteBulkRow(db, "gap_assessments", id); err != nil {
+		return err
+	}
+	if len(risks) == 0 {
+		return nil
+	}
+	m, err := loadRiskMatrix(db)
+	if err != nil {
+		return err
+	}
+	return refreshResidualRisks(db, m, risks)
+}
+
 func (app *App) createGapAssessment(w http.ResponseWriter, r *http.Request) {
 	var a GapAssessment
 	if err := json.NewDecoder(r.Body).Decode(&a); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	if err := applyCycleParam(app.DB, r, &a.CycleID); err != nil {
+		writeRiskError(w, err)
+		return
+	}
 
-	err := app.DB.QueryRow(
-		"INSERT INTO gap_assessments (category, section, standard_ref, assessment_question, compliance, notes, target_date, action_item_id) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id, created_at, updated_at",
-		a.Category, a.Section, a.StandardRef, a.AssessmentQuestion, a.Compliance, a.Notes, a.TargetDate, a.ActionItemID,
-	).Scan(&a.ID, &a.CreatedAt, &a.UpdatedAt)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := insertGapAssessment(app.DB, &a); err != nil {
+		writeCycleError(w, err, "Assessment not found")
 		return
 	}
 
@@ -240,16 +344,24 @@ func (app *App) updateGapAssessment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = app.DB.QueryRow(
-		"UPDATE gap_assessments SET category = $1, section = $2, standard_ref = $3, assessment_question = $4, compliance = $5, notes = $6, target_date = $7, action_item_id = $8 WHERE id = $9 RETURNING id, created_at, updated_at",
-		a.Category, a.Section, a.StandardRef, a.AssessmentQuestion, a.Compliance, a.Notes, a.TargetDate, a.ActionItemID, id,
-	).Scan(&a.ID, &a.CreatedAt, &a.UpdatedAt)
+	tx, err := app.DB.Begin()
 	if err != nil {
-		if err == sql.ErrNoRows {
-			http.Error(w, "Assessment not found", http.StatusNotFound)
-		} else {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	if err := checkPatchBase(tx, r, id); err != nil {
+		writePatchError(w, err, "Assessment not found")
+		return
+	}
+
+	if err := saveGapAssessment(tx, id, &a); err != nil {
+		writeCycleError(w, err, "Assessment not found")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -265,20 +377,19 @@ func (app *App) deleteGapAssessment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	result, err := app.DB.Exec("DELETE FROM gap_assessments WHERE id = $1", id)
+	tx, err := app.DB.Begin()
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	defer tx.Rollback()
 
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := removeGapAssessment(tx, id); err != nil {
+		writeCycleError(w, err, "Assessment not found")
 		return
 	}
-
-	if rowsAffected == 0 {
-		http.Error(w, "Assessment not found", http.StatusNotFound)
+	if err := tx.Commit(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -287,18 +398,20 @@ func (app *App) deleteGapAssessment(w http.ResponseWriter, r *http.Request) {
 
 // Maturity Assessment Handlers
 func (app *App) getMaturityAssessments(w http.ResponseWriter, r *http.Request) {
-	rows, err := app.DB.Query("SELECT id, category, section, standard_ref, assessment_question, current_maturity_level, current_maturity_score, current_maturity_comments, target_maturity_level, target_maturity_score, target_maturity_comments, created_at, updated_at FROM maturity_assessments ORDER BY id")
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := scopeListToCycle(app.DB, r); err != nil {
+		writeRiskError(w, err)
+		return
+	}
+	rows := app.queryList(w, r, maturityAssessmentList, "SELECT "+maturityAssessmentColumns+" FROM maturity_assessments")
+	if rows == nil {
 		return
 	}
 	defer rows.Close()
 
-	var assessments []MaturityAssessment
+	assessments := []MaturityAssessment{}
 	for rows.Next() {
 		var a MaturityAssessment
-		err := rows.Scan(&a.ID, &a.Category, &a.Section, &a.StandardRef, &a.AssessmentQuestion, &a.CurrentMaturityLevel, &a.CurrentMaturityScore, &a.CurrentMaturityComments, &a.TargetMaturityLevel, &a.TargetMaturityScore, &a.TargetMaturityComments, &a.CreatedAt, &a.UpdatedAt)
-		if err != nil {
+		if err := scanMaturityAssessment(rows, &a); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
@@ -309,6 +422,13 @@ func (app *App) getMaturityAssessments(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(assessments)
 }
 
+// maturityAssessmentColumns is the column list scanned by scanMaturityAssessment.
+const maturityAssessmentColumns = "id, category, section, standard_ref, assessment_question, current_maturity_level, current_maturity_score, current_maturity_comments, target_maturity_level, target_maturity_score, target_maturity_comments, cycle_id, previous_id, created_at, updated_at"
+
+func scanMaturityAssessment(row rowScanner, a *MaturityAssessment) error {
+	return row.Scan(&a.ID, &a.Category, &a.Section, &a.StandardRef, &a.AssessmentQuestion, &a.CurrentMaturityLevel, &a.CurrentMaturityScore, &a.CurrentMaturityComments, &a.TargetMaturityLevel, &a.TargetMaturityScore, &a.TargetMaturityComments, &a.CycleID, &a.PreviousID, &a.CreatedAt, &a.UpdatedAt)
+}
+
 func (app *App) getMaturityAssessment(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
@@ -318,8 +438,7 @@ func (app *App) getMaturityAssessment(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var a MaturityAssessment
-	err = app.DB.QueryRow("SELECT id, category, section, standard_ref, assessment_question, current_maturity_level, current_maturity_score, current_maturity_comments, target_maturity_level, target_maturity_score, target_maturity_comments, created_at, updated_at FROM maturity_assessments WHERE id = $1", id).
-		Scan(&a.ID, &a.Category, &a.Section, &a.StandardRef, &a.AssessmentQuestion, &a.CurrentMaturityLevel, &a.CurrentMaturityScore, &a.CurrentMaturityComments, &a.TargetMaturityLevel, &a.TargetMaturityScore, &a.TargetMaturityComments, &a.CreatedAt, &a.UpdatedAt)
+	err = scanMaturityAssessment(app.DB.QueryRow("SELECT "+maturityAssessmentColumns+" FROM maturity_assessments WHERE id = $1", id), &a)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			http.Error(w, "Assessment not found", http.StatusNotFound)
@@ -339,10 +458,20 @@ func (app *App) createMaturityAssessment(w http.ResponseWriter, r *http.Request)
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	if err := applyCycleParam(app.DB, r, &a.CycleID); err != nil {
+		writeRiskError(w, err)
+		return
+	}
+	cycleID, err := openCycleID(app.DB, a.CycleID)
+	if err != nil {
+		writeCycleError(w, err, "Assessment not found")
+		return
+	}
+	a.CycleID, a.PreviousID = &cycleID, nil
 
-	err := app.DB.QueryRow(
-		"INSERT INTO maturity_assessments (category, section, standard_ref, assessment_question, current_maturity_level, current_maturity_score, current_maturity_comments, target_maturity_level, target_maturity_score, target_maturity_comments) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id, created_at, updated_at",
-		a.Category, a.Section, a.StandardRef, a.AssessmentQuestion, a.CurrentMaturityLevel, a.CurrentMaturityScore, a.CurrentMaturityComments, a.TargetMaturityLevel, a.TargetMaturityScore, a.TargetMaturityComments,
+	err = app.DB.QueryRow(
+		"INSERT INTO maturity_assessments (category, section, standard_ref, assessment_question, current_maturity_level, current_maturity_score, current_maturity_comments, target_maturity_level, target_maturity_score, target_maturity_comments, cycle_id) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) RETURNING id, created_at, updated_at",
+		a.Category, a.Section, a.StandardRef, a.AssessmentQuestion, a.CurrentMaturityLevel, a.CurrentMaturityScore, a.CurrentMaturityComments, a.TargetMaturityLevel, a.TargetMaturityScore, a.TargetMaturityComments, cycleID,
 	).Scan(&a.ID, &a.CreatedAt, &a.UpdatedAt)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -368,10 +497,27 @@ func (app *App) updateMaturityAssessment(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	err = app.DB.QueryRow(
-		"UPDATE maturity_assessments SET category = $1, section = $2, standard_ref = $3, assessment_question = $4, current_maturity_level = $5, current_maturity_score = $6, current_maturity_comments = $7, target_maturity_level = $8, target_maturity_score = $9, target_maturity_comments = $10 WHERE id = $11 RETURNING id, created_at, updated_at",
+	tx, err := app.DB.Begin()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	if err := checkPatchBase(tx, r, id); err != nil {
+		writePatchError(w, err, "Assessment not found")
+		return
+	}
+
+	if err := checkCycleOpen(tx, "maturity_assessments", id); err != nil {
+		writeCycleError(w, err, "Assessment not found")
+		return
+	}
+
+	err = tx.QueryRow(
+		"UPDATE maturity_assessments SET category = $1, section = $2, standard_ref = $3, assessment_question = $4, current_maturity_level = $5, current_maturity_score = $6, current_maturity_comments = $7, target_maturity_level = $8, target_maturity_score = $9, target_maturity_comments = $10 WHERE id = $11 RETURNING id, cycle_id, previous_id, created_at, updated_at",
 		a.Category, a.Section, a.StandardRef, a.AssessmentQuestion, a.CurrentMaturityLevel, a.CurrentMaturityScore, a.CurrentMaturityComments, a.TargetMaturityLevel, a.TargetMaturityScore, a.TargetMaturityComments, id,
-	).Scan(&a.ID, &a.CreatedAt, &a.UpdatedAt)
+	).Scan(&a.ID, &a.CycleID, &a.PreviousID, &a.CreatedAt, &a.UpdatedAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			http.Error(w, "Assessment not found", http.StatusNotFound)
@@ -380,6 +526,10 @@ func (app *App) updateMaturityAssessment(w http.ResponseWriter, r *http.Request)
 		}
 		return
 	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(a)
@@ -393,6 +543,11 @@ func (app *App) deleteMaturityAssessment(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if err := checkCycleOpen(app.DB, "maturity_assessments", id); err != nil {
+		writeCycleError(w, err, "Assessment not found")
+		return
+	}
+
 	result, err := app.DB.Exec("DELETE FROM maturity_assessments WHERE id = $1", id)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -415,14 +570,13 @@ func (app *App) deleteMaturityAssessment(w http.ResponseWriter, r *http.Request)
 
 // Action Item Handlers
 func (app *App) getActionItems(w http.ResponseWriter, r *http.Request) {
-	rows, err := app.DB.Query("SELECT id, title, description, status, priority, assigned_to, due_date, completed_date, gap_assessment_id, maturity_assessment_id, category, created_at, updated_at FROM action_items ORDER BY due_date NULLS LAST, priority DESC, created_at DESC")
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	rows := app.queryList(w, r, actionItemList, "SELECT id, title, description, status, priority, assigned_to, due_date, completed_date, gap_assessment_id, maturity_assessment_id, category, created_at, updated_at FROM action_items")
+	if rows == nil {
 		return
 	}
 	defer rows.Close()
 
-	var items []ActionItem
+	items := []ActionItem{}
 	for rows.Next() {
 		var item ActionItem
 		err := rows.Scan(&item.ID, &item.Title, &item.Description, &item.Status, &item.Priority, &item.AssignedTo, &item.DueDate, &item.CompletedDate, &item.GapAssessmentID, &item.MaturityAssessmentID, &item.Category, &item.CreatedAt, &item.UpdatedAt)
@@ -437,6 +591,13 @@ func (app *App) getActionItems(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(items)
 }
 
+// actionItemColumns is the column list scanned by scanActionItem.
+const actionItemColumns = "id, title, description, status, priority, assigned_to, due_date, completed_date, gap_assessment_id, maturity_assessment_id, category, file_name, file_path, file_size, file_type, sha256, clause_reference, annex_reference, created_at, updated_at"
+
+func scanActionItem(row rowScanner, item *ActionItem) error {
+	return row.Scan(&item.ID, &item.Title, &item.Description, &item.Status, &item.Priority, &item.AssignedTo, &item.DueDate, &item.CompletedDate, &item.GapAssessmentID, &item.MaturityAssessmentID, &item.Category, &item.FileName, &item.FilePath, &item.FileSize, &item.FileType, &item.SHA256, &item.ClauseReference, &item.AnnexReference, &item.CreatedAt, &item.UpdatedAt)
+}
+
 func (app *App) getActionItem(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
@@ -446,8 +607,7 @@ func (app *App) getActionItem(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var item ActionItem
-	err = app.DB.QueryRow("SELECT id, title, description, status, priority, assigned_to, due_date, completed_date, gap_assessment_id, maturity_assessment_id, category, file_name, file_path, file_size, file_type, clause_reference, annex_reference, created_at, updated_at FROM action_items WHERE id = $1", id).
-		Scan(&item.ID, &item.Title, &item.Description, &item.Status, &item.Priority, &item.AssignedTo, &item.DueDate, &item.CompletedDate, &item.GapAssessmentID, &item.MaturityAssessmentID, &item.Category, &item.FileName, &item.FilePath, &item.FileSize, &item.FileType, &item.ClauseReference, &item.AnnexReference, &item.CreatedAt, &item.UpdatedAt)
+	err = scanActionItem(app.DB.QueryRow("SELECT "+actionItemColumns+" FROM action_items WHERE id = $1", id), &item)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			http.Error(w, "Action item not found", http.StatusNotFound)
@@ -461,6 +621,25 @@ func (app *App) getActionItem(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(item)
 }
 
+// insertActionItem stores a new action item.
+func insertActionItem(db dbExecutor, item *ActionItem) error {
+	// File fields and digests are only ever set by the server from an uploaded file
+	item.FileName, item.FilePath, item.FileSize, item.FileType, item.SHA256 = nil, nil, nil, nil, nil
+	return db.QueryRow(
+		"INSERT INTO action_items (title, description, status, priority, assigned_to, due_date, completed_date, gap_assessment_id, maturity_assessment_id, category, file_name, file_path, file_size, file_type, clause_reference, annex_reference) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16) RETURNING id, created_at, updated_at",
+		item.Title, item.Description, item.Status, item.Priority, item.AssignedTo, item.DueDate, item.CompletedDate, item.GapAssessmentID, item.MaturityAssessmentID, item.Category, item.FileName, item.FilePath, item.FileSize, item.FileType, item.ClauseReference, item.AnnexReference,
+	).Scan(&item.ID, &item.CreatedAt, &item.UpdatedAt)
+}
+
+// saveActionItem stores action item id. Its file fields are left as the
+// upload handler stored them. A missing row is reported as sql.ErrNoRows.
+func saveActionItem(db dbExecutor, id int, item *ActionItem) error {
+	return db.QueryRow(
+		"UPDATE action_items SET title = $1, description = $2, status = $3, priority = $4, assigned_to = $5, due_date = $6, completed_date = $7, gap_assessment_id = $8, maturity_assessment_id = $9, category = $10, clause_reference = $11, annex_reference = $12 WHERE id = $13 RETURNING id, file_name, file_path, file_size, file_type, sha256, created_at, updated_at",
+		item.Title, item.Description, item.Status, item.Priority, item.AssignedTo, item.DueDate, item.CompletedDate, item.GapAssessmentID, item.MaturityAssessmentID, item.Category, item.ClauseReference, item.AnnexReference, id,
+	).Scan(&item.ID, &item.FileName, &item.FilePath, &item.FileSize, &item.FileType, &item.SHA256, &item.CreatedAt, &item.UpdatedAt)
+}
+
 func (app *App) createActionItem(w http.ResponseWriter, r *http.Request) {
 	var item ActionItem
 	if err := json.NewDecoder(r.Body).Decode(&item); err != nil {
@@ -468,11 +647,7 @@ func (app *App) createActionItem(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := app.DB.QueryRow(
-		"INSERT INTO action_items (title, description, status, priority, assigned_to, due_date, completed_date, gap_assessment_id, maturity_assessment_id, category, file_name, file_path, file_size, file_type, clause_reference, annex_reference) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16) RETURNING id, created_at, updated_at",
-		item.Title, item.Description, item.Status, item.Priority, item.AssignedTo, item.DueDate, item.CompletedDate, item.GapAssessmentID, item.MaturityAssessmentID, item.Category, item.FileName, item.FilePath, item.FileSize, item.FileType, item.ClauseReference, item.AnnexReference,
-	).Scan(&item.ID, &item.CreatedAt, &item.UpdatedAt)
-	if err != nil {
+	if err := insertActionItem(app.DB, &item); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -496,10 +671,19 @@ func (app *App) updateActionItem(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = app.DB.QueryRow(
-		"UPDATE action_items SET title = $1, description = $2, status = $3, priority = $4, assigned_to = $5, due_date = $6, completed_date = $7, gap_assessment_id = $8, maturity_assessment_id = $9, category = $10, file_name = $11, file_path = $12, file_size = $13, file_type = $14, clause_reference = $15, annex_reference = $16 WHERE id = $17 RETURNING id, created_at, updated_at",
-		item.Title, item.Description, item.Status, item.Priority, item.AssignedTo, item.DueDate, item.CompletedDate, item.GapAssessmentID, item.MaturityAssessmentID, item.Category, item.FileName, item.FilePath, item.FileSize, item.FileType, item.ClauseReference, item.AnnexReference, id,
-	).Scan(&item.ID, &item.CreatedAt, &item.UpdatedAt)
+	tx, err := app.DB.Begin()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	if err := checkPatchBase(tx, r, id); err != nil {
+		writePatchError(w, err, "Action item not found")
+		return
+	}
+
+	err = saveActionItem(tx, id, &item)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			http.Error(w, "Action item not found", http.StatusNotFound)
@@ -508,6 +692,10 @@ func (app *App) updateActionItem(w http.ResponseWriter, r *http.Request) {
 		}
 		return
 	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(item)
@@ -521,21 +709,20 @@ func (app *App) deleteActionItem(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	result, err := app.DB.Exec("DELETE FROM action_items WHERE id = $1", id)
+	var filePath sql.NullString
+	err = app.DB.QueryRow("DELETE FROM action_items WHERE id = $1 RETURNING file_path", id).Scan(&filePath)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Action item not found", http.StatusNotFound)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
 		return
 	}
 
-	if rowsAffected == 0 {
-		http.Error(w, "Action item not found", http.StatusNotFound)
-		return
+	// Best-effort cleanup of the uploaded file, if there was one
+	if filePath.Valid && filePath.String != "" {
+		app.removeOwnedFiles(fmt.Sprintf("action item %d", id), "action-items", []string{filePath.String})
 	}
 
 	w.WriteHeader(http.StatusNoContent)
@@ -543,17 +730,16 @@ func (app *App) deleteActionItem(w http.ResponseWriter, r *http.Request) {
 
 // Evidence Handlers
 func (app *App) getEvidence(w http.ResponseWriter, r *http.Request) {
-	rows, err := app.DB.Query("SELECT id, title, description, file_name, file_path, file_size, file_type, gap_assessment_id, maturity_assessment_id, clause_reference, annex_reference, uploaded_by, uploaded_at, created_at, updated_at FROM evidence ORDER BY created_at DESC")
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	rows := app.queryList(w, r, evidenceList, "SELECT id, title, description, file_name, file_path, file_size, file_type, sha256, current_version_id, gap_assessment_id, maturity_assessment_id, clause_reference, annex_reference, uploaded_by, uploaded_at, collected_at, valid_until, created_at, updated_at FROM evidence")
+	if rows == nil {
 		return
 	}
 	defer rows.Close()
 
-	var items []Evidence
+	items := []Evidence{}
 	for rows.Next() {
 		var item Evidence
-		err := rows.Scan(&item.ID, &item.Title, &item.Description, &item.FileName, &item.FilePath, &item.FileSize, &item.FileType, &item.GapAssessmentID, &item.MaturityAssessmentID, &item.ClauseReference, &item.AnnexReference, &item.UploadedBy, &item.UploadedAt, &item.CreatedAt, &item.UpdatedAt)
+		err := rows.Scan(&item.ID, &item.Title, &item.Description, &item.FileName, &item.FilePath, &item.FileSize, &item.FileType, &item.SHA256, &item.CurrentVersionID, &item.GapAssessmentID, &item.MaturityAssessmentID, &item.ClauseReference, &item.AnnexReference, &item.UploadedBy, &item.UploadedAt, &item.CollectedAt, &item.ValidUntil, &item.CreatedAt, &item.UpdatedAt)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -565,6 +751,13 @@ func (app *App) getEvidence(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(items)
 }
 
+// evidenceColumns is the column list scanned by scanEvidence.
+const evidenceColumns = "id, title, description, file_name, file_path, file_size, file_type, sha256, current_version_id, gap_assessment_id, maturity_assessment_id, clause_reference, annex_reference, uploaded_by, uploaded_at, collected_at, valid_until, created_at, updated_at"
+
+func scanEvidence(row rowScanner, item *Evidence) error {
+	return row.Scan(&item.ID, &item.Title, &item.Description, &item.FileName, &item.FilePath, &item.FileSize, &item.FileType, &item.SHA256, &item.CurrentVersionID, &item.GapAssessmentID, &item.MaturityAssessmentID, &item.ClauseReference, &item.AnnexReference, &item.UploadedBy, &item.UploadedAt, &item.CollectedAt, &item.ValidUntil, &item.CreatedAt, &item.UpdatedAt)
+}
+
 func (app *App) getEvidenceItem(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
@@ -574,8 +767,7 @@ func (app *App) getEvidenceItem(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var item Evidence
-	err = app.DB.QueryRow("SELECT id, title, description, file_name, file_path, file_size, file_type, gap_assessment_id, maturity_assessment_id, clause_reference, annex_reference, uploaded_by, uploaded_at, created_at, updated_at FROM evidence WHERE id = $1", id).
-		Scan(&item.ID, &item.Title, &item.Description, &item.FileName, &item.FilePath, &item.FileSize, &item.FileType, &item.GapAssessmentID, &item.MaturityAssessmentID, &item.ClauseReference, &item.AnnexReference, &item.UploadedBy, &item.UploadedAt, &item.CreatedAt, &item.UpdatedAt)
+	err = scanEvidence(app.DB.QueryRow("SELECT "+evidenceColumns+" FROM evidence WHERE id = $1", id), &item)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			http.Error(w, "Evidence not found", http.StatusNotFound)
@@ -595,15 +787,37 @@ func (app *App) createEvidence(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	// File fields, digests and revisions are only ever set by the server from an uploaded file
+	item.FileName, item.FilePath, item.FileType = "", "", ""
+	item.FileSize = nil
+	item.SHA256 = nil
+	item.CurrentVersionID = nil
 
-	err := app.DB.QueryRow(
-		"INSERT INTO evidence (title, description, file_name, file_path, file_size, file_type, gap_assessment_id, maturity_assessment_id, clause_reference, annex_reference, uploaded_by) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) RETURNING id, uploaded_at, created_at, updated_at",
-		item.Title, item.Description, item.FileName, item.FilePath, item.FileSize, item.FileType, item.GapAssessmentID, item.MaturityAssessmentID, item.ClauseReference, item.AnnexReference, item.UploadedBy,
+	tx, err := app.DB.Begin()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(
+		"INSERT INTO evidence (title, description, file_name, file_path, file_size, file_type, gap_assessment_id, maturity_assessment_id, clause_reference, annex_reference, uploaded_by, collected_at, valid_until) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13) RETURNING id, uploaded_at, created_at, updated_at",
+		item.Title, item.Description, item.FileName, item.FilePath, item.FileSize, item.FileType, item.GapAssessmentID, item.MaturityAssessmentID, item.ClauseReference, item.AnnexReference, item.UploadedBy, item.CollectedAt, item.ValidUntil,
 	).Scan(&item.ID, &item.UploadedAt, &item.CreatedAt, &item.UpdatedAt)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if item.GapAssessmentID != nil {
+		if err := linkEvidenceControl(tx, item.ID, *item.GapAssessmentID); err != nil {
+			writeCycleError(w, err, "Control not found")
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
@@ -624,10 +838,28 @@ func (app *App) updateEvidence(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = app.DB.QueryRow(
-		"UPDATE evidence SET title = $1, description = $2, file_name = $3, file_path = $4, file_size = $5, file_type = $6, gap_assessment_id = $7, maturity_assessment_id = $8, clause_reference = $9, annex_reference = $10, uploaded_by = $11 WHERE id = $12 RETURNING id, uploaded_at, created_at, updated_at",
-		item.Title, item.Description, item.FileName, item.FilePath, item.FileSize, item.FileType, item.GapAssessmentID, item.MaturityAssessmentID, item.ClauseReference, item.AnnexReference, item.UploadedBy, id,
-	).Scan(&item.ID, &item.UploadedAt, &item.CreatedAt, &item.UpdatedAt)
+	tx, err := app.DB.Begin()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	if err := checkPatchBase(tx, r, id); err != nil {
+		writePatchError(w, err, "Evidence not found")
+		return
+	}
+
+	// File fields belong to the uploaded file (or its current revision) and are
+	// only changed by uploading a new version, never overwritten in place.
+	err = tx.QueryRow(
+		`UPDATE evidence SET title = $1, description = $2,
+		   gap_assessment_id = $3, maturity_assessment_id = $4, clause_reference = $5, annex_reference = $6, uploaded_by = $7,
+		   collected_at = $8, valid_until = $9
+		 WHERE id = $10
+		 RETURNING id, file_name, file_path, file_size, file_type, sha256, current_version_id, uploaded_at, created_at, updated_at`,
+		item.Title, item.Description, item.GapAssessmentID, item.MaturityAssessmentID, item.ClauseReference, item.AnnexReference, item.UploadedBy, item.CollectedAt, item.ValidUntil, id,
+	).Scan(&item.ID, &item.FileName, &item.FilePath, &item.FileSize, &item.FileType, &item.SHA256, &item.CurrentVersionID, &item.UploadedAt, &item.CreatedAt, &item.UpdatedAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			http.Error(w, "Evidence not found", http.StatusNotFound)
@@ -636,6 +868,17 @@ func (app *App) updateEvidence(w http.ResponseWriter, r *http.Request) {
 		}
 		return
 	}
+	// The primary control is always among the linked controls; other links are kept
+	if item.GapAssessmentID != nil {
+		if err := linkEvidenceControl(tx, item.ID, *item.GapAssessmentID); err != nil {
+			writeCycleError(w, err, "Control not found")
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(item)
@@ -649,6 +892,21 @@ func (app *App) deleteEvidence(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Collect every stored revision before the row (and its versions) go away
+	var paths []string
+	rows, err := app.DB.Query("SELECT file_path FROM evidence_versions WHERE evidence_id = $1 UNION SELECT file_path FROM evidence WHERE id = $1 AND current_version_id IS NULL AND file_path <> ''", id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err == nil {
+			paths = append(paths, path)
+		}
+	}
+	rows.Close()
+
 	result, err := app.DB.Exec("DELETE FROM evidence WHERE id = $1", id)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -666,23 +924,36 @@ func (app *App) deleteEvidence(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Best-effort cleanup of the uploaded files
+	app.removeEvidenceFiles(id, paths)
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
 // Risk Register Handlers
+// riskColumns is the column list scanned by scanRisk.
+const riskColumns = "id, risk_id, title, description, category, likelihood, impact, impact_from_assets, risk_level, risk_score, residual_likelihood, residual_impact, residual_score, residual_level, control_effectiveness, current_controls, treatment_plan, treatment_option, treatment_status, owner, target_date, gap_assessment_id, frequency_min, frequency_most_likely, frequency_max, loss_min, loss_most_likely, loss_max, " + riskControlsText + ", created_at, updated_at"
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanRisk(row rowScanner, risk *RiskRegister) error {
+	return row.Scan(&risk.ID, &risk.RiskID, &risk.Title, &risk.Description, &risk.Category, &risk.Likelihood, &risk.Impact, &risk.ImpactFromAssets, &risk.RiskLevel, &risk.RiskScore, &risk.ResidualLikelihood, &risk.ResidualImpact, &risk.ResidualScore, &risk.ResidualLevel, &risk.ControlEffectiveness, &risk.CurrentControls, &risk.TreatmentPlan, &risk.TreatmentOption, &risk.TreatmentStatus, &risk.Owner, &risk.TargetDate, &risk.GapAssessmentID, &risk.FrequencyMin, &risk.FrequencyMostLikely, &risk.FrequencyMax, &risk.LossMin, &risk.LossMostLikely, &risk.LossMax, &risk.AnnexAControls, &risk.CreatedAt, &risk.UpdatedAt)
+}
+
 func (app *App) getRisks(w http.ResponseWriter, r *http.Request) {
-	rows, err := app.DB.Query("SELECT id, risk_id, title, description, category, likelihood, impact, risk_level, current_controls, treatment_plan, treatment_status, owner, target_date, gap_assessment_id, annex_a_controls, created_at, updated_at FROM risk_register ORDER BY risk_level DESC, created_at DESC")
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	rows := app.queryList(w, r, riskList, "SELECT "+riskColumns+" FROM risk_register")
+	if rows == nil {
 		return
 	}
 	defer rows.Close()
 
-	var risks []RiskRegister
+	risks := []RiskRegister{}
 	for rows.Next() {
 		var risk RiskRegister
-		err := rows.Scan(&risk.ID, &risk.RiskID, &risk.Title, &risk.Description, &risk.Category, &risk.Likelihood, &risk.Impact, &risk.RiskLevel, &risk.CurrentControls, &risk.TreatmentPlan, &risk.TreatmentStatus, &risk.Owner, &risk.TargetDate, &risk.GapAssessmentID, &risk.AnnexAControls, &risk.CreatedAt, &risk.UpdatedAt)
-		if err != nil {
+		if err := scanRisk(rows, &risk); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
@@ -702,8 +973,7 @@ func (app *App) getRisk(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var risk RiskRegister
-	err = app.DB.QueryRow("SELECT id, risk_id, title, description, category, likelihood, impact, risk_level, current_controls, treatment_plan, treatment_status, owner, target_date, gap_assessment_id, annex_a_controls, created_at, updated_at FROM risk_register WHERE id = $1", id).
-		Scan(&risk.ID, &risk.RiskID, &risk.Title, &risk.Description, &risk.Category, &risk.Likelihood, &risk.Impact, &risk.RiskLevel, &risk.CurrentControls, &risk.TreatmentPlan, &risk.TreatmentStatus, &risk.Owner, &risk.TargetDate, &risk.GapAssessmentID, &risk.AnnexAControls, &risk.CreatedAt, &risk.UpdatedAt)
+	err = scanRisk(app.DB.QueryRow("SELECT "+riskColumns+" FROM risk_register WHERE id = $1", id), &risk)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			http.Error(w, "Risk not found", http.StatusNotFound)
@@ -717,6 +987,25 @@ func (app *App) getRisk(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(risk)
 }
 
+// insertRisk assigns a scored risk its risk_id, stores it and links it to the
+// given controls
+func (app *App) insertRisk(tx *sql.Tx, matrix *RiskMatrix, risk *RiskRegister, controls []int) error {
+	if err := checkTreatmentStatus(risk, ""); err != nil {
+		return err
+	}
+	if err := app.assignRiskID(tx, risk); err != nil {
+		return err
+	}
+	err := tx.QueryRow(
+		"INSERT INTO risk_register (risk_id, title, description, category, likelihood, impact, impact_from_assets, risk_level, risk_score, current_controls, treatment_plan, treatment_option, treatment_status, owner, target_date, gap_assessment_id, frequency_min, frequency_most_likely, frequency_max, loss_min, loss_most_likely, loss_max) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22) RETURNING id, created_at, updated_at",
+		risk.RiskID, risk.Title, risk.Description, risk.Category, risk.Likelihood, risk.Impact, risk.ImpactFromAssets, risk.RiskLevel, risk.RiskScore, risk.CurrentControls, risk.TreatmentPlan, risk.TreatmentOption, risk.TreatmentStatus, risk.Owner, risk.TargetDate, risk.GapAssessmentID, risk.FrequencyMin, risk.FrequencyMostLikely, risk.FrequencyMax, risk.LossMin, risk.LossMostLikely, risk.LossMax,
+	).Scan(&risk.ID, &risk.CreatedAt, &risk.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	return saveRiskControls(tx, matrix, risk, controls)
+}
+
 func (app *App) createRisk(w http.ResponseWriter, r *http.Request) {
 	var risk RiskRegister
 	if err := json.NewDecoder(r.Body).Decode(&risk); err != nil {
@@ -724,14 +1013,45 @@ func (app *App) createRisk(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := app.DB.QueryRow(
-		"INSERT INTO risk_register (risk_id, title, description, category, likelihood, impact, risk_level, current_controls, treatment_plan, treatment_status, owner, target_date, gap_assessment_id, annex_a_controls) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14) RETURNING id, created_at, updated_at",
-		risk.RiskID, risk.Title, risk.Description, risk.Category, risk.Likelihood, risk.Impact, risk.RiskLevel, risk.CurrentControls, risk.TreatmentPlan, risk.TreatmentStatus, risk.Owner, risk.TargetDate, risk.GapAssessmentID, risk.AnnexAControls,
-	).Scan(&risk.ID, &risk.CreatedAt, &risk.UpdatedAt)
+	// Score and level are always derived from the risk matrix, never taken from the client
+	matrix, err := loadRiskMatrix(app.DB)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if err := scoreRisk(matrix, &risk); err != nil {
+		writeRiskError(w, err)
+		return
+	}
+	if err := normalizeTreatmentOption(&risk); err != nil {
+		writeRiskError(w, err)
+		return
+	}
+	if err := validateLossInputs(&risk); err != nil {
+		writeRiskError(w, err)
+		return
+	}
+	controls, err := resolveRiskControls(app.DB, &risk)
+	if err != nil {
+		writeRiskError(w, err)
+		return
+	}
+
+	tx, err := app.DB.Begin()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	if err := app.insertRisk(tx, matrix, &risk, controls); err != nil {
+		writeRiskSaveError(w, &risk, err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
@@ -746,22 +1066,119 @@ func (app *App) updateRisk(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var risk RiskRegister
-	if err := json.NewDecoder(r.Body).Decode(&risk); err != nil {
+	// A body without annex_a_controls leaves the risk's control links as they are
+	var body struct {
+		RiskRegister
+		AnnexAControls *string `json:"annex_a_controls"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	risk := body.RiskRegister
+	if body.AnnexAControls != nil {
+		risk.AnnexAControls = *body.AnnexAControls
+	}
 
-	err = app.DB.QueryRow(
-		"UPDATE risk_register SET risk_id = $1, title = $2, description = $3, category = $4, likelihood = $5, impact = $6, risk_level = $7, current_controls = $8, treatment_plan = $9, treatment_status = $10, owner = $11, target_date = $12, gap_assessment_id = $13, annex_a_controls = $14 WHERE id = $15 RETURNING id, created_at, updated_at",
-		risk.RiskID, risk.Title, risk.Description, risk.Category, risk.Likelihood, risk.Impact, risk.RiskLevel, risk.CurrentControls, risk.TreatmentPlan, risk.TreatmentStatus, risk.Owner, risk.TargetDate, risk.GapAssessmentID, risk.AnnexAControls, id,
+	matrix, err := loadRiskMatrix(app.DB)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	risk.ID = id
+	if err := applyAssetImpact(app.DB, matrix, &risk); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := scoreRisk(matrix, &risk); err != nil {
+		writeRiskError(w, err)
+		return
+	}
+	if err := normalizeTreatmentOption(&risk); err != nil {
+		writeRiskError(w, err)
+		return
+	}
+	if err := validateLossInputs(&risk); err != nil {
+		writeRiskError(w, err)
+		return
+	}
+	var controls []int
+	if body.AnnexAControls != nil {
+		if controls, err = resolveRiskControls(app.DB, &risk); err != nil {
+			writeRiskError(w, err)
+			return
+		}
+	}
+
+	// A risk keeps its identifier unless clients may choose their own
+	risk.RiskID = strings.TrimSpace(risk.RiskID)
+	if risk.RiskID == "" || !app.RiskIDs.AllowClient {
+		var current string
+		err = app.DB.QueryRow("SELECT risk_id FROM risk_register WHERE id = $1", id).Scan(&current)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				http.Error(w, "Risk not found", http.StatusNotFound)
+			} else {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+		if risk.RiskID != "" && risk.RiskID != current {
+			http.Error(w, "risk_id is assigned by the server and cannot be changed", http.StatusBadRequest)
+			return
+		}
+		risk.RiskID = current
+	}
+
+	tx, err := app.DB.Begin()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	if err := checkPatchBase(tx, r, id); err != nil {
+		writePatchError(w, err, "Risk not found")
+		return
+	}
+	var status string
+	if err := tx.QueryRow("SELECT treatment_status FROM risk_register WHERE id = $1", id).Scan(&status); err != nil {
+		writeCycleError(w, err, "Risk not found")
+		return
+	}
+	if err := checkTreatmentStatus(&risk, status); err != nil {
+		writeRiskError(w, err)
+		return
+	}
+
+	err = tx.QueryRow(
+		"UPDATE risk_register SET risk_id = $1, title = $2, description = $3, category = $4, likelihood = $5, impact = $6, impact_from_assets = $7, risk_level = $8, risk_score = $9, current_controls = $10, treatment_plan = $11, treatment_option = $12, treatment_status = $13, owner = $14, target_date = $15, gap_assessment_id = $16, frequency_min = $17, frequency_most_likely = $18, frequency_max = $19, loss_min = $20, loss_most_likely = $21, loss_max = $22 WHERE id = $23 RETURNING id, created_at, updated_at",
+		risk.RiskID, risk.Title, risk.Description, risk.Category, risk.Likelihood, risk.Impact, risk.ImpactFromAssets, risk.RiskLevel, risk.RiskScore, risk.CurrentControls, risk.TreatmentPlan, risk.TreatmentOption, risk.TreatmentStatus, risk.Owner, risk.TargetDate, risk.GapAssessmentID, risk.FrequencyMin, risk.FrequencyMostLikely, risk.FrequencyMax, risk.LossMin, risk.LossMostLikely, risk.LossMax, id,
 	).Scan(&risk.ID, &risk.CreatedAt, &risk.UpdatedAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			http.Error(w, "Risk not found", http.StatusNotFound)
 		} else {
+			writeRiskSaveError(w, &risk, err)
+		}
+		return
+	}
+	if err := cancelPendingAcceptances(tx, &risk); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if body.AnnexAControls == nil {
+		if controls, err = linkedRiskControls(tx, id, risk.GapAssessmentID); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
 		}
+	}
+	if err := saveRiskControls(tx, matrix, &risk, controls); err != nil {
+		writeRiskSaveError(w, &risk, err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -801,7 +1218,29 @@ func (app *App) deleteRisk(w http.ResponseWriter, r *http.Request) {
 func (app *App) generateClauseDocument(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	clauseRef := vars["clause"]
+
+	cycle, err := resolveCycle(app.DB, r.URL.Query().Get("cycle"))
+	if err != nil {
+		writeRiskError(w, err)
+		return
+	}
+	assessmentData, err := app.loadClauseData(clauseRef, cycle.ID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 	
+	document := generateClauseDocument(clauseRef, assessmentData)
+	
+	w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"ISO27001-Clause-%s.md\"", clauseRef))
+	w.Write([]byte(document))
+}
+
+// loadClauseData collects the gap and maturity assessment of an assessment
+// cycle for a clause or control together with its linked action items,
+// evidence and risks.
+func (app *App) loadClauseData(clauseRef string, cycleID int) (map[string]interface{}, error) {
 	assessmentData := make(map[string]interface{})
 
 	// Prefer exact standard_ref matches first to avoid collisions like 6.1 matching 6.1.1/6.1.2/6.1.3
@@ -816,7 +1255,7 @@ func (app *App) generateClauseDocument(w http.ResponseWriter, r *http.Request) {
 	err := app.DB.QueryRow(
 		`SELECT id, category, section, standard_ref, assessment_question, compliance, notes, target_date
 		 FROM gap_assessments
-		 WHERE standard_ref = $1 OR standard_ref = $2 OR standard_ref ILIKE $3
+		 WHERE cycle_id = $4 AND (standard_ref = $1 OR standard_ref = $2 OR standard_ref ILIKE $3)
 		 ORDER BY
 		   CASE
 		     WHEN standard_ref = $1 THEN 0
@@ -826,11 +1265,10 @@ func (app *App) generateClauseDocument(w http.ResponseWriter, r *http.Request) {
 		   END,
 		   length(standard_ref) ASC
 		 LIMIT 1`,
-		exactDash, exactSpace, like,
+		exactDash, exactSpace, like, cycleID,
 	).Scan(&gapID, &category, &section, &standardRef, &question, &compliance, &notes, &targetDate)
 	if err != nil && err != sql.ErrNoRows {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		return nil, err
 	}
 
 	if standardRef.Valid {
@@ -847,6 +1285,13 @@ func (app *App) generateClauseDocument(w http.ResponseWriter, r *http.Request) {
 	}
 	if compliance.Valid {
 		assessmentData["compliance"] = compliance.String
+		scale, err := loadComplianceScale(app.DB)
+		if err != nil {
+			return nil, err
+		}
+		if level, ok := scale.level(compliance.String); ok {
+			assessmentData["implementation_status"] = level.SoAStatus
+		}
 	}
 	if notes.Valid {
 		assessmentData["notes"] = notes.String
@@ -865,7 +1310,7 @@ func (app *App) generateClauseDocument(w http.ResponseWriter, r *http.Request) {
 			        current_maturity_level, current_maturity_score, current_maturity_comments,
 			        target_maturity_level, target_maturity_score, target_maturity_comments
 			 FROM maturity_assessments
-			 WHERE standard_ref = $1 OR standard_ref = $2 OR standard_ref ILIKE $3
+			 WHERE cycle_id = $4 AND (standard_ref = $1 OR standard_ref = $2 OR standard_ref ILIKE $3)
 			 ORDER BY
 			   CASE
 			     WHEN standard_ref = $1 THEN 0
@@ -875,11 +1320,10 @@ func (app *App) generateClauseDocument(w http.ResponseWriter, r *http.Request) {
 			   END,
 			   length(standard_ref) ASC
 			 LIMIT 1`,
-			exactDash, exactSpace, like,
+			exactDash, exactSpace, like, cycleID,
 		).Scan(&mRef, &mQuestion, &currentLevel, &currentScore, &currentComments, &targetLevel, &targetScore, &targetComments)
 		if err != nil && err != sql.ErrNoRows {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
+			return nil, err
 		}
 
 		if currentLevel.Valid {
@@ -917,8 +1361,7 @@ func (app *App) generateClauseDocument(w http.ResponseWriter, r *http.Request) {
 				gapAssessmentID, like,
 			)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		return nil, err
 	}
 	defer rows.Close()
 	
@@ -950,15 +1393,15 @@ func (app *App) generateClauseDocument(w http.ResponseWriter, r *http.Request) {
 		// Evidence
 		{
 			rows, err := app.DB.Query(
-				`SELECT id, title, file_name, file_type, uploaded_by, uploaded_at
-				 FROM evidence
-				 WHERE gap_assessment_id = $1 OR clause_reference ILIKE $2
-				 ORDER BY uploaded_at DESC`,
-				gapAssessmentID, like,
+				`SELECT e.id, e.title, e.file_name, e.file_type, e.uploaded_by, e.uploaded_at
+				 FROM evidence e
+				 JOIN evidence_controls ec ON ec.evidence_id = e.id
+				 WHERE ec.gap_assessment_id = $1
+				 ORDER BY e.uploaded_at DESC`,
+				gapAssessmentID,
 			)
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
+				return nil, err
 			}
 			defer rows.Close()
 
@@ -987,15 +1430,15 @@ func (app *App) generateClauseDocument(w http.ResponseWriter, r *http.Request) {
 		// Risks
 		{
 			rows, err := app.DB.Query(
-				`SELECT risk_id, title, risk_level, treatment_status, owner, target_date
-				 FROM risk_register
-				 WHERE gap_assessment_id = $1
-				 ORDER BY risk_level DESC, created_at DESC`,
+				`SELECT r.risk_id, r.title, r.risk_level, r.treatment_status, r.owner, r.target_date
+				 FROM risk_register r
+				 JOIN risk_controls rc ON rc.risk_register_id = r.id
+				 WHERE rc.gap_assessment_id = $1
+				 ORDER BY r.risk_score DESC NULLS LAST, r.created_at DESC`,
 				gapAssessmentID,
 			)
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
+				return nil, err
 			}
 			defer rows.Close()
 
@@ -1023,16 +1466,17 @@ func (app *App) generateClauseDocument(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 	}
-	
-	document := generateClauseDocument(clauseRef, assessmentData)
-	
-	w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
-	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"ISO27001-Clause-%s.md\"", clauseRef))
-	w.Write([]byte(document))
+
+	return assessmentData, nil
 }
 
 func (app *App) generateSoA(w http.ResponseWriter, r *http.Request) {
-	rows, err := app.DB.Query("SELECT standard_ref, assessment_question, compliance, notes FROM gap_assessments ORDER BY standard_ref")
+	cycle, err := resolveCycle(app.DB, r.URL.Query().Get("cycle"))
+	if err != nil {
+		writeRiskError(w, err)
+		return
+	}
+	rows, err := app.DB.Query("SELECT standard_ref, assessment_question, compliance, notes FROM gap_assessments WHERE cycle_id = $1 ORDER BY standard_ref", cycle.ID)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -1053,7 +1497,12 @@ func (app *App) generateSoA(w http.ResponseWriter, r *http.Request) {
 		})
 	}
 	
-	soa := generateSoA(assessments)
+	scale, err := loadComplianceScale(app.DB)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	soa := generateSoA(assessments, scale)
 	
 	w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
 	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"ISO27001-Statement-of-Applicability-%s.md\"", time.Now().Format("2006-01-02")))
@@ -1061,14 +1510,26 @@ func (app *App) generateSoA(w http.ResponseWriter, r *http.Request) {
 }
 
 func (app *App) generateNotionExport(w http.ResponseWriter, r *http.Request) {
+	cycle, err := resolveCycle(app.DB, r.URL.Query().Get("cycle"))
+	if err != nil {
+		writeRiskError(w, err)
+		return
+	}
+
 	// Get gap assessments
-	gapRows, err := app.DB.Query("SELECT standard_ref, assessment_question, compliance, notes FROM gap_assessments ORDER BY standard_ref")
+	gapRows, err := app.DB.Query("SELECT standard_ref, assessment_question, compliance, notes FROM gap_assessments WHERE cycle_id = $1 ORDER BY standard_ref", cycle.ID)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 	defer gapRows.Close()
 	
+	scale, err := loadComplianceScale(app.DB)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
 	var gapAssessments []map[string]interface{}
 	for gapRows.Next() {
 		var ref, question, compliance, notes string
@@ -1076,15 +1537,16 @@ func (app *App) generateNotionExport(w http.ResponseWriter, r *http.Request) {
 			continue
 		}
 		gapAssessments = append(gapAssessments, map[string]interface{}{
-			"standard_ref":        ref,
-			"assessment_question": question,
-			"compliance":          compliance,
-			"notes":               notes,
+			"standard_ref":          ref,
+			"assessment_question":   question,
+			"compliance":            compliance,
+			"implementation_status": scale.soaStatus(compliance),
+			"notes":                 notes,
 		})
 	}
 	
 	// Get maturity assessments
-	matRows, err := app.DB.Query("SELECT standard_ref, assessment_question, current_maturity_level, target_maturity_level FROM maturity_assessments ORDER BY standard_ref")
+	matRows, err := app.DB.Query("SELECT standard_ref, assessment_question, current_maturity_level, target_maturity_level FROM maturity_assessments WHERE cycle_id = $1 ORDER BY standard_ref", cycle.ID)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -1180,7 +1642,22 @@ func main() {
 	}
 	defer db.Close()
 
-	app := &App{DB: db}
+	storage, err := newStorageFromEnv()
+	if err != nil {
+		log.Fatal("Failed to configure evidence storage:", err)
+	}
+
+	uploads, err := newUploadPolicyFromEnv()
+	if err != nil {
+		log.Fatal("Failed to configure upload policy:", err)
+	}
+
+	riskIDs, err := newRiskIDPolicyFromEnv()
+	if err != nil {
+		log.Fatal("Failed to configure risk IDs:", err)
+	}
+
+	app := &App{DB: db, Storage: storage, Uploads: uploads, RiskIDs: riskIDs}
 
 	// Initialize database (create tables and seed data)
 	if err := app.InitializeDB(); err != nil {
@@ -1193,43 +1670,110 @@ func main() {
 	// Gap Assessment routes
 	r.HandleFunc("/api/gap-assessments", app.getGapAssessments).Methods("GET")
 	r.HandleFunc("/api/gap-assessments", app.createGapAssessment).Methods("POST")
+	r.HandleFunc("/api/gap-assessments/bulk", app.bulkGapAssessments).Methods("POST")
 	r.HandleFunc("/api/gap-assessments/{id}", app.getGapAssessment).Methods("GET")
 	r.HandleFunc("/api/gap-assessments/{id}", app.updateGapAssessment).Methods("PUT")
+	r.HandleFunc("/api/gap-assessments/{id}", app.patchGapAssessment).Methods("PATCH")
 	r.HandleFunc("/api/gap-assessments/{id}", app.deleteGapAssessment).Methods("DELETE")
+	r.HandleFunc("/api/gap-assessments/{id}/evidence", app.getControlEvidence).Methods("GET")
+	r.HandleFunc("/api/gap-assessments/{id}/risks", app.getControlRisks).Methods("GET")
+	r.HandleFunc("/api/compliance-scale", app.getComplianceScale).Methods("GET")
+	r.HandleFunc("/api/compliance-scale", app.updateComplianceScale).Methods("PUT")
+
+	// Assessment Cycle routes
+	r.HandleFunc("/api/assessment-cycles", app.getAssessmentCycles).Methods("GET")
+	r.HandleFunc("/api/assessment-cycles", app.createAssessmentCycle).Methods("POST")
+	r.HandleFunc("/api/assessment-cycles/{id}", app.getAssessmentCycle).Methods("GET")
+	r.HandleFunc("/api/assessment-cycles/{id}/close", app.closeAssessmentCycle).Methods("POST")
 
 	// Maturity Assessment routes
 	r.HandleFunc("/api/maturity-assessments", app.getMaturityAssessments).Methods("GET")
 	r.HandleFunc("/api/maturity-assessments", app.createMaturityAssessment).Methods("POST")
 	r.HandleFunc("/api/maturity-assessments/{id}", app.getMaturityAssessment).Methods("GET")
 	r.HandleFunc("/api/maturity-assessments/{id}", app.updateMaturityAssessment).Methods("PUT")
+	r.HandleFunc("/api/maturity-assessments/{id}", app.patchMaturityAssessment).Methods("PATCH")
 	r.HandleFunc("/api/maturity-assessments/{id}", app.deleteMaturityAssessment).Methods("DELETE")
 
 	// Action Items routes
 	r.HandleFunc("/api/action-items", app.getActionItems).Methods("GET")
 	r.HandleFunc("/api/action-items", app.createActionItem).Methods("POST")
+	r.HandleFunc("/api/action-items/upload", app.uploadActionItem).Methods("POST")
+	r.HandleFunc("/api/action-items/bulk", app.bulkActionItems).Methods("POST")
 	r.HandleFunc("/api/action-items/{id}", app.getActionItem).Methods("GET")
 	r.HandleFunc("/api/action-items/{id}", app.updateActionItem).Methods("PUT")
+	r.HandleFunc("/api/action-items/{id}", app.patchActionItem).Methods("PATCH")
 	r.HandleFunc("/api/action-items/{id}", app.deleteActionItem).Methods("DELETE")
+	r.HandleFunc("/api/action-items/{id}/file", app.downloadActionItemFile).Methods("GET")
 
 	// Evidence routes
 	r.HandleFunc("/api/evidence", app.getEvidence).Methods("GET")
 	r.HandleFunc("/api/evidence", app.createEvidence).Methods("POST")
+	r.HandleFunc("/api/evidence/upload", app.uploadEvidence).Methods("POST")
+	r.HandleFunc("/api/evidence/verify", app.verifyEvidence).Methods("POST")
+	r.HandleFunc("/api/evidence/stale", app.getStaleEvidence).Methods("GET")
+	r.HandleFunc("/api/evidence/search", app.searchEvidence).Methods("GET")
+	r.HandleFunc("/api/evidence/reindex", app.reindexEvidenceText).Methods("POST")
 	r.HandleFunc("/api/evidence/{id}", app.getEvidenceItem).Methods("GET")
 	r.HandleFunc("/api/evidence/{id}", app.updateEvidence).Methods("PUT")
+	r.HandleFunc("/api/evidence/{id}", app.patchEvidence).Methods("PATCH")
 	r.HandleFunc("/api/evidence/{id}", app.deleteEvidence).Methods("DELETE")
+	r.HandleFunc("/api/evidence/{id}/file", app.downloadEvidenceFile).Methods("GET")
+	r.HandleFunc("/api/evidence/{id}/versions", app.getEvidenceVersions).Methods("GET")
+	r.HandleFunc("/api/evidence/{id}/versions", app.uploadEvidenceVersion).Methods("POST")
+	r.HandleFunc("/api/evidence/{id}/versions/{version}/file", app.downloadEvidenceVersionFile).Methods("GET")
+	r.HandleFunc("/api/evidence/{id}/versions/{version}/current", app.setCurrentEvidenceVersionHandler).Methods("PUT")
+	r.HandleFunc("/api/evidence/{id}/controls", app.getEvidenceControls).Methods("GET")
+	r.HandleFunc("/api/evidence/{id}/controls", app.attachEvidenceControl).Methods("POST")
+	r.HandleFunc("/api/evidence/{id}/controls/{gapId}", app.detachEvidenceControl).Methods("DELETE")
 
 	// Risk Register routes
 	r.HandleFunc("/api/risks", app.getRisks).Methods("GET")
 	r.HandleFunc("/api/risks", app.createRisk).Methods("POST")
+	r.HandleFunc("/api/risks/uncontrolled", app.getUncontrolledRisks).Methods("GET")
+	r.HandleFunc("/api/risks/heatmap", app.getRiskHeatmap).Methods("GET")
+	r.HandleFunc("/api/risks/from-catalogue", app.createRiskFromCatalogue).Methods("POST")
+	r.HandleFunc("/api/risks/appetite-breaches", app.getRiskAppetiteBreaches).Methods("GET")
+	r.HandleFunc("/api/risks/loss-simulation", app.getLossSimulation).Methods("GET")
 	r.HandleFunc("/api/risks/{id}", app.getRisk).Methods("GET")
 	r.HandleFunc("/api/risks/{id}", app.updateRisk).Methods("PUT")
+	r.HandleFunc("/api/risks/{id}", app.patchRisk).Methods("PATCH")
 	r.HandleFunc("/api/risks/{id}", app.deleteRisk).Methods("DELETE")
+	r.HandleFunc("/api/risks/{id}/controls", app.getRiskControls).Methods("GET")
+	r.HandleFunc("/api/risks/{id}/controls", app.attachRiskControl).Methods("POST")
+	r.HandleFunc("/api/risks/{id}/controls/{gapId}", app.detachRiskControl).Methods("DELETE")
+	r.HandleFunc("/api/risks/{id}/assets", app.getRiskAssets).Methods("GET")
+	r.HandleFunc("/api/risks/{id}/assets", app.attachRiskAsset).Methods("POST")
+	r.HandleFunc("/api/risks/{id}/assets/{assetId}", app.detachRiskAsset).Methods("DELETE")
+	r.HandleFunc("/api/risks/{id}/acceptances", app.getRiskAcceptances).Methods("GET")
+	r.HandleFunc("/api/risks/{id}/acceptances", app.requestRiskAcceptance).Methods("POST")
+	r.HandleFunc("/api/risk-acceptances/pending", app.getPendingRiskAcceptances).Methods("GET")
+	r.HandleFunc("/api/risk-acceptances/expired", app.getExpiredRiskAcceptances).Methods("GET")
+	r.HandleFunc("/api/risk-acceptances/{id}/decision", app.decideRiskAcceptance).Methods("POST")
+	r.HandleFunc("/api/risk-matrix", app.getRiskMatrix).Methods("GET")
+	r.HandleFunc("/api/risk-matrix", app.updateRiskMatrix).Methods("PUT")
+	r.HandleFunc("/api/reports/risk-reduction", app.getRiskReductionReport).Methods("GET")
+	r.HandleFunc("/api/risk-appetite", app.getRiskAppetite).Methods("GET")
+	r.HandleFunc("/api/risk-appetite", app.updateRiskAppetite).Methods("PUT")
+
+	// Asset inventory routes
+	r.HandleFunc("/api/assets", app.getAssets).Methods("GET")
+	r.HandleFunc("/api/assets", app.createAsset).Methods("POST")
+	r.HandleFunc("/api/assets/import", app.importAssets).Methods("POST")
+	r.HandleFunc("/api/assets/{id}", app.getAsset).Methods("GET")
+	r.HandleFunc("/api/assets/{id}", app.updateAsset).Methods("PUT")
+	r.HandleFunc("/api/assets/{id}", app.deleteAsset).Methods("DELETE")
+	r.HandleFunc("/api/assets/{id}/risks", app.getAssetRisks).Methods("GET")
+
+	// Threat and vulnerability catalogue routes
+	r.HandleFunc("/api/catalogue/threats", app.getThreats).Methods("GET")
+	r.HandleFunc("/api/catalogue/vulnerabilities", app.getVulnerabilities).Methods("GET")
 
 	// Document Generation routes
 	r.HandleFunc("/api/generate/clause/{clause}", app.generateClauseDocument).Methods("GET")
 	r.HandleFunc("/api/generate/soa", app.generateSoA).Methods("GET")
 	r.HandleFunc("/api/generate/notion-export", app.generateNotionExport).Methods("GET")
 	r.HandleFunc("/api/templates/clauses", app.getAvailableClauses).Methods("GET")
+	r.HandleFunc("/api/export/audit-pack", app.exportAuditPack).Methods("GET")
 
 	// Health check
 	r.HandleFunc("/api/health", func(w http.ResponseWriter, r *http.Request) {
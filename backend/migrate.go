@@ -1,8 +1,10 @@
 package main
 
 import (
+	"database/sql"
 	"fmt"
 	"log"
+	"strings"
 )
 
 // createTables creates all database tables if they don't exist
@@ -120,7 +122,6 @@ func (app *App) createTables() error {
 			owner VARCHAR(255) NOT NULL,
 			target_date DATE,
 			gap_assessment_id INTEGER REFERENCES gap_assessments(id),
-			annex_a_controls TEXT,
 			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 		)
@@ -129,6 +130,393 @@ func (app *App) createTables() error {
 		return fmt.Errorf("error creating risk_register table: %v", err)
 	}
 
+	// Add integrity digests for uploaded files
+	_, err = app.DB.Exec(`
+		ALTER TABLE evidence ADD COLUMN IF NOT EXISTS sha256 VARCHAR(64);
+		ALTER TABLE action_items ADD COLUMN IF NOT EXISTS sha256 VARCHAR(64);
+	`)
+	if err != nil {
+		return fmt.Errorf("error adding sha256 columns: %v", err)
+	}
+
+	// Create evidence_versions table (immutable file revisions)
+	_, err = app.DB.Exec(`
+		CREATE TABLE IF NOT EXISTS evidence_versions (
+			id SERIAL PRIMARY KEY,
+			evidence_id INTEGER NOT NULL REFERENCES evidence(id) ON DELETE CASCADE,
+			version_number INTEGER NOT NULL,
+			file_name VARCHAR(255) NOT NULL,
+			file_path TEXT NOT NULL,
+			file_size INTEGER,
+			file_type VARCHAR(100) NOT NULL,
+			sha256 VARCHAR(64),
+			uploaded_by VARCHAR(255) NOT NULL,
+			change_note TEXT,
+			uploaded_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (evidence_id, version_number)
+		);
+		ALTER TABLE evidence ADD COLUMN IF NOT EXISTS current_version_id INTEGER REFERENCES evidence_versions(id) ON DELETE SET NULL;
+		CREATE INDEX IF NOT EXISTS idx_evidence_versions_evidence ON evidence_versions(evidence_id);
+	`)
+	if err != nil {
+		return fmt.Errorf("error creating evidence_versions table: %v", err)
+	}
+
+	// Turn files uploaded before versioning existed into revision 1
+	_, err = app.DB.Exec(`
+		INSERT INTO evidence_versions (evidence_id, version_number, file_name, file_path, file_size, file_type, sha256, uploaded_by, uploaded_at)
+		SELECT e.id, 1, e.file_name, e.file_path, e.file_size, e.file_type, e.sha256, e.uploaded_by, e.uploaded_at
+		FROM evidence e
+		WHERE e.sha256 IS NOT NULL AND e.current_version_id IS NULL
+		  AND NOT EXISTS (SELECT 1 FROM evidence_versions v WHERE v.evidence_id = e.id);
+		UPDATE evidence e SET current_version_id = v.id
+		FROM evidence_versions v
+		WHERE v.evidence_id = e.id AND e.current_version_id IS NULL
+		  AND v.version_number = (SELECT MAX(version_number) FROM evidence_versions WHERE evidence_id = e.id);
+	`)
+	if err != nil {
+		return fmt.Errorf("error backfilling evidence_versions: %v", err)
+	}
+
+	// Add evidence validity windows and per-control refresh intervals
+	_, err = app.DB.Exec(`
+		ALTER TABLE evidence ADD COLUMN IF NOT EXISTS collected_at DATE;
+		ALTER TABLE evidence ADD COLUMN IF NOT EXISTS valid_until DATE;
+		ALTER TABLE gap_assessments ADD COLUMN IF NOT EXISTS evidence_refresh_days INTEGER;
+	`)
+	if err != nil {
+		return fmt.Errorf("error adding evidence freshness columns: %v", err)
+	}
+
+	// Create evidence_controls link table (one evidence item supports many controls).
+	// Existing single-control links are copied across only when the table is first
+	// created, so links removed later are not brought back on restart.
+	var hadEvidenceControls bool
+	if err := app.DB.QueryRow("SELECT to_regclass('evidence_controls') IS NOT NULL").Scan(&hadEvidenceControls); err != nil {
+		return fmt.Errorf("error checking evidence_controls table: %v", err)
+	}
+	_, err = app.DB.Exec(`
+		CREATE TABLE IF NOT EXISTS evidence_controls (
+			evidence_id INTEGER NOT NULL REFERENCES evidence(id) ON DELETE CASCADE,
+			gap_assessment_id INTEGER NOT NULL REFERENCES gap_assessments(id) ON DELETE CASCADE,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (evidence_id, gap_assessment_id)
+		);
+		CREATE INDEX IF NOT EXISTS idx_evidence_controls_gap_id ON evidence_controls(gap_assessment_id);
+	`)
+	if err != nil {
+		return fmt.Errorf("error creating evidence_controls table: %v", err)
+	}
+	if !hadEvidenceControls {
+		_, err = app.DB.Exec(`
+			INSERT INTO evidence_controls (evidence_id, gap_assessment_id)
+			SELECT id, gap_assessment_id FROM evidence WHERE gap_assessment_id IS NOT NULL
+			ON CONFLICT DO NOTHING;
+			INSERT INTO evidence_controls (evidence_id, gap_assessment_id)
+			SELECT e.id, g.id
+			FROM evidence e
+			JOIN gap_assessments g ON g.standard_ref IN (
+				TRIM(e.clause_reference), 'Clause-' || TRIM(e.clause_reference), 'Clause ' || TRIM(e.clause_reference),
+				TRIM(e.annex_reference), 'Control-' || TRIM(e.annex_reference))
+			ON CONFLICT DO NOTHING;
+		`)
+		if err != nil {
+			return fmt.Errorf("error backfilling evidence_controls: %v", err)
+		}
+	}
+
+	// Add extracted file text and a full-text search vector to evidence
+	_, err = app.DB.Exec(`
+		ALTER TABLE evidence_versions ADD COLUMN IF NOT EXISTS content_text TEXT;
+		ALTER TABLE evidence ADD COLUMN IF NOT EXISTS content_text TEXT;
+		ALTER TABLE evidence ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+			setweight(to_tsvector('english', COALESCE(title, '')), 'A') ||
+			setweight(to_tsvector('english', COALESCE(description, '')), 'B') ||
+			setweight(to_tsvector('english', COALESCE(content_text, '')), 'C')
+		) STORED;
+		CREATE INDEX IF NOT EXISTS idx_evidence_search_vector ON evidence USING GIN (search_vector);
+	`)
+	if err != nil {
+		return fmt.Errorf("error adding evidence search columns: %v", err)
+	}
+
+	// Create risk_matrix table (single row holding the likelihood × impact matrix)
+	// and store a numeric score on each risk
+	_, err = app.DB.Exec(`
+		CREATE TABLE IF NOT EXISTS risk_matrix (
+			id INTEGER PRIMARY KEY DEFAULT 1 CHECK (id = 1),
+			config JSONB NOT NULL,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		);
+		ALTER TABLE risk_register ADD COLUMN IF NOT EXISTS risk_score INTEGER;
+		CREATE INDEX IF NOT EXISTS idx_risk_register_score ON risk_register(risk_score);
+	`)
+	if err != nil {
+		return fmt.Errorf("error creating risk_matrix table: %v", err)
+	}
+
+	// Add residual (after controls) rating columns to risk_register
+	_, err = app.DB.Exec(`
+		ALTER TABLE risk_register ADD COLUMN IF NOT EXISTS residual_likelihood VARCHAR(50);
+		ALTER TABLE risk_register ADD COLUMN IF NOT EXISTS residual_impact VARCHAR(50);
+		ALTER TABLE risk_register ADD COLUMN IF NOT EXISTS residual_score INTEGER;
+		ALTER TABLE risk_register ADD COLUMN IF NOT EXISTS residual_level VARCHAR(50);
+		ALTER TABLE risk_register ADD COLUMN IF NOT EXISTS control_effectiveness REAL;
+	`)
+	if err != nil {
+		return fmt.Errorf("error adding residual risk columns: %v", err)
+	}
+	// Create risk_controls link table (many risks to many controls), replacing
+	// the free-text annex_a_controls column. Existing links are parsed out of
+	// that column once, before it is dropped.
+	var hasAnnexText bool
+	err = app.DB.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM information_schema.columns
+			WHERE table_name = 'risk_register' AND column_name = 'annex_a_controls'
+		)
+	`).Scan(&hasAnnexText)
+	if err != nil {
+		return fmt.Errorf("error checking annex_a_controls column: %v", err)
+	}
+	var hadRiskControls bool
+	if err := app.DB.QueryRow("SELECT to_regclass('risk_controls') IS NOT NULL").Scan(&hadRiskControls); err != nil {
+		return fmt.Errorf("error checking risk_controls table: %v", err)
+	}
+	_, err = app.DB.Exec(`
+		CREATE TABLE IF NOT EXISTS risk_controls (
+			risk_register_id INTEGER NOT NULL REFERENCES risk_register(id) ON DELETE CASCADE,
+			gap_assessment_id INTEGER NOT NULL REFERENCES gap_assessments(id) ON DELETE CASCADE,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (risk_register_id, gap_assessment_id)
+		);
+		CREATE INDEX IF NOT EXISTS idx_risk_controls_gap_id ON risk_controls(gap_assessment_id);
+	`)
+	if err != nil {
+		return fmt.Errorf("error creating risk_controls table: %v", err)
+	}
+	if !hadRiskControls {
+		_, err = app.DB.Exec(`
+			INSERT INTO risk_controls (risk_register_id, gap_assessment_id)
+			SELECT id, gap_assessment_id FROM risk_register WHERE gap_assessment_id IS NOT NULL
+			ON CONFLICT DO NOTHING
+		`)
+		if err != nil {
+			return fmt.Errorf("error backfilling risk_controls: %v", err)
+		}
+	}
+	if hasAnnexText {
+		if err := app.migrateAnnexAControls(); err != nil {
+			return fmt.Errorf("error migrating annex_a_controls: %v", err)
+		}
+	}
+
+	// Add structured treatment options and the risk acceptance workflow
+	var hasTreatmentOption bool
+	err = app.DB.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM information_schema.columns
+			WHERE table_name = 'risk_register' AND column_name = 'treatment_option'
+		)
+	`).Scan(&hasTreatmentOption)
+	if err != nil {
+		return fmt.Errorf("error checking treatment_option column: %v", err)
+	}
+	_, err = app.DB.Exec(`
+		ALTER TABLE risk_register ADD COLUMN IF NOT EXISTS treatment_option VARCHAR(20)
+			CHECK (treatment_option IN ('modify', 'retain', 'avoid', 'share'));
+		CREATE TABLE IF NOT EXISTS risk_acceptances (
+			id SERIAL PRIMARY KEY,
+			risk_register_id INTEGER NOT NULL REFERENCES risk_register(id) ON DELETE CASCADE,
+			status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'rejected', 'cancelled')),
+			requested_by VARCHAR(255),
+			requested_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			rationale TEXT NOT NULL,
+			expires_at DATE NOT NULL,
+			approver VARCHAR(255),
+			decided_at TIMESTAMP,
+			decision_note TEXT
+		);
+		CREATE INDEX IF NOT EXISTS idx_risk_acceptances_risk_id ON risk_acceptances(risk_register_id);
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_risk_acceptances_one_pending ON risk_acceptances(risk_register_id) WHERE status = 'pending';
+		-- Tables created before pending requests could be cancelled
+		ALTER TABLE risk_acceptances DROP CONSTRAINT IF EXISTS risk_acceptances_status_check;
+		ALTER TABLE risk_acceptances ADD CONSTRAINT risk_acceptances_status_check CHECK (status IN ('pending', 'approved', 'rejected', 'cancelled'));
+	`)
+	if err != nil {
+		return fmt.Errorf("error creating risk_acceptances table: %v", err)
+	}
+	if !hasTreatmentOption {
+		// Derive the option from the free-text status the UI used before
+		_, err = app.DB.Exec(`
+			UPDATE risk_register SET treatment_option = CASE
+				WHEN treatment_status = 'Accepted' THEN 'retain'
+				WHEN treatment_status = 'Transferred' THEN 'share'
+				WHEN treatment_status IN ('In Progress', 'Mitigated') THEN 'modify'
+			END
+			WHERE treatment_option IS NULL
+		`)
+		if err != nil {
+			return fmt.Errorf("error backfilling treatment_option: %v", err)
+		}
+	}
+
+	// Create assets inventory and risk_assets link table; risks can derive
+	// their impact from the most valuable linked asset
+	_, err = app.DB.Exec(`
+		CREATE TABLE IF NOT EXISTS assets (
+			id SERIAL PRIMARY KEY,
+			asset_id VARCHAR(100) UNIQUE NOT NULL,
+			name VARCHAR(255) NOT NULL,
+			type VARCHAR(50) NOT NULL,
+			description TEXT,
+			owner VARCHAR(255),
+			location VARCHAR(255),
+			classification VARCHAR(50) NOT NULL,
+			confidentiality INTEGER NOT NULL CHECK (confidentiality BETWEEN 1 AND 5),
+			integrity INTEGER NOT NULL CHECK (integrity BETWEEN 1 AND 5),
+			availability INTEGER NOT NULL CHECK (availability BETWEEN 1 AND 5),
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_assets_asset_id_lower ON assets(LOWER(asset_id));
+		CREATE TABLE IF NOT EXISTS risk_assets (
+			risk_register_id INTEGER NOT NULL REFERENCES risk_register(id) ON DELETE CASCADE,
+			asset_id INTEGER NOT NULL REFERENCES assets(id) ON DELETE CASCADE,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (risk_register_id, asset_id)
+		);
+		CREATE INDEX IF NOT EXISTS idx_risk_assets_asset_id ON risk_assets(asset_id);
+		ALTER TABLE risk_register ADD COLUMN IF NOT EXISTS impact_from_assets BOOLEAN NOT NULL DEFAULT FALSE;
+	`)
+	if err != nil {
+		return fmt.Errorf("error creating assets tables: %v", err)
+	}
+
+	// Create the threat and vulnerability catalogue used to identify risks
+	_, err = app.DB.Exec(`
+		CREATE TABLE IF NOT EXISTS threats (
+			id SERIAL PRIMARY KEY,
+			code VARCHAR(50) UNIQUE NOT NULL,
+			name VARCHAR(255) NOT NULL,
+			category VARCHAR(100) NOT NULL,
+			origin VARCHAR(255),
+			description TEXT,
+			suggested_controls TEXT[] NOT NULL DEFAULT '{}',
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE TABLE IF NOT EXISTS vulnerabilities (
+			id SERIAL PRIMARY KEY,
+			code VARCHAR(50) UNIQUE NOT NULL,
+			name VARCHAR(255) NOT NULL,
+			category VARCHAR(100) NOT NULL,
+			description TEXT,
+			asset_types TEXT[] NOT NULL DEFAULT '{}',
+			suggested_controls TEXT[] NOT NULL DEFAULT '{}',
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		);
+	`)
+	if err != nil {
+		return fmt.Errorf("error creating threat catalogue tables: %v", err)
+	}
+
+	// Create per-scope counters for server-assigned risk IDs
+	_, err = app.DB.Exec(`
+		CREATE TABLE IF NOT EXISTS risk_id_sequences (
+			scope VARCHAR(100) PRIMARY KEY,
+			last_value INTEGER NOT NULL
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("error creating risk_id_sequences table: %v", err)
+	}
+
+	// Create risk appetite: the highest acceptable residual level per category
+	_, err = app.DB.Exec(`
+		CREATE TABLE IF NOT EXISTS risk_appetite (
+			id SERIAL PRIMARY KEY,
+			category VARCHAR(255) NOT NULL,
+			max_level VARCHAR(50) NOT NULL,
+			statement TEXT,
+			approved_by VARCHAR(255),
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_risk_appetite_category ON risk_appetite(LOWER(category));
+	`)
+	if err != nil {
+		return fmt.Errorf("error creating risk_appetite table: %v", err)
+	}
+
+	// Add optional FAIR-style quantitative estimates (min / most likely / max)
+	_, err = app.DB.Exec(`
+		ALTER TABLE risk_register ADD COLUMN IF NOT EXISTS frequency_min DOUBLE PRECISION;
+		ALTER TABLE risk_register ADD COLUMN IF NOT EXISTS frequency_most_likely DOUBLE PRECISION;
+		ALTER TABLE risk_register ADD COLUMN IF NOT EXISTS frequency_max DOUBLE PRECISION;
+		ALTER TABLE risk_register ADD COLUMN IF NOT EXISTS loss_min DOUBLE PRECISION;
+		ALTER TABLE risk_register ADD COLUMN IF NOT EXISTS loss_most_likely DOUBLE PRECISION;
+		ALTER TABLE risk_register ADD COLUMN IF NOT EXISTS loss_max DOUBLE PRECISION;
+	`)
+	if err != nil {
+		return fmt.Errorf("error adding quantitative risk columns: %v", err)
+	}
+
+	// Create the configurable compliance scale for gap assessments; while it is
+	// empty the built-in scale applies
+	_, err = app.DB.Exec(`
+		CREATE TABLE IF NOT EXISTS compliance_scale (
+			id SERIAL PRIMARY KEY,
+			label VARCHAR(50) NOT NULL,
+			weight DOUBLE PRECISION NOT NULL,
+			applicable BOOLEAN NOT NULL DEFAULT TRUE,
+			soa_status VARCHAR(100) NOT NULL,
+			position INTEGER NOT NULL DEFAULT 0,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_compliance_scale_label ON compliance_scale(LOWER(label));
+	`)
+	if err != nil {
+		return fmt.Errorf("error creating compliance_scale table: %v", err)
+	}
+
+	// Create assessment cycles. Gap and maturity assessments belong to a cycle,
+	// and rows recorded before cycles existed go into an initial one
+	_, err = app.DB.Exec(`
+		CREATE TABLE IF NOT EXISTS assessment_cycles (
+			id SERIAL PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			description TEXT,
+			status VARCHAR(20) NOT NULL DEFAULT 'open',
+			copied_from_id INTEGER REFERENCES assessment_cycles(id) ON DELETE SET NULL,
+			closed_at TIMESTAMP,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_assessment_cycles_name ON assessment_cycles(LOWER(name));
+		INSERT INTO assessment_cycles (name, description)
+		SELECT 'Initial assessment', 'Assessments recorded before assessment cycles were introduced'
+		WHERE NOT EXISTS (SELECT 1 FROM assessment_cycles);
+
+		ALTER TABLE gap_assessments ADD COLUMN IF NOT EXISTS cycle_id INTEGER REFERENCES assessment_cycles(id);
+		ALTER TABLE gap_assessments ADD COLUMN IF NOT EXISTS previous_id INTEGER REFERENCES gap_assessments(id) ON DELETE SET NULL;
+		UPDATE gap_assessments SET cycle_id = (SELECT MIN(id) FROM assessment_cycles) WHERE cycle_id IS NULL;
+		ALTER TABLE gap_assessments ALTER COLUMN cycle_id SET NOT NULL;
+		CREATE INDEX IF NOT EXISTS idx_gap_assessments_cycle_id ON gap_assessments(cycle_id);
+
+		ALTER TABLE maturity_assessments ADD COLUMN IF NOT EXISTS cycle_id INTEGER REFERENCES assessment_cycles(id);
+		ALTER TABLE maturity_assessments ADD COLUMN IF NOT EXISTS previous_id INTEGER REFERENCES maturity_assessments(id) ON DELETE SET NULL;
+		UPDATE maturity_assessments SET cycle_id = (SELECT MIN(id) FROM assessment_cycles) WHERE cycle_id IS NULL;
+		ALTER TABLE maturity_assessments ALTER COLUMN cycle_id SET NOT NULL;
+		CREATE INDEX IF NOT EXISTS idx_maturity_assessments_cycle_id ON maturity_assessments(cycle_id);
+	`)
+	if err != nil {
+		return fmt.Errorf("error adding assessment cycles: %v", err)
+	}
+
+	// Score risks that predate server-side scoring, once every risk column exists
+	if err := app.scoreUnscoredRisks(); err != nil {
+		return fmt.Errorf("error scoring existing risks: %v", err)
+	}
+
 	// Create indexes for better query performance
 	_, err = app.DB.Exec(`
 		CREATE INDEX IF NOT EXISTS idx_gap_assessments_standard_ref ON gap_assessments(standard_ref);
@@ -162,3 +550,83 @@ func (app *App) InitializeDB() error {
 
 	return nil
 }
+
+// unmatchedControlsNote prefixes annex_a_controls entries that could not be
+// linked when they are moved into current_controls.
+const unmatchedControlsNote = "Unlinked Annex A controls: "
+
+// migrateAnnexAControls links every entry of the old comma-separated
+// risk_register.annex_a_controls column ("5.15", "A.5.15" or "Control-5.15")
+// to its gap assessment row, then drops the column. Entries that match none
+// are appended to the risk's current_controls so no text is lost.
+func (app *App) migrateAnnexAControls() error {
+	tx, err := app.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query("SELECT id, risk_id, annex_a_controls FROM risk_register WHERE COALESCE(annex_a_controls, '') <> ''")
+	if err != nil {
+		return err
+	}
+	type annexText struct {
+		id     int
+		riskID string
+		text   string
+	}
+	var risks []annexText
+	for rows.Next() {
+		var a annexText
+		if err := rows.Scan(&a.id, &a.riskID, &a.text); err != nil {
+			rows.Close()
+			return err
+		}
+		risks = append(risks, a)
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return err
+	}
+
+	linked := 0
+	for _, a := range risks {
+		var unmatched []string
+		for _, ref := range controlRefSeparator.Split(a.text, -1) {
+			if strings.TrimSpace(ref) == "" {
+				continue
+			}
+			// Cycles are only added further down, so they cannot be used here
+			gapID, err := findControlAnyCycle(tx, ref)
+			if err == sql.ErrNoRows {
+				log.Printf("Risk %s: annex_a_controls entry %q matches no gap assessment and was kept in current_controls", a.riskID, strings.TrimSpace(ref))
+				unmatched = append(unmatched, strings.TrimSpace(ref))
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if err := linkRiskControl(tx, a.id, gapID); err != nil {
+				return err
+			}
+			linked++
+		}
+		if len(unmatched) > 0 {
+			_, err := tx.Exec("UPDATE risk_register SET current_controls = CONCAT_WS(E'\\n', NULLIF(current_controls, ''), $1::text) WHERE id = $2",
+				unmatchedControlsNote+strings.Join(unmatched, ", "), a.id)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if _, err := tx.Exec("ALTER TABLE risk_register DROP COLUMN annex_a_controls"); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	log.Printf("Migrated annex_a_controls of %d risks into %d control links", len(risks), linked)
+	return nil
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"database/sql"
+	"os"
+	"testing"
+)
+
+// openTestDB connects to the scratch database named by TEST_DATABASE_URL and
+// empties its public schema. Tests that need PostgreSQL are skipped when it
+// is not set.
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err := db.Exec("DROP SCHEMA public CASCADE; CREATE SCHEMA public"); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// baselineSchema is the schema createTables produced before any of the
+// later migrations, with risk controls still kept as free text.
+const baselineSchema = `
+	CREATE TABLE gap_assessments (
+		id SERIAL PRIMARY KEY,
+		category VARCHAR(255) NOT NULL,
+		section VARCHAR(255) NOT NULL,
+		standard_ref VARCHAR(255) NOT NULL,
+		assessment_question TEXT NOT NULL,
+		compliance VARCHAR(50) NOT NULL,
+		notes TEXT,
+		target_date DATE,
+		action_item_id INTEGER,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE TABLE maturity_assessments (
+		id SERIAL PRIMARY KEY,
+		category VARCHAR(255) NOT NULL,
+		section VARCHAR(255) NOT NULL,
+		standard_ref VARCHAR(255) NOT NULL,
+		assessment_question TEXT NOT NULL,
+		current_maturity_level VARCHAR(50),
+		current_maturity_score INTEGER,
+		current_maturity_comments TEXT,
+		target_maturity_level VARCHAR(50),
+		target_maturity_score INTEGER,
+		target_maturity_comments TEXT,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE TABLE action_items (
+		id SERIAL PRIMARY KEY,
+		title VARCHAR(255) NOT NULL,
+		description TEXT,
+		status VARCHAR(50) NOT NULL,
+		priority VARCHAR(50) NOT NULL,
+		assigned_to VARCHAR(255),
+		due_date DATE,
+		completed_date DATE,
+		gap_assessment_id INTEGER REFERENCES gap_assessments(id),
+		maturity_assessment_id INTEGER REFERENCES maturity_assessments(id),
+		category VARCHAR(255),
+		file_name VARCHAR(255),
+		file_path TEXT,
+		file_size INTEGER,
+		file_type VARCHAR(100),
+		clause_reference VARCHAR(255),
+		annex_reference VARCHAR(255),
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE TABLE evidence (
+		id SERIAL PRIMARY KEY,
+		title VARCHAR(255) NOT NULL,
+		description TEXT,
+		file_name VARCHAR(255) NOT NULL,
+		file_path TEXT NOT NULL,
+		file_size INTEGER,
+		file_type VARCHAR(100) NOT NULL,
+		gap_assessment_id INTEGER REFERENCES gap_assessments(id),
+		maturity_assessment_id INTEGER REFERENCES maturity_assessments(id),
+		clause_reference VARCHAR(255),
+		annex_reference VARCHAR(255),
+		uploaded_by VARCHAR(255) NOT NULL,
+		uploaded_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE TABLE risk_register (
+		id SERIAL PRIMARY KEY,
+		risk_id VARCHAR(100) UNIQUE NOT NULL,
+		title VARCHAR(255) NOT NULL,
+		description TEXT,
+		category VARCHAR(255) NOT NULL,
+		likelihood VARCHAR(50) NOT NULL,
+		impact VARCHAR(50) NOT NULL,
+		risk_level VARCHAR(50) NOT NULL,
+		current_controls TEXT,
+		treatment_plan TEXT,
+		treatment_status VARCHAR(50) NOT NULL,
+		owner VARCHAR(255) NOT NULL,
+		target_date DATE,
+		gap_assessment_id INTEGER REFERENCES gap_assessments(id),
+		annex_a_controls TEXT,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+`
+
+func TestCreateTablesUpgradesBaselineSchema(t *testing.T) {
+	db := openTestDB(t)
+	if _, err := db.Exec(baselineSchema); err != nil {
+		t.Fatal(err)
+	}
+	_, err := db.Exec(`
+		INSERT INTO gap_assessments (category, section, standard_ref, assessment_question, compliance, notes)
+		VALUES ('Annex A', 'A.5 - Organizational controls', 'Control-5.15', 'Is access controlled?', 'Partially Compliant', '');
+		INSERT INTO maturity_assessments (category, section, standard_ref, assessment_question)
+		VALUES ('Annex A', 'A.5 - Organizational controls', 'Control-5.15', 'How mature is access control?');
+		INSERT INTO risk_register (risk_id, title, category, likelihood, impact, risk_level, current_controls, treatment_status, owner, annex_a_controls)
+		VALUES ('RISK-001', 'Unauthorised access', 'Operational', 'High', 'Medium', 'High', 'MFA', 'Open', 'CISO', 'A.5.15; A.99.1')
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := &App{DB: db}
+	// A second run must find nothing left to do
+	for run := 1; run <= 2; run++ {
+		if err := app.createTables(); err != nil {
+			t.Fatalf("createTables run %d: %v", run, err)
+		}
+	}
+
+	var cycles, unassigned int
+	err = db.QueryRow(`SELECT (SELECT COUNT(*) FROM assessment_cycles),
+		(SELECT COUNT(*) FROM gap_assessments WHERE cycle_id IS NULL) + (SELECT COUNT(*) FROM maturity_assessments WHERE cycle_id IS NULL)`).
+		Scan(&cycles, &unassigned)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cycles != 1 || unassigned != 0 {
+		t.Errorf("got %d cycles and %d rows without a cycle, want 1 and 0", cycles, unassigned)
+	}
+
+	var linked string
+	err = db.QueryRow(`SELECT g.standard_ref FROM risk_controls rc
+		JOIN risk_register r ON r.id = rc.risk_register_id
+		JOIN gap_assessments g ON g.id = rc.gap_assessment_id
+		WHERE r.risk_id = 'RISK-001'`).Scan(&linked)
+	if err != nil {
+		t.Fatalf("annex_a_controls link: %v", err)
+	}
+	if linked != "Control-5.15" {
+		t.Errorf("risk linked to %q, want Control-5.15", linked)
+	}
+
+	// The entry that matches no control survives the dropped column
+	var controls string
+	if err := db.QueryRow("SELECT current_controls FROM risk_register WHERE risk_id = 'RISK-001'").Scan(&controls); err != nil {
+		t.Fatal(err)
+	}
+	if want := "MFA\nUnlinked Annex A controls: A.99.1"; controls != want {
+		t.Errorf("current_controls = %q, want %q", controls, want)
+	}
+
+	// Same evidence_versions schema as migrations/006_add_evidence_versions.sql
+	var pathType string
+	var indexed bool
+	err = db.QueryRow(`SELECT data_type,
+		EXISTS (SELECT 1 FROM pg_indexes WHERE indexname = 'idx_evidence_versions_evidence')
+		FROM information_schema.columns WHERE table_name = 'evidence_versions' AND column_name = 'file_path'`).Scan(&pathType, &indexed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pathType != "text" || !indexed {
+		t.Errorf("evidence_versions.file_path is %s, index present %v; want text and true", pathType, indexed)
+	}
+
+	var score, residual sql.NullInt64
+	if err := db.QueryRow("SELECT risk_score, residual_score FROM risk_register WHERE risk_id = 'RISK-001'").Scan(&score, &residual); err != nil {
+		t.Fatal(err)
+	}
+	if !score.Valid || !residual.Valid {
+		t.Errorf("risk not scored after upgrade: risk_score %v, residual_score %v", score, residual)
+	}
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// mergePatchContentType is the media type of RFC 7396 JSON Merge Patch.
+const mergePatchContentType = "application/merge-patch+json"
+
+// mergePatch applies an RFC 7396 merge patch to target: members of an object
+// patch replace those of target, null removes a member and nested objects are
+// merged recursively. Anything other than an object replaces target outright.
+func mergePatch(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = map[string]interface{}{}
+	}
+	for name, value := range p {
+		if value == nil {
+			delete(t, name)
+		} else {
+			t[name] = mergePatch(t[name], value)
+		}
+	}
+	return t
+}
+
+// decodeJSON decodes data keeping numbers as json.Number, so values pass
+// through a patch unchanged.
+func decodeJSON(data []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+// applyMergePatch applies the merge patch in patch to the JSON form of
+// current and returns the merged document. A patch that is not a JSON object
+// is reported as *riskInputError.
+func applyMergePatch(current interface{}, patch []byte) ([]byte, error) {
+	var p interface{}
+	if err := decodeJSON(patch, &p); err != nil {
+		return nil, &riskInputError{err.Error()}
+	}
+	if _, ok := p.(map[string]interface{}); !ok {
+		return nil, &riskInputError{"A merge patch must be a JSON object"}
+	}
+
+	encoded, err := json.Marshal(current)
+	if err != nil {
+		return nil, err
+	}
+	var doc interface{}
+	if err := decodeJSON(encoded, &doc); err != nil {
+		return nil, err
+	}
+	return json.Marshal(mergePatch(doc, p))
+}
+
+// patchLoader returns the current representation of row id of a resource.
+type patchLoader func(db queryRower, id int) (interface{}, error)
+
+// patchBase is the representation a merge patch was applied to, carried in
+// the request context from patchResource to the PUT handler.
+type patchBase struct {
+	table   string
+	load    patchLoader
+	encoded []byte
+}
+
+type patchBaseKey struct{}
+
+// patchConflictError reports a row that another request changed between a
+// PATCH loading it and the merged result being saved.
+type patchConflictError struct{}
+
+func (e *patchConflictError) Error() string {
+	return "The resource was changed by another request; reload it and retry the patch"
+}
+
+// checkPatchBase is called by PUT handlers inside their write transaction,
+// before writing row id. For a PATCH it locks the row and fails with
+// *patchConflictError if the row no longer matches the representation the
+// patch was merged into, so a concurrent update is never silently lost. For
+// a plain PUT it does nothing.
+func checkPatchBase(db dbExecutor, r *http.Request, id int) error {
+	base, ok := r.Context().Value(patchBaseKey{}).(*patchBase)
+	if !ok {
+		return nil
+	}
+	if err := lockRow(db, base.table, id); err != nil {
+		return err
+	}
+	current, err := base.load(db, id)
+	if err != nil {
+		return err
+	}
+	encoded, err := json.Marshal(current)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(encoded, base.encoded) {
+		return &patchConflictError{}
+	}
+	return nil
+}
+
+// lockRow locks row id of table until the surrounding transaction ends, so a
+// row read before it is written cannot change in between. A missing row is
+// reported as sql.ErrNoRows.
+func lockRow(db queryRower, table string, id int) error {
+	var locked int
+	return db.QueryRow("SELECT id FROM "+table+" WHERE id = $1 FOR UPDATE", id).Scan(&locked)
+}
+
+// writePatchError answers a failed checkPatchBase: a missing row with
+// notFound, a conflicting change with 409 and anything else with 500.
+func writePatchError(w http.ResponseWriter, err error, notFound string) {
+	var pc *patchConflictError
+	switch {
+	case err == sql.ErrNoRows:
+		http.Error(w, notFound, http.StatusNotFound)
+	case errors.As(err, &pc):
+		http.Error(w, pc.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// patchResource serves PATCH for a resource whose PUT handler expects the
+// full representation. The merge patch is applied to the current
+// representation returned by load and the result is handed to put, so a
+// patch goes through exactly the validation and side effects of a PUT while
+// fields it leaves out keep their values. put must call checkPatchBase
+// before it writes. load must return sql.ErrNoRows for a missing resource,
+// which is answered with notFound.
+func (app *App) patchResource(w http.ResponseWriter, r *http.Request, table, notFound string, load patchLoader, put http.HandlerFunc) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		mediaType, _, err := mime.ParseMediaType(ct)
+		if err != nil || (mediaType != mergePatchContentType && mediaType != "application/json") {
+			http.Error(w, "PATCH bodies must be "+mergePatchContentType, http.StatusUnsupportedMediaType)
+			return
+		}
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	current, err := load(app.DB, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, notFound, http.StatusNotFound)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	merged, err := applyMergePatch(current, body)
+	if err != nil {
+		writeRiskError(w, err)
+		return
+	}
+	encoded, err := json.Marshal(current)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	r = r.WithContext(context.WithValue(r.Context(), patchBaseKey{}, &patchBase{table: table, load: load, encoded: encoded}))
+	r.Body = io.NopCloser(bytes.NewReader(merged))
+	r.ContentLength = int64(len(merged))
+	r.Header.Set("Content-Type", "application/json")
+	put(w, r)
+}
+
+func (app *App) patchGapAssessment(w http.ResponseWriter, r *http.Request) {
+	app.patchResource(w, r, "gap_assessments", "Assessment not found", func(db queryRower, id int) (interface{}, error) {
+		var a GapAssessment
+		err := scanGapAssessment(db.QueryRow("SELECT "+gapAssessmentColumns+" FROM gap_assessments WHERE id = $1", id), &a)
+		return a, err
+	}, app.updateGapAssessment)
+}
+
+func (app *App) patchMaturityAssessment(w http.ResponseWriter, r *http.Request) {
+	app.patchResource(w, r, "maturity_assessments", "Assessment not found", func(db queryRower, id int) (interface{}, error) {
+		var a MaturityAssessment
+		err := scanMaturityAssessment(db.QueryRow("SELECT "+maturityAssessmentColumns+" FROM maturity_assessments WHERE id = $1", id), &a)
+		return a, err
+	}, app.updateMaturityAssessment)
+}
+
+func (app *App) patchActionItem(w http.ResponseWriter, r *http.Request) {
+	app.patchResource(w, r, "action_items", "Action item not found", func(db queryRower, id int) (interface{}, error) {
+		var item ActionItem
+		err := scanActionItem(db.QueryRow("SELECT "+actionItemColumns+" FROM action_items WHERE id = $1", id), &item)
+		return item, err
+	}, app.updateActionItem)
+}
+
+func (app *App) patchEvidence(w http.ResponseWriter, r *http.Request) {
+	app.patchResource(w, r, "evidence", "Evidence not found", func(db queryRower, id int) (interface{}, error) {
+		var item Evidence
+		err := scanEvidence(db.QueryRow("SELECT "+evidenceColumns+" FROM evidence WHERE id = $1", id), &item)
+		return item, err
+	}, app.updateEvidence)
+}
+
+// patchRisk re-scores the merged risk like PUT does, so patching likelihood
+// or impact alone updates score and level.
+func (app *App) patchRisk(w http.ResponseWriter, r *http.Request) {
+	app.patchResource(w, r, "risk_register", "Risk not found", func(db queryRower, id int) (interface{}, error) {
+		var risk RiskRegister
+		err := scanRisk(db.QueryRow("SELECT "+riskColumns+" FROM risk_register WHERE id = $1", id), &risk)
+		return risk, err
+	}, app.updateRisk)
+}
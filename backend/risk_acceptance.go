@@ -0,0 +1,364 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// treatmentOptions are the ISO 27001 6.1.3 risk treatment options. Common
+// synonyms are accepted on input and stored under the canonical name.
+var treatmentOptions = map[string]string{
+	"modify":   "modify",
+	"mitigate": "modify",
+	"reduce":   "modify",
+	"retain":   "retain",
+	"accept":   "retain",
+	"avoid":    "avoid",
+	"share":    "share",
+	"transfer": "share",
+}
+
+// normalizeTreatmentOption validates a risk's treatment option in place.
+// An empty option is stored as NULL.
+func normalizeTreatmentOption(risk *RiskRegister) error {
+	if risk.TreatmentOption == nil || strings.TrimSpace(*risk.TreatmentOption) == "" {
+		risk.TreatmentOption = nil
+		return nil
+	}
+	option, ok := treatmentOptions[strings.ToLower(strings.TrimSpace(*risk.TreatmentOption))]
+	if !ok {
+		return &riskInputError{fmt.Sprintf("Unknown treatment_option %q; expected one of: modify, retain, avoid, share", *risk.TreatmentOption)}
+	}
+	risk.TreatmentOption = &option
+	return nil
+}
+
+// acceptedStatus is the treatment status set by approving an acceptance.
+const acceptedStatus = "Accepted"
+
+// checkTreatmentStatus keeps the Accepted treatment status reserved for
+// approved acceptances: a risk that has it may keep it, but a client cannot
+// set it. current is the stored status, "" for a new risk.
+func checkTreatmentStatus(risk *RiskRegister, current string) error {
+	if strings.EqualFold(strings.TrimSpace(risk.TreatmentStatus), acceptedStatus) && current != acceptedStatus {
+		return &riskInputError{"treatment_status Accepted is only set by approving a risk acceptance"}
+	}
+	return nil
+}
+
+// cancelPendingAcceptances cancels the pending acceptance request of a risk
+// whose treatment option is no longer retain, so it cannot be approved later.
+func cancelPendingAcceptances(db execer, risk *RiskRegister) error {
+	if risk.TreatmentOption != nil && *risk.TreatmentOption == "retain" {
+		return nil
+	}
+	_, err := db.Exec(
+		`UPDATE risk_acceptances
+		 SET status = 'cancelled', decided_at = CURRENT_TIMESTAMP, decision_note = 'Cancelled: treatment option is no longer retain'
+		 WHERE risk_register_id = $1 AND status = 'pending'`, risk.ID,
+	)
+	return err
+}
+
+// RiskAcceptance is a request for the risk owner to formally accept (retain)
+// a risk, and the owner's decision on it.
+type RiskAcceptance struct {
+	ID             int     `json:"id"`
+	RiskRegisterID int     `json:"risk_register_id"`
+	RiskRef        string  `json:"risk_ref"`
+	RiskTitle      string  `json:"risk_title"`
+	RiskOwner      string  `json:"risk_owner"`
+	Status         string  `json:"status"`
+	RequestedBy    string  `json:"requested_by"`
+	RequestedAt    string  `json:"requested_at"`
+	Rationale      string  `json:"rationale"`
+	ExpiresAt      string  `json:"expires_at"`
+	Approver       *string `json:"approver,omitempty"`
+	DecidedAt      *string `json:"decided_at,omitempty"`
+	DecisionNote   *string `json:"decision_note,omitempty"`
+}
+
+// RiskAcceptanceRequest is the body of a new acceptance request.
+type RiskAcceptanceRequest struct {
+	RequestedBy string `json:"requested_by"`
+	Rationale   string `json:"rationale"`
+	ExpiresAt   string `json:"expires_at"`
+}
+
+// RiskAcceptanceDecision is the risk owner's approval or rejection. ExpiresAt
+// optionally overrides the requested expiry when approving, and is required
+// once the requested expiry has passed.
+type RiskAcceptanceDecision struct {
+	Approver     string `json:"approver"`
+	Decision     string `json:"decision"`
+	DecisionNote string `json:"decision_note"`
+	ExpiresAt    string `json:"expires_at"`
+}
+
+const riskAcceptanceColumns = `a.id, a.risk_register_id, r.risk_id, r.title, COALESCE(r.owner, ''), a.status,
+	COALESCE(a.requested_by, ''), a.requested_at, a.rationale, a.expires_at, a.approver, a.decided_at, a.decision_note`
+
+func scanRiskAcceptance(row rowScanner, a *RiskAcceptance) error {
+	var expiresAt time.Time
+	if err := row.Scan(&a.ID, &a.RiskRegisterID, &a.RiskRef, &a.RiskTitle, &a.RiskOwner, &a.Status,
+		&a.RequestedBy, &a.RequestedAt, &a.Rationale, &expiresAt, &a.Approver, &a.DecidedAt, &a.DecisionNote); err != nil {
+		return err
+	}
+	a.ExpiresAt = expiresAt.Format("2006-01-02")
+	return nil
+}
+
+func (app *App) queryRiskAcceptances(w http.ResponseWriter, query string, args ...interface{}) {
+	rows, err := app.DB.Query(query, args...)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	acceptances := []RiskAcceptance{}
+	for rows.Next() {
+		var a RiskAcceptance
+		if err := scanRiskAcceptance(rows, &a); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		acceptances = append(acceptances, a)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(acceptances)
+}
+
+// parseExpiry parses a YYYY-MM-DD expiry that must lie in the future.
+func parseExpiry(s string) (string, error) {
+	d, err := time.Parse("2006-01-02", strings.TrimSpace(s))
+	if err != nil {
+		return "", fmt.Errorf("expires_at must be a date (YYYY-MM-DD)")
+	}
+	if !d.After(time.Now().UTC().Truncate(24 * time.Hour)) {
+		return "", fmt.Errorf("expires_at must be in the future")
+	}
+	return d.Format("2006-01-02"), nil
+}
+
+// Risk Acceptance Handlers
+func (app *App) getRiskAcceptances(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	app.queryRiskAcceptances(w,
+		"SELECT "+riskAcceptanceColumns+" FROM risk_acceptances a JOIN risk_register r ON r.id = a.risk_register_id WHERE a.risk_register_id = $1 ORDER BY a.requested_at DESC, a.id DESC", id)
+}
+
+// requestRiskAcceptance asks the risk owner to accept a risk whose treatment
+// option is retain. Only one request per risk can be pending at a time.
+func (app *App) requestRiskAcceptance(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	var req RiskAcceptanceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.Rationale) == "" {
+		http.Error(w, "rationale is required", http.StatusBadRequest)
+		return
+	}
+	expiresAt, err := parseExpiry(req.ExpiresAt)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var option, owner sql.NullString
+	err = app.DB.QueryRow("SELECT treatment_option, owner FROM risk_register WHERE id = $1", id).Scan(&option, &owner)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Risk not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if option.String != "retain" {
+		http.Error(w, "Only risks with treatment_option retain can be accepted", http.StatusConflict)
+		return
+	}
+	if strings.TrimSpace(owner.String) == "" {
+		http.Error(w, "Risk has no owner to approve the acceptance", http.StatusConflict)
+		return
+	}
+
+	var acceptanceID int
+	err = app.DB.QueryRow(
+		`INSERT INTO risk_acceptances (risk_register_id, requested_by, rationale, expires_at)
+		 SELECT $1, $2, $3, $4
+		 WHERE NOT EXISTS (SELECT 1 FROM risk_acceptances WHERE risk_register_id = $1 AND status = 'pending')
+		 RETURNING id`,
+		id, req.RequestedBy, req.Rationale, expiresAt,
+	).Scan(&acceptanceID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "An acceptance request for this risk is already pending", http.StatusConflict)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var a RiskAcceptance
+	if err := scanRiskAcceptance(app.DB.QueryRow("SELECT "+riskAcceptanceColumns+" FROM risk_acceptances a JOIN risk_register r ON r.id = a.risk_register_id WHERE a.id = $1", acceptanceID), &a); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(a)
+}
+
+// decideRiskAcceptance records the risk owner's approval or rejection of a
+// pending request; an approved acceptance marks the risk's treatment status
+// as Accepted. The API has no authenticated users, so the check that the
+// approver is the risk owner only compares names: it is advisory, guarding
+// against mistakes rather than against someone typing the owner's name.
+func (app *App) decideRiskAcceptance(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	var d RiskAcceptanceDecision
+	if err := json.NewDecoder(r.Body).Decode(&d); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var status string
+	switch strings.ToLower(strings.TrimSpace(d.Decision)) {
+	case "approve", "approved":
+		status = "approved"
+	case "reject", "rejected":
+		status = "rejected"
+	default:
+		http.Error(w, "decision must be approve or reject", http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(d.Approver) == "" {
+		http.Error(w, "approver is required", http.StatusBadRequest)
+		return
+	}
+	var expiresAt *string
+	if status == "approved" && strings.TrimSpace(d.ExpiresAt) != "" {
+		e, err := parseExpiry(d.ExpiresAt)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		expiresAt = &e
+	}
+
+	tx, err := app.DB.Begin()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	var riskID int
+	var current, owner string
+	var expired bool
+	err = tx.QueryRow(
+		`SELECT a.risk_register_id, a.status, COALESCE(r.owner, ''), a.expires_at <= CURRENT_DATE
+		 FROM risk_acceptances a JOIN risk_register r ON r.id = a.risk_register_id
+		 WHERE a.id = $1 FOR UPDATE OF a`, id,
+	).Scan(&riskID, &current, &owner, &expired)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Acceptance not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if current != "pending" {
+		http.Error(w, fmt.Sprintf("Acceptance has already been %s", current), http.StatusConflict)
+		return
+	}
+	if !strings.EqualFold(strings.TrimSpace(d.Approver), strings.TrimSpace(owner)) {
+		http.Error(w, fmt.Sprintf("Only the risk owner (%s) can decide this acceptance", owner), http.StatusForbidden)
+		return
+	}
+	// A request left pending past its expiry cannot be approved as it stands
+	if status == "approved" && expiresAt == nil && expired {
+		http.Error(w, "The requested expiry has passed; approve with a new expires_at in the future", http.StatusBadRequest)
+		return
+	}
+
+	_, err = tx.Exec(
+		`UPDATE risk_acceptances
+		 SET status = $1, approver = $2, decision_note = $3, decided_at = CURRENT_TIMESTAMP, expires_at = COALESCE($4::date, expires_at)
+		 WHERE id = $5`,
+		status, strings.TrimSpace(d.Approver), d.DecisionNote, expiresAt, id,
+	)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if status == "approved" {
+		if _, err := tx.Exec("UPDATE risk_register SET treatment_status = $1 WHERE id = $2", acceptedStatus, riskID); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	var a RiskAcceptance
+	if err := scanRiskAcceptance(tx.QueryRow("SELECT "+riskAcceptanceColumns+" FROM risk_acceptances a JOIN risk_register r ON r.id = a.risk_register_id WHERE a.id = $1", id), &a); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(a)
+}
+
+// getPendingRiskAcceptances lists requests still waiting for the risk owner.
+func (app *App) getPendingRiskAcceptances(w http.ResponseWriter, r *http.Request) {
+	app.queryRiskAcceptances(w,
+		"SELECT "+riskAcceptanceColumns+" FROM risk_acceptances a JOIN risk_register r ON r.id = a.risk_register_id WHERE a.status = 'pending' ORDER BY a.requested_at, a.id")
+}
+
+// getExpiredRiskAcceptances lists risks that are still retained but whose
+// most recent approved acceptance has expired, so the owner must re-approve.
+func (app *App) getExpiredRiskAcceptances(w http.ResponseWriter, r *http.Request) {
+	app.queryRiskAcceptances(w, `
+		SELECT `+riskAcceptanceColumns+`
+		FROM (
+			SELECT DISTINCT ON (risk_register_id) *
+			FROM risk_acceptances
+			WHERE status = 'approved'
+			ORDER BY risk_register_id, expires_at DESC, decided_at DESC
+		) a
+		JOIN risk_register r ON r.id = a.risk_register_id
+		WHERE a.expires_at < CURRENT_DATE AND r.treatment_option = 'retain'
+		ORDER BY a.expires_at, a.id`)
+}
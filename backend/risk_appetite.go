@@ -0,0 +1,284 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// appetiteDefaultCategory holds the appetite for categories without their own.
+const appetiteDefaultCategory = "*"
+
+// RiskAppetite is the maximum residual risk level top management accepts for
+// a risk category (Clause 6.1).
+type RiskAppetite struct {
+	Category   string  `json:"category"`
+	MaxLevel   string  `json:"max_level"`
+	Statement  string  `json:"statement"`
+	ApprovedBy string  `json:"approved_by"`
+	UpdatedAt  *string `json:"updated_at,omitempty"`
+}
+
+// RiskAppetiteBreach is a risk above appetite that is not being dealt with.
+type RiskAppetiteBreach struct {
+	Risk             RiskRegister `json:"risk"`
+	Category         string       `json:"category"`
+	Level            string       `json:"level"`
+	MaxLevel         string       `json:"max_level"`
+	AppetiteCategory string       `json:"appetite_category"`
+	Reasons          []string     `json:"reasons"`
+}
+
+// threshold finds a level of the matrix, case-insensitively.
+func (m *RiskMatrix) threshold(level string) (RiskThreshold, bool) {
+	for _, t := range m.Thresholds {
+		if strings.EqualFold(t.Level, strings.TrimSpace(level)) {
+			return t, true
+		}
+	}
+	return RiskThreshold{}, false
+}
+
+func thresholdLevels(m *RiskMatrix) string {
+	names := make([]string, len(m.Thresholds))
+	for i, t := range m.Thresholds {
+		names[i] = t.Level
+	}
+	return strings.Join(names, ", ")
+}
+
+// loadRiskAppetite returns the appetite per lower-cased category.
+func loadRiskAppetite(db dbExecutor) (map[string]RiskAppetite, error) {
+	rows, err := db.Query("SELECT category, max_level, COALESCE(statement, ''), COALESCE(approved_by, ''), updated_at FROM risk_appetite ORDER BY category")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	appetite := map[string]RiskAppetite{}
+	for rows.Next() {
+		var a RiskAppetite
+		var updatedAt string
+		if err := rows.Scan(&a.Category, &a.MaxLevel, &a.Statement, &a.ApprovedBy, &updatedAt); err != nil {
+			return nil, err
+		}
+		a.UpdatedAt = &updatedAt
+		appetite[strings.ToLower(a.Category)] = a
+	}
+	return appetite, rows.Err()
+}
+
+// checkAppetiteLevels reports appetite levels missing from m as a
+// *riskInputError, so the matrix cannot drop a level appetite refers to.
+func checkAppetiteLevels(db dbExecutor, m *RiskMatrix) error {
+	appetite, err := loadRiskAppetite(db)
+	if err != nil {
+		return err
+	}
+	for _, a := range appetite {
+		if _, ok := m.threshold(a.MaxLevel); !ok {
+			return &riskInputError{fmt.Sprintf("Risk appetite for %q uses level %q, which is not a threshold of the matrix", a.Category, a.MaxLevel)}
+		}
+	}
+	return nil
+}
+
+// Risk Appetite Handlers
+func (app *App) getRiskAppetite(w http.ResponseWriter, r *http.Request) {
+	appetite, err := loadRiskAppetite(app.DB)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	list := []RiskAppetite{}
+	for _, a := range appetite {
+		list = append(list, a)
+	}
+	sortRiskAppetite(list)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// updateRiskAppetite replaces the appetite of every category. Category "*"
+// applies to risks whose category has no entry of its own.
+func (app *App) updateRiskAppetite(w http.ResponseWriter, r *http.Request) {
+	var list []RiskAppetite
+	if err := json.NewDecoder(r.Body).Decode(&list); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	m, err := loadRiskMatrix(app.DB)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	seen := map[string]bool{}
+	for i := range list {
+		a := &list[i]
+		a.Category = strings.TrimSpace(a.Category)
+		if a.Category == "" {
+			http.Error(w, "category is required; use \"*\" for the default appetite", http.StatusBadRequest)
+			return
+		}
+		if seen[strings.ToLower(a.Category)] {
+			http.Error(w, fmt.Sprintf("duplicate category %q", a.Category), http.StatusBadRequest)
+			return
+		}
+		seen[strings.ToLower(a.Category)] = true
+		t, ok := m.threshold(a.MaxLevel)
+		if !ok {
+			http.Error(w, fmt.Sprintf("Unknown max_level %q for %q; expected one of: %s", a.MaxLevel, a.Category, thresholdLevels(m)), http.StatusBadRequest)
+			return
+		}
+		a.MaxLevel = t.Level
+	}
+
+	tx, err := app.DB.Begin()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM risk_appetite"); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for i := range list {
+		a := &list[i]
+		var updatedAt string
+		err := tx.QueryRow(
+			"INSERT INTO risk_appetite (category, max_level, statement, approved_by) VALUES ($1, $2, $3, $4) RETURNING updated_at",
+			a.Category, a.MaxLevel, a.Statement, a.ApprovedBy,
+		).Scan(&updatedAt)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		a.UpdatedAt = &updatedAt
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if list == nil {
+		list = []RiskAppetite{}
+	}
+	sortRiskAppetite(list)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// getRiskAppetiteBreaches lists risks whose residual level (inherent when no
+// residual rating exists) is above the appetite for their category and that
+// either have no approved treatment or are past their target date. A
+// treatment counts as approved when a treatment option is chosen and, for
+// retain, the owner's acceptance is approved and unexpired, or otherwise the
+// treatment has started (status is no longer Open).
+func (app *App) getRiskAppetiteBreaches(w http.ResponseWriter, r *http.Request) {
+	m, err := loadRiskMatrix(app.DB)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	appetite, err := loadRiskAppetite(app.DB)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	accepted := map[int]bool{}
+	ids, err := queryIDs(app.DB, "SELECT DISTINCT risk_register_id FROM risk_acceptances WHERE status = 'approved' AND expires_at >= CURRENT_DATE")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for _, id := range ids {
+		accepted[id] = true
+	}
+
+	rows, err := app.DB.Query("SELECT " + riskColumns + " FROM risk_register ORDER BY COALESCE(residual_score, risk_score) DESC NULLS LAST, risk_id")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	today := time.Now().Format("2006-01-02")
+	breaches := []RiskAppetiteBreach{}
+	for rows.Next() {
+		var risk RiskRegister
+		if err := scanRisk(rows, &risk); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		a, ok := appetite[strings.ToLower(strings.TrimSpace(risk.Category))]
+		if !ok {
+			if a, ok = appetite[appetiteDefaultCategory]; !ok {
+				continue
+			}
+		}
+		level := risk.RiskLevel
+		if risk.ResidualLevel != nil {
+			level = *risk.ResidualLevel
+		}
+		current, ok := m.threshold(level)
+		limit, known := m.threshold(a.MaxLevel)
+		if !ok || !known || current.MinScore <= limit.MinScore {
+			continue
+		}
+
+		var reasons []string
+		switch {
+		case risk.TreatmentOption == nil:
+			reasons = append(reasons, "No treatment option chosen")
+		case *risk.TreatmentOption == "retain" && !accepted[risk.ID]:
+			reasons = append(reasons, "Retained without an approved, unexpired owner acceptance")
+		case *risk.TreatmentOption != "retain" && (risk.TreatmentStatus == "" || risk.TreatmentStatus == "Open"):
+			reasons = append(reasons, "Treatment not started")
+		}
+		if risk.TargetDate != nil && len(*risk.TargetDate) >= 10 && risk.TreatmentStatus != "Mitigated" && risk.TreatmentStatus != "Accepted" {
+			if due := (*risk.TargetDate)[:10]; due < today {
+				reasons = append(reasons, fmt.Sprintf("Target date %s has passed", due))
+			}
+		}
+		if len(reasons) == 0 {
+			continue
+		}
+
+		breaches = append(breaches, RiskAppetiteBreach{
+			Risk:             risk,
+			Category:         orUnspecified(risk.Category),
+			Level:            current.Level,
+			MaxLevel:         limit.Level,
+			AppetiteCategory: a.Category,
+			Reasons:          reasons,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(breaches)
+}
+
+// sortRiskAppetite orders entries by category with the default last.
+func sortRiskAppetite(list []RiskAppetite) {
+	sort.Slice(list, func(i, j int) bool {
+		if (list[i].Category == appetiteDefaultCategory) != (list[j].Category == appetiteDefaultCategory) {
+			return list[j].Category == appetiteDefaultCategory
+		}
+		return strings.ToLower(list[i].Category) < strings.ToLower(list[j].Category)
+	})
+}
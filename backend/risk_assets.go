@@ -0,0 +1,316 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+// RiskAssetRequest identifies the asset to link, by ID or by asset_id code.
+type RiskAssetRequest struct {
+	ID      *int   `json:"id,omitempty"`
+	AssetID string `json:"asset_id,omitempty"`
+}
+
+// queryIDs runs a query returning a single integer column.
+func queryIDs(db dbExecutor, query string, args ...interface{}) ([]int, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// assetImpact maps an asset value (1 to maxAssetRating) onto the matrix's
+// impact axis, so the highest value always means the highest impact whatever
+// the number of impact labels.
+func assetImpact(m *RiskMatrix, value int) string {
+	impacts := sortedLabels(m.Impact)
+	pos := int(math.Round(float64(value-1) * float64(len(impacts)-1) / float64(maxAssetRating-1)))
+	if pos < 0 {
+		pos = 0
+	}
+	if pos >= len(impacts) {
+		pos = len(impacts) - 1
+	}
+	return impacts[pos].Label
+}
+
+// applyAssetImpact sets the impact of a risk with impact_from_assets from
+// the most valuable asset linked to it. Risks without linked assets keep
+// their impact.
+func applyAssetImpact(db queryRower, m *RiskMatrix, risk *RiskRegister) error {
+	if !risk.ImpactFromAssets || risk.ID == 0 {
+		return nil
+	}
+	var value sql.NullInt64
+	err := db.QueryRow(
+		`SELECT MAX(GREATEST(a.confidentiality, a.integrity, a.availability))
+		 FROM risk_assets ra JOIN assets a ON a.id = ra.asset_id
+		 WHERE ra.risk_register_id = $1`, risk.ID,
+	).Scan(&value)
+	if err != nil {
+		return err
+	}
+	if value.Valid {
+		risk.Impact = assetImpact(m, int(value.Int64))
+	}
+	return nil
+}
+
+// deriveRiskImpacts re-derives impact, score and residual rating of the given
+// risks after their assets changed. Risks that set their impact by hand are
+// left alone.
+func deriveRiskImpacts(db dbExecutor, ids []int) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	m, err := loadRiskMatrix(db)
+	if err != nil {
+		return err
+	}
+
+	rows, err := db.Query("SELECT "+riskColumns+" FROM risk_register WHERE impact_from_assets AND id = ANY($1)", pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	var risks []RiskRegister
+	for rows.Next() {
+		var risk RiskRegister
+		if err := scanRisk(rows, &risk); err != nil {
+			rows.Close()
+			return err
+		}
+		risks = append(risks, risk)
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return err
+	}
+
+	for i := range risks {
+		risk := &risks[i]
+		if err := applyAssetImpact(db, m, risk); err != nil {
+			return err
+		}
+		if err := scoreRisk(m, risk); err != nil {
+			// Labels no longer on the matrix; keep the stored rating
+			continue
+		}
+		_, err := db.Exec("UPDATE risk_register SET impact = $1, risk_score = $2, risk_level = $3 WHERE id = $4", risk.Impact, risk.RiskScore, risk.RiskLevel, risk.ID)
+		if err != nil {
+			return err
+		}
+		if err := updateResidualRisk(db, m, risk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// refreshAssetRisks re-derives the risks returned by query (a single
+// risk_register_id column).
+func refreshAssetRisks(db dbExecutor, query string, args ...interface{}) error {
+	ids, err := queryIDs(db, query, args...)
+	if err != nil {
+		return err
+	}
+	return deriveRiskImpacts(db, ids)
+}
+
+// Risk Asset Handlers
+func (app *App) getRiskAssets(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	var exists bool
+	if err := app.DB.QueryRow("SELECT EXISTS (SELECT 1 FROM risk_register WHERE id = $1)", id).Scan(&exists); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !exists {
+		http.Error(w, "Risk not found", http.StatusNotFound)
+		return
+	}
+
+	rows, err := app.DB.Query("SELECT "+assetColumns+" FROM assets WHERE id IN (SELECT asset_id FROM risk_assets WHERE risk_register_id = $1) ORDER BY asset_id", id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	assets := []Asset{}
+	for rows.Next() {
+		var a Asset
+		if err := scanAsset(rows, &a); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		assets = append(assets, a)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(assets)
+}
+
+func (app *App) attachRiskAsset(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	var req RiskAssetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.ID == nil && strings.TrimSpace(req.AssetID) == "" {
+		http.Error(w, "id or asset_id is required", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := app.DB.Begin()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	if err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM risk_register WHERE id = $1)", id).Scan(&exists); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !exists {
+		http.Error(w, "Risk not found", http.StatusNotFound)
+		return
+	}
+
+	var a Asset
+	if req.ID != nil {
+		err = scanAsset(tx.QueryRow("SELECT "+assetColumns+" FROM assets WHERE id = $1", *req.ID), &a)
+	} else {
+		err = scanAsset(tx.QueryRow("SELECT "+assetColumns+" FROM assets WHERE LOWER(asset_id) = LOWER($1)", strings.TrimSpace(req.AssetID)), &a)
+	}
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Asset not found", http.StatusNotFound)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	_, err = tx.Exec("INSERT INTO risk_assets (risk_register_id, asset_id) VALUES ($1, $2) ON CONFLICT DO NOTHING", id, a.ID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := deriveRiskImpacts(tx, []int{id}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(a)
+}
+
+func (app *App) detachRiskAsset(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+	assetID, err := strconv.Atoi(vars["assetId"])
+	if err != nil {
+		http.Error(w, "Invalid asset ID", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := app.DB.Begin()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("DELETE FROM risk_assets WHERE risk_register_id = $1 AND asset_id = $2", id, assetID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if rowsAffected == 0 {
+		http.Error(w, "Link not found", http.StatusNotFound)
+		return
+	}
+	if err := deriveRiskImpacts(tx, []int{id}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getAssetRisks lists the risks identified against an asset.
+func (app *App) getAssetRisks(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	var exists bool
+	if err := app.DB.QueryRow("SELECT EXISTS (SELECT 1 FROM assets WHERE id = $1)", id).Scan(&exists); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !exists {
+		http.Error(w, "Asset not found", http.StatusNotFound)
+		return
+	}
+
+	app.queryRisks(w,
+		"SELECT "+riskColumns+" FROM risk_register WHERE id IN (SELECT risk_register_id FROM risk_assets WHERE asset_id = $1) ORDER BY risk_score DESC NULLS LAST, created_at DESC", id)
+}
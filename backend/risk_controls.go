@@ -0,0 +1,437 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+// RiskControl is a gap assessment row (usually an Annex A control) that
+// mitigates a risk.
+type RiskControl struct {
+	RiskRegisterID     int    `json:"risk_register_id"`
+	GapAssessmentID    int    `json:"gap_assessment_id"`
+	StandardRef        string `json:"standard_ref"`
+	Category           string `json:"category"`
+	Section            string `json:"section"`
+	AssessmentQuestion string `json:"assessment_question"`
+	Compliance         string `json:"compliance"`
+	LinkedAt           string `json:"linked_at"`
+}
+
+// RiskControlRequest identifies the control to link, either by gap
+// assessment ID or by its reference ("Control-8.8", "A.8.8" or "8.8").
+type RiskControlRequest struct {
+	GapAssessmentID *int   `json:"gap_assessment_id,omitempty"`
+	StandardRef     string `json:"standard_ref,omitempty"`
+}
+
+// riskControlsText renders a risk's linked controls as the comma-separated
+// annex_a_controls value; it must be selected FROM risk_register.
+const riskControlsText = `COALESCE((SELECT string_agg(DISTINCT g.standard_ref, ', ' ORDER BY g.standard_ref)
+	FROM risk_controls rc JOIN gap_assessments g ON g.id = rc.gap_assessment_id
+	WHERE rc.risk_register_id = risk_register.id), '') AS annex_a_controls`
+
+// newestRiskControlSQL keeps, of the links of risk control rc to rows g with
+// the same standard_ref, only the one in the newest cycle. A risk stays linked
+// to the rows of earlier cycles when they are copied forward.
+const newestRiskControlSQL = `g.cycle_id = (SELECT MAX(g2.cycle_id) FROM risk_controls rc2
+	JOIN gap_assessments g2 ON g2.id = rc2.gap_assessment_id
+	WHERE rc2.risk_register_id = rc.risk_register_id AND g2.standard_ref = g.standard_ref)`
+
+var controlRefSeparator = regexp.MustCompile(`[,;\n]`)
+var controlRefPrefix = regexp.MustCompile(`(?i)^(A\.|Control-)\s*`)
+
+// findControl looks a control up by reference. "8.8" and "A.8.8" both match
+// a gap assessment with standard_ref "Control-8.8"; the row of the current
+// assessment cycle is preferred.
+func findControl(db queryRower, ref string) (int, error) {
+	return matchControl(db, ref, "cycle_id = "+currentCycleSQL+" DESC, ")
+}
+
+// findControlAnyCycle is findControl for migrations that run before
+// assessment cycles exist.
+func findControlAnyCycle(db queryRower, ref string) (int, error) {
+	return matchControl(db, ref, "")
+}
+
+// matchControl runs the lookup of findControl; prefer is a constant ORDER BY
+// prefix ranking candidate rows before the reference itself.
+func matchControl(db queryRower, ref, prefer string) (int, error) {
+	ref = strings.TrimSpace(ref)
+	var id int
+	err := db.QueryRow(
+		`SELECT id FROM gap_assessments
+		 WHERE LOWER(standard_ref) IN (LOWER($1), LOWER($2))
+		 ORDER BY `+prefer+`LOWER(standard_ref) = LOWER($1) DESC, id
+		 LIMIT 1`,
+		ref, "Control-"+controlRefPrefix.ReplaceAllString(ref, ""),
+	).Scan(&id)
+	return id, err
+}
+
+// resolveRiskControls turns a risk's gap_assessment_id and annex_a_controls
+// list into the set of gap assessment IDs it should be linked to. Unknown
+// references are reported as a *riskInputError.
+func resolveRiskControls(db queryRower, risk *RiskRegister) ([]int, error) {
+	var ids []int
+	seen := map[int]bool{}
+	if risk.GapAssessmentID != nil {
+		ids = append(ids, *risk.GapAssessmentID)
+		seen[*risk.GapAssessmentID] = true
+	}
+	for _, ref := range controlRefSeparator.Split(risk.AnnexAControls, -1) {
+		if strings.TrimSpace(ref) == "" {
+			continue
+		}
+		id, err := findControl(db, ref)
+		if err == sql.ErrNoRows {
+			return nil, &riskInputError{fmt.Sprintf("Unknown control %q in annex_a_controls", strings.TrimSpace(ref))}
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !seen[id] {
+			ids = append(ids, id)
+			seen[id] = true
+		}
+	}
+	return ids, nil
+}
+
+// linkedRiskControls lists the controls a risk is linked to now, plus primary
+// if it is set, for updates that leave the links unchanged.
+func linkedRiskControls(db dbExecutor, riskID int, primary *int) ([]int, error) {
+	var ids []int
+	if primary != nil {
+		ids = append(ids, *primary)
+	}
+	rows, err := db.Query("SELECT gap_assessment_id FROM risk_controls WHERE risk_register_id = $1 ORDER BY gap_assessment_id", riskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		if primary == nil || id != *primary {
+			ids = append(ids, id)
+		}
+	}
+	return ids, rows.Err()
+}
+
+// setRiskControls makes ids the set of controls of open cycles linked to a
+// risk. Links to controls of closed cycles are history and kept; adding one
+// is reported as *cycleClosedError.
+func setRiskControls(db dbExecutor, riskID int, ids []int) error {
+	if ids == nil {
+		ids = []int{}
+	}
+	var closed string
+	err := db.QueryRow(
+		`SELECT c.name FROM gap_assessments g JOIN assessment_cycles c ON c.id = g.cycle_id
+		 WHERE c.status <> 'open' AND g.id = ANY($2)
+		   AND NOT EXISTS (SELECT 1 FROM risk_controls WHERE risk_register_id = $1 AND gap_assessment_id = g.id)
+		 LIMIT 1`, riskID, pq.Array(ids),
+	).Scan(&closed)
+	if err == nil {
+		return &cycleClosedError{closed}
+	}
+	if err != sql.ErrNoRows {
+		return err
+	}
+	_, err = db.Exec(
+		`DELETE FROM risk_controls rc USING gap_assessments g, assessment_cycles c
+		 WHERE rc.risk_register_id = $1 AND NOT (rc.gap_assessment_id = ANY($2))
+		   AND g.id = rc.gap_assessment_id AND c.id = g.cycle_id AND c.status = 'open'`,
+		riskID, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := linkRiskControl(db, riskID, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// linkRiskControl records that a control mitigates a risk. Linking twice is
+// harmless.
+func linkRiskControl(db execer, riskID, gapAssessmentID int) error {
+	_, err := db.Exec(
+		"INSERT INTO risk_controls (risk_register_id, gap_assessment_id) VALUES ($1, $2) ON CONFLICT DO NOTHING",
+		riskID, gapAssessmentID,
+	)
+	return err
+}
+
+// saveRiskControls stores a created or updated risk's control links, then
+// recalculates its residual rating and reloads it so the response shows the
+// linked controls.
+func saveRiskControls(db dbExecutor, m *RiskMatrix, risk *RiskRegister, ids []int) error {
+	if err := setRiskControls(db, risk.ID, ids); err != nil {
+		return err
+	}
+	if err := updateResidualRisk(db, m, risk); err != nil {
+		return err
+	}
+	return scanRisk(db.QueryRow("SELECT "+riskColumns+" FROM risk_register WHERE id = $1", risk.ID), risk)
+}
+
+// refreshResidualForRisk recalculates one risk's residual rating after its
+// control links changed.
+func refreshResidualForRisk(db dbExecutor, riskID int) error {
+	m, err := loadRiskMatrix(db)
+	if err != nil {
+		return err
+	}
+	return refreshResidualRisks(db, m, []int{riskID})
+}
+
+// Risk Control Handlers
+func (app *App) getRiskControls(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	var exists bool
+	if err := app.DB.QueryRow("SELECT EXISTS (SELECT 1 FROM risk_register WHERE id = $1)", id).Scan(&exists); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !exists {
+		http.Error(w, "Risk not found", http.StatusNotFound)
+		return
+	}
+
+	rows, err := app.DB.Query(
+		`SELECT rc.risk_register_id, g.id, g.standard_ref, g.category, g.section, g.assessment_question, g.compliance, rc.created_at
+		 FROM risk_controls rc
+		 JOIN gap_assessments g ON g.id = rc.gap_assessment_id
+		 WHERE rc.risk_register_id = $1
+		 ORDER BY g.standard_ref`, id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	controls := []RiskControl{}
+	for rows.Next() {
+		var c RiskControl
+		if err := rows.Scan(&c.RiskRegisterID, &c.GapAssessmentID, &c.StandardRef, &c.Category, &c.Section, &c.AssessmentQuestion, &c.Compliance, &c.LinkedAt); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		controls = append(controls, c)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(controls)
+}
+
+func (app *App) attachRiskControl(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	var req RiskControlRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.GapAssessmentID == nil && strings.TrimSpace(req.StandardRef) == "" {
+		http.Error(w, "gap_assessment_id or standard_ref is required", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := app.DB.Begin()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	if err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM risk_register WHERE id = $1)", id).Scan(&exists); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !exists {
+		http.Error(w, "Risk not found", http.StatusNotFound)
+		return
+	}
+
+	gapID := 0
+	if req.GapAssessmentID != nil {
+		gapID = *req.GapAssessmentID
+	} else if gapID, err = findControl(tx, req.StandardRef); err != nil && err != sql.ErrNoRows {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	c := RiskControl{RiskRegisterID: id}
+	err = tx.QueryRow("SELECT id, standard_ref, category, section, assessment_question, compliance FROM gap_assessments WHERE id = $1", gapID).
+		Scan(&c.GapAssessmentID, &c.StandardRef, &c.Category, &c.Section, &c.AssessmentQuestion, &c.Compliance)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Control not found", http.StatusNotFound)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// Links of a closed cycle's controls are as read-only as the controls
+	if err := checkCycleOpen(tx, "gap_assessments", c.GapAssessmentID); err != nil {
+		writeCycleError(w, err, "Control not found")
+		return
+	}
+	if err := linkRiskControl(tx, id, c.GapAssessmentID); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := tx.QueryRow("SELECT created_at FROM risk_controls WHERE risk_register_id = $1 AND gap_assessment_id = $2", id, c.GapAssessmentID).Scan(&c.LinkedAt); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := refreshResidualForRisk(tx, id); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(c)
+}
+
+func (app *App) detachRiskControl(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+	gapID, err := strconv.Atoi(vars["gapId"])
+	if err != nil {
+		http.Error(w, "Invalid control ID", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := app.DB.Begin()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	if err := checkCycleOpen(tx, "gap_assessments", gapID); err != nil {
+		writeCycleError(w, err, "Link not found")
+		return
+	}
+
+	result, err := tx.Exec("DELETE FROM risk_controls WHERE risk_register_id = $1 AND gap_assessment_id = $2", id, gapID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if rowsAffected == 0 {
+		http.Error(w, "Link not found", http.StatusNotFound)
+		return
+	}
+
+	// The primary control link goes with it, so a later PUT does not restore it
+	if _, err := tx.Exec("UPDATE risk_register SET gap_assessment_id = NULL WHERE id = $1 AND gap_assessment_id = $2", id, gapID); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := refreshResidualForRisk(tx, id); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getControlRisks lists the risks a control mitigates.
+func (app *App) getControlRisks(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	var exists bool
+	if err := app.DB.QueryRow("SELECT EXISTS (SELECT 1 FROM gap_assessments WHERE id = $1)", id).Scan(&exists); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !exists {
+		http.Error(w, "Assessment not found", http.StatusNotFound)
+		return
+	}
+
+	app.queryRisks(w,
+		"SELECT "+riskColumns+" FROM risk_register WHERE id IN (SELECT risk_register_id FROM risk_controls WHERE gap_assessment_id = $1) ORDER BY risk_score DESC NULLS LAST, created_at DESC", id)
+}
+
+// getUncontrolledRisks lists risks that no control mitigates yet.
+func (app *App) getUncontrolledRisks(w http.ResponseWriter, r *http.Request) {
+	app.queryRisks(w,
+		"SELECT "+riskColumns+" FROM risk_register WHERE NOT EXISTS (SELECT 1 FROM risk_controls rc WHERE rc.risk_register_id = risk_register.id) ORDER BY risk_score DESC NULLS LAST, created_at DESC")
+}
+
+func (app *App) queryRisks(w http.ResponseWriter, query string, args ...interface{}) {
+	rows, err := app.DB.Query(query, args...)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	risks := []RiskRegister{}
+	for rows.Next() {
+		var risk RiskRegister
+		if err := scanRisk(rows, &risk); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		risks = append(risks, risk)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(risks)
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RiskHeatmapCell is one likelihood × impact cell of the heatmap.
+type RiskHeatmapCell struct {
+	Likelihood string   `json:"likelihood"`
+	Impact     string   `json:"impact"`
+	Score      int      `json:"score"`
+	Level      string   `json:"level"`
+	Count      int      `json:"count"`
+	RiskIDs    []string `json:"risk_ids"`
+}
+
+// RiskBreakdown counts risks sharing one value of a dimension.
+type RiskBreakdown struct {
+	Key   string `json:"key"`
+	Count int    `json:"count"`
+}
+
+// RiskHeatmap is the risk posture: the heatmap plus breakdowns for reporting.
+type RiskHeatmap struct {
+	Basis             string            `json:"basis"`
+	From              *string           `json:"from,omitempty"`
+	To                *string           `json:"to,omitempty"`
+	Total             int               `json:"total"`
+	Likelihood        []string          `json:"likelihood"`
+	Impact            []string          `json:"impact"`
+	Cells             []RiskHeatmapCell `json:"cells"`
+	Unplaced          []string          `json:"unplaced"`
+	ByLevel           []RiskBreakdown   `json:"by_level"`
+	ByCategory        []RiskBreakdown   `json:"by_category"`
+	ByOwner           []RiskBreakdown   `json:"by_owner"`
+	ByTreatmentStatus []RiskBreakdown   `json:"by_treatment_status"`
+}
+
+// sortedLabels returns an axis ordered from lowest to highest weight.
+func sortedLabels(labels []RiskMatrixLabel) []RiskMatrixLabel {
+	sorted := append([]RiskMatrixLabel(nil), labels...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Weight < sorted[j].Weight })
+	return sorted
+}
+
+// breakdown turns counts into a list ordered by count, then key.
+func breakdown(counts map[string]int) []RiskBreakdown {
+	list := make([]RiskBreakdown, 0, len(counts))
+	for k, n := range counts {
+		list = append(list, RiskBreakdown{k, n})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Count != list[j].Count {
+			return list[i].Count > list[j].Count
+		}
+		return list[i].Key < list[j].Key
+	})
+	return list
+}
+
+// getRiskHeatmap aggregates the register on the server. Query parameters:
+// basis=inherent|residual (default inherent), and from/to (YYYY-MM-DD,
+// inclusive) to limit it to risks created in that period.
+func (app *App) getRiskHeatmap(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	basis := strings.ToLower(q.Get("basis"))
+	if basis == "" {
+		basis = "inherent"
+	}
+	if basis != "inherent" && basis != "residual" {
+		http.Error(w, "basis must be inherent or residual", http.StatusBadRequest)
+		return
+	}
+
+	heatmap := RiskHeatmap{Basis: basis}
+	var conditions []string
+	var args []interface{}
+	for _, p := range []struct {
+		name  string
+		op    string
+		shift int
+		dest  **string
+	}{{"from", ">=", 0, &heatmap.From}, {"to", "<", 1, &heatmap.To}} {
+		v := q.Get(p.name)
+		if v == "" {
+			continue
+		}
+		d, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Invalid %s date; expected YYYY-MM-DD", p.name), http.StatusBadRequest)
+			return
+		}
+		*p.dest = &v
+		args = append(args, d.AddDate(0, 0, p.shift))
+		conditions = append(conditions, fmt.Sprintf("created_at %s $%d", p.op, len(args)))
+	}
+
+	m, err := loadRiskMatrix(app.DB)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	likelihoodCol, impactCol, levelCol := "likelihood", "impact", "risk_level"
+	if basis == "residual" {
+		likelihoodCol = "COALESCE(residual_likelihood, likelihood)"
+		impactCol = "COALESCE(residual_impact, impact)"
+		levelCol = "COALESCE(residual_level, risk_level)"
+	}
+	query := "SELECT risk_id, " + likelihoodCol + ", " + impactCol + ", " + levelCol + ", COALESCE(category, ''), COALESCE(owner, ''), COALESCE(treatment_status, '') FROM risk_register"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY risk_score DESC NULLS LAST, risk_id"
+
+	rows, err := app.DB.Query(query, args...)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	likelihoods, impacts := sortedLabels(m.Likelihood), sortedLabels(m.Impact)
+	cells := map[[2]string]*RiskHeatmapCell{}
+	for _, l := range likelihoods {
+		heatmap.Likelihood = append(heatmap.Likelihood, l.Label)
+	}
+	for _, i := range impacts {
+		heatmap.Impact = append(heatmap.Impact, i.Label)
+	}
+	for _, l := range likelihoods {
+		for _, i := range impacts {
+			score := l.Weight * i.Weight
+			heatmap.Cells = append(heatmap.Cells, RiskHeatmapCell{
+				Likelihood: l.Label, Impact: i.Label, Score: score, Level: m.Level(score), RiskIDs: []string{},
+			})
+		}
+	}
+	for idx := range heatmap.Cells {
+		c := &heatmap.Cells[idx]
+		cells[[2]string{strings.ToLower(c.Likelihood), strings.ToLower(c.Impact)}] = c
+	}
+
+	heatmap.Unplaced = []string{}
+	levels, categories, owners, statuses := map[string]int{}, map[string]int{}, map[string]int{}, map[string]int{}
+	for rows.Next() {
+		var riskID, likelihood, impact, level, category, owner, status string
+		if err := rows.Scan(&riskID, &likelihood, &impact, &level, &category, &owner, &status); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		heatmap.Total++
+		if c, ok := cells[[2]string{strings.ToLower(strings.TrimSpace(likelihood)), strings.ToLower(strings.TrimSpace(impact))}]; ok {
+			c.Count++
+			c.RiskIDs = append(c.RiskIDs, riskID)
+		} else {
+			heatmap.Unplaced = append(heatmap.Unplaced, riskID)
+		}
+		levels[level]++
+		categories[orUnspecified(category)]++
+		owners[orUnspecified(owner)]++
+		statuses[orUnspecified(status)]++
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	heatmap.ByLevel = breakdown(levels)
+	heatmap.ByCategory = breakdown(categories)
+	heatmap.ByOwner = breakdown(owners)
+	heatmap.ByTreatmentStatus = breakdown(statuses)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(heatmap)
+}
+
+func orUnspecified(s string) string {
+	if strings.TrimSpace(s) == "" {
+		return "Unspecified"
+	}
+	return s
+}
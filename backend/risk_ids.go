@@ -0,0 +1,133 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultRiskIDPattern is used when RISK_ID_PATTERN is not set.
+const defaultRiskIDPattern = "R-{YYYY}-{seq:4}"
+
+// RiskIDPolicy controls how risk identifiers are assigned.
+type RiskIDPolicy struct {
+	Pattern     string
+	AllowClient bool // accept a risk_id supplied by the client
+}
+
+// riskIDToken matches {YYYY}, {YY}, {MM}, {seq} and {seq:N} in a pattern.
+var riskIDToken = regexp.MustCompile(`\{([A-Za-z]+)(?::(\d+))?\}`)
+
+// newRiskIDPolicyFromEnv reads RISK_ID_PATTERN and RISK_ID_ALLOW_CLIENT.
+func newRiskIDPolicyFromEnv() (*RiskIDPolicy, error) {
+	allow, err := strconv.ParseBool(getEnv("RISK_ID_ALLOW_CLIENT", "false"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid RISK_ID_ALLOW_CLIENT %q", getEnv("RISK_ID_ALLOW_CLIENT", ""))
+	}
+	policy := &RiskIDPolicy{Pattern: getEnv("RISK_ID_PATTERN", defaultRiskIDPattern), AllowClient: allow}
+
+	seqs := 0
+	for _, m := range riskIDToken.FindAllStringSubmatch(policy.Pattern, -1) {
+		switch m[1] {
+		case "seq":
+			seqs++
+		case "YYYY", "YY", "MM":
+			if m[2] != "" {
+				return nil, fmt.Errorf("RISK_ID_PATTERN token {%s} does not take a width", m[1])
+			}
+		default:
+			return nil, fmt.Errorf("unknown RISK_ID_PATTERN token {%s}", m[1])
+		}
+	}
+	if seqs != 1 {
+		return nil, errors.New("RISK_ID_PATTERN must contain {seq} exactly once")
+	}
+	return policy, nil
+}
+
+// render fills in the pattern for date t. A negative seq leaves the {seq}
+// token in place, which gives the scope the counter is kept per: with
+// R-{YYYY}-{seq:4} numbering restarts every year.
+func (p *RiskIDPolicy) render(t time.Time, seq int) string {
+	return riskIDToken.ReplaceAllStringFunc(p.Pattern, func(token string) string {
+		m := riskIDToken.FindStringSubmatch(token)
+		switch m[1] {
+		case "YYYY":
+			return t.Format("2006")
+		case "YY":
+			return t.Format("06")
+		case "MM":
+			return t.Format("01")
+		}
+		if seq < 0 {
+			return "{seq}"
+		}
+		width, _ := strconv.Atoi(m[2])
+		return fmt.Sprintf("%0*d", width, seq)
+	})
+}
+
+// nextRiskID allocates the next identifier from the pattern. The counter row
+// stays locked until tx ends, so concurrent creates cannot get the same
+// number and a rolled-back create gives its number back. Numbers already
+// taken, e.g. by IDs entered by hand, are skipped.
+func (p *RiskIDPolicy) nextRiskID(tx *sql.Tx, now time.Time) (string, error) {
+	scope := p.render(now, -1)
+	for {
+		var seq int
+		err := tx.QueryRow(
+			`INSERT INTO risk_id_sequences (scope, last_value) VALUES ($1, 1)
+			 ON CONFLICT (scope) DO UPDATE SET last_value = risk_id_sequences.last_value + 1
+			 RETURNING last_value`, scope,
+		).Scan(&seq)
+		if err != nil {
+			return "", err
+		}
+		id := p.render(now, seq)
+
+		var taken bool
+		if err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM risk_register WHERE risk_id = $1)", id).Scan(&taken); err != nil {
+			return "", err
+		}
+		if !taken {
+			return id, nil
+		}
+	}
+}
+
+// assignRiskID sets the identifier of a new risk: the client's when allowed,
+// otherwise the next one from the pattern.
+func (app *App) assignRiskID(tx *sql.Tx, risk *RiskRegister) error {
+	risk.RiskID = strings.TrimSpace(risk.RiskID)
+	if risk.RiskID != "" {
+		if !app.RiskIDs.AllowClient {
+			return &riskInputError{"risk_id is assigned by the server; leave it empty"}
+		}
+		return nil
+	}
+	id, err := app.RiskIDs.nextRiskID(tx, time.Now())
+	if err != nil {
+		return err
+	}
+	risk.RiskID = id
+	return nil
+}
+
+// writeRiskSaveError answers a failed risk insert or update, turning a
+// duplicate risk_id or a control link of a closed cycle into 409.
+func writeRiskSaveError(w http.ResponseWriter, risk *RiskRegister, err error) {
+	var ce *cycleClosedError
+	switch {
+	case isUniqueViolation(err):
+		http.Error(w, fmt.Sprintf("Risk ID %q is already in use", risk.RiskID), http.StatusConflict)
+	case errors.As(err, &ce):
+		http.Error(w, ce.Error(), http.StatusConflict)
+	default:
+		writeRiskError(w, err)
+	}
+}
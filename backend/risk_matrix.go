@@ -0,0 +1,369 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// RiskMatrixLabel is one step on the likelihood or impact axis.
+type RiskMatrixLabel struct {
+	Label  string `json:"label"`
+	Weight int    `json:"weight"`
+}
+
+// RiskThreshold maps scores of at least MinScore to a risk level.
+type RiskThreshold struct {
+	Level    string `json:"level"`
+	MinScore int    `json:"min_score"`
+}
+
+// RiskMatrix is the likelihood × impact matrix used to score every risk.
+// A risk's score is likelihood weight × impact weight; its level is the
+// threshold with the highest MinScore the score reaches.
+type RiskMatrix struct {
+	Likelihood []RiskMatrixLabel `json:"likelihood"`
+	Impact     []RiskMatrixLabel `json:"impact"`
+	Thresholds []RiskThreshold   `json:"thresholds"`
+	UpdatedAt  *string           `json:"updated_at,omitempty"`
+}
+
+// defaultRiskMatrix is the 5×5 matrix the risk register UI has always used.
+func defaultRiskMatrix() *RiskMatrix {
+	labels := func() []RiskMatrixLabel {
+		return []RiskMatrixLabel{
+			{"Very Low", 1}, {"Low", 2}, {"Medium", 3}, {"High", 4}, {"Very High", 5},
+		}
+	}
+	return &RiskMatrix{
+		Likelihood: labels(),
+		Impact:     labels(),
+		Thresholds: []RiskThreshold{
+			{"Critical", 20}, {"Very High", 15}, {"High", 10}, {"Medium", 6}, {"Low", 3}, {"Very Low", 0},
+		},
+	}
+}
+
+// riskInputError is a client mistake in risk or matrix input (answered with 400).
+type riskInputError struct {
+	Message string
+}
+
+func (e *riskInputError) Error() string {
+	return e.Message
+}
+
+// validate checks the matrix is usable and sorts thresholds from highest to lowest.
+func (m *RiskMatrix) validate() error {
+	for _, axis := range []struct {
+		name   string
+		labels []RiskMatrixLabel
+	}{{"likelihood", m.Likelihood}, {"impact", m.Impact}} {
+		if len(axis.labels) < 2 || len(axis.labels) > 10 {
+			return &riskInputError{fmt.Sprintf("%s must have between 2 and 10 labels", axis.name)}
+		}
+		seen := map[string]bool{}
+		for _, l := range axis.labels {
+			key := strings.ToLower(strings.TrimSpace(l.Label))
+			if key == "" {
+				return &riskInputError{fmt.Sprintf("%s labels must not be empty", axis.name)}
+			}
+			if seen[key] {
+				return &riskInputError{fmt.Sprintf("duplicate %s label %q", axis.name, l.Label)}
+			}
+			if l.Weight <= 0 {
+				return &riskInputError{fmt.Sprintf("%s label %q must have a positive weight", axis.name, l.Label)}
+			}
+			seen[key] = true
+		}
+	}
+
+	if len(m.Thresholds) == 0 {
+		return &riskInputError{"at least one threshold is required"}
+	}
+	seen := map[string]bool{}
+	for _, t := range m.Thresholds {
+		key := strings.ToLower(strings.TrimSpace(t.Level))
+		if key == "" {
+			return &riskInputError{"threshold levels must not be empty"}
+		}
+		if seen[key] {
+			return &riskInputError{fmt.Sprintf("duplicate threshold level %q", t.Level)}
+		}
+		seen[key] = true
+	}
+	sort.SliceStable(m.Thresholds, func(i, j int) bool {
+		return m.Thresholds[i].MinScore > m.Thresholds[j].MinScore
+	})
+	if lowest := m.Thresholds[len(m.Thresholds)-1].MinScore; lowest > minWeight(m.Likelihood)*minWeight(m.Impact) {
+		return &riskInputError{fmt.Sprintf("the lowest threshold (%d) must cover the lowest possible score (%d)", lowest, minWeight(m.Likelihood)*minWeight(m.Impact))}
+	}
+	return nil
+}
+
+func minWeight(labels []RiskMatrixLabel) int {
+	min := labels[0].Weight
+	for _, l := range labels[1:] {
+		if l.Weight < min {
+			min = l.Weight
+		}
+	}
+	return min
+}
+
+// axisLabel finds a label case-insensitively.
+func axisLabel(labels []RiskMatrixLabel, label string) (RiskMatrixLabel, bool) {
+	for _, l := range labels {
+		if strings.EqualFold(l.Label, strings.TrimSpace(label)) {
+			return l, true
+		}
+	}
+	return RiskMatrixLabel{}, false
+}
+
+func axisLabels(labels []RiskMatrixLabel) string {
+	names := make([]string, len(labels))
+	for i, l := range labels {
+		names[i] = l.Label
+	}
+	return strings.Join(names, ", ")
+}
+
+// Score returns the numeric score and level for a likelihood/impact pair.
+// Unknown labels are reported as a *riskInputError.
+func (m *RiskMatrix) Score(likelihood, impact string) (int, string, error) {
+	l, ok := axisLabel(m.Likelihood, likelihood)
+	if !ok {
+		return 0, "", &riskInputError{fmt.Sprintf("Unknown likelihood %q; expected one of: %s", likelihood, axisLabels(m.Likelihood))}
+	}
+	i, ok := axisLabel(m.Impact, impact)
+	if !ok {
+		return 0, "", &riskInputError{fmt.Sprintf("Unknown impact %q; expected one of: %s", impact, axisLabels(m.Impact))}
+	}
+	score := l.Weight * i.Weight
+	return score, m.Level(score), nil
+}
+
+// Level maps a score to the matching threshold level.
+func (m *RiskMatrix) Level(score int) string {
+	for _, t := range m.Thresholds {
+		if score >= t.MinScore {
+			return t.Level
+		}
+	}
+	return m.Thresholds[len(m.Thresholds)-1].Level
+}
+
+// queryRower is satisfied by both *sql.DB and *sql.Tx.
+type queryRower interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// loadRiskMatrix returns the stored matrix, or the default one if none has
+// been saved yet.
+func loadRiskMatrix(db queryRower) (*RiskMatrix, error) {
+	var raw []byte
+	var updatedAt string
+	err := db.QueryRow("SELECT config, updated_at FROM risk_matrix WHERE id = 1").Scan(&raw, &updatedAt)
+	if err == sql.ErrNoRows {
+		return defaultRiskMatrix(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var m RiskMatrix
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return nil, fmt.Errorf("invalid stored risk matrix: %v", err)
+	}
+	if err := m.validate(); err != nil {
+		return nil, fmt.Errorf("invalid stored risk matrix: %v", err)
+	}
+	m.UpdatedAt = &updatedAt
+	return &m, nil
+}
+
+// scoreRisk sets a risk's score and level from its likelihood and impact,
+// normalising both to the matrix's spelling.
+func scoreRisk(m *RiskMatrix, risk *RiskRegister) error {
+	score, level, err := m.Score(risk.Likelihood, risk.Impact)
+	if err != nil {
+		return err
+	}
+	l, _ := axisLabel(m.Likelihood, risk.Likelihood)
+	i, _ := axisLabel(m.Impact, risk.Impact)
+	risk.Likelihood, risk.Impact = l.Label, i.Label
+	risk.RiskScore = &score
+	risk.RiskLevel = level
+	return nil
+}
+
+// rescoreRisks recalculates every risk against m. When strict is set, risks
+// whose labels are not on the matrix are reported as a *riskInputError;
+// otherwise they keep their current level and get no score.
+func rescoreRisks(tx *sql.Tx, m *RiskMatrix, strict bool) (int, error) {
+	rows, err := tx.Query("SELECT id, risk_id, likelihood, impact FROM risk_register ORDER BY id")
+	if err != nil {
+		return 0, err
+	}
+	type scored struct {
+		id    int
+		score *int
+		level string
+	}
+	var updates []scored
+	var unknown []string
+	for rows.Next() {
+		var id int
+		var riskID, likelihood, impact string
+		if err := rows.Scan(&id, &riskID, &likelihood, &impact); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		score, level, err := m.Score(likelihood, impact)
+		if err != nil {
+			unknown = append(unknown, riskID)
+			continue
+		}
+		updates = append(updates, scored{id, &score, level})
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return 0, err
+	}
+	if strict && len(unknown) > 0 {
+		return 0, &riskInputError{fmt.Sprintf("Risks %s use likelihood or impact labels that are not on the matrix", strings.Join(unknown, ", "))}
+	}
+
+	for _, u := range updates {
+		if _, err := tx.Exec("UPDATE risk_register SET risk_score = $1, risk_level = $2 WHERE id = $3", u.score, u.level, u.id); err != nil {
+			return 0, err
+		}
+	}
+	return len(updates), nil
+}
+
+// scoreUnscoredRisks gives risks created before server-side scoring an
+// inherent score and a residual rating.
+func (app *App) scoreUnscoredRisks() error {
+	var pending bool
+	if err := app.DB.QueryRow("SELECT EXISTS (SELECT 1 FROM risk_register WHERE risk_score IS NULL OR residual_score IS NULL)").Scan(&pending); err != nil || !pending {
+		return err
+	}
+
+	tx, err := app.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	m, err := loadRiskMatrix(tx)
+	if err != nil {
+		return err
+	}
+	if _, err := rescoreRisks(tx, m, false); err != nil {
+		return err
+	}
+	if err := refreshResidualRisks(tx, m, nil); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// writeRiskError answers input errors with 400 and anything else with 500.
+func writeRiskError(w http.ResponseWriter, err error) {
+	var ie *riskInputError
+	if errors.As(err, &ie) {
+		http.Error(w, ie.Message, http.StatusBadRequest)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+// Risk Matrix Handlers
+func (app *App) getRiskMatrix(w http.ResponseWriter, r *http.Request) {
+	m, err := loadRiskMatrix(app.DB)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(m)
+}
+
+// updateRiskMatrix replaces the matrix and rescores every risk (inherent and
+// residual) with it. A matrix that drops labels still used by existing risks
+// is refused with 409.
+func (app *App) updateRiskMatrix(w http.ResponseWriter, r *http.Request) {
+	var m RiskMatrix
+	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := m.validate(); err != nil {
+		writeRiskError(w, err)
+		return
+	}
+	m.UpdatedAt = nil
+
+	config, err := json.Marshal(m)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	tx, err := app.DB.Begin()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	var updatedAt string
+	err = tx.QueryRow(
+		`INSERT INTO risk_matrix (id, config) VALUES (1, $1)
+		 ON CONFLICT (id) DO UPDATE SET config = EXCLUDED.config, updated_at = CURRENT_TIMESTAMP
+		 RETURNING updated_at`, config,
+	).Scan(&updatedAt)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if _, err := rescoreRisks(tx, &m, true); err != nil {
+		var ie *riskInputError
+		if errors.As(err, &ie) {
+			http.Error(w, ie.Message, http.StatusConflict)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	if err := refreshResidualRisks(tx, &m, nil); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := checkAppetiteLevels(tx, &m); err != nil {
+		var ie *riskInputError
+		if errors.As(err, &ie) {
+			http.Error(w, ie.Message, http.StatusConflict)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	m.UpdatedAt = &updatedAt
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(m)
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+)
+
+const (
+	defaultLossIterations = 10000
+	maxLossIterations     = 100000
+	defaultLossSeed       = 1
+	// maxLossFrequency caps the loss events per year a risk may estimate.
+	maxLossFrequency = 1000
+	// exactLossEvents is the largest number of events in a year whose losses
+	// are drawn one by one; more are summed with a normal approximation.
+	exactLossEvents = 50
+)
+
+// lossExceedanceProbabilities are the points of the loss-exceedance curve.
+var lossExceedanceProbabilities = []float64{0.99, 0.95, 0.90, 0.80, 0.70, 0.60, 0.50, 0.40, 0.30, 0.20, 0.10, 0.05, 0.02, 0.01, 0.001}
+
+// LossDistribution summarises simulated annual losses. ALE is the mean, the
+// annualized loss expectancy.
+type LossDistribution struct {
+	ALE float64 `json:"ale"`
+	P10 float64 `json:"p10"`
+	P50 float64 `json:"p50"`
+	P90 float64 `json:"p90"`
+	P95 float64 `json:"p95"`
+	P99 float64 `json:"p99"`
+	Max float64 `json:"max"`
+}
+
+// RiskLossEstimate is the simulated annual loss of one risk.
+type RiskLossEstimate struct {
+	ID     int    `json:"id"`
+	RiskID string `json:"risk_id"`
+	Title  string `json:"title"`
+	LossDistribution
+	ProbabilityOfLoss float64 `json:"probability_of_loss"`
+}
+
+// LossExceedancePoint is the probability that total annual loss reaches Loss.
+type LossExceedancePoint struct {
+	Loss        float64 `json:"loss"`
+	Probability float64 `json:"probability"`
+}
+
+// LossSimulation is the result of a Monte Carlo run over every risk with
+// quantitative inputs.
+type LossSimulation struct {
+	Iterations      int                   `json:"iterations"`
+	Seed            int64                 `json:"seed"`
+	Risks           []RiskLossEstimate    `json:"risks"`
+	Portfolio       LossDistribution      `json:"portfolio"`
+	ExceedanceCurve []LossExceedancePoint `json:"exceedance_curve"`
+	Unquantified    []string              `json:"unquantified"`
+}
+
+// pertRange is a FAIR-style min / most likely / max estimate.
+type pertRange struct {
+	min, mode, max float64
+}
+
+// validateLossInputs checks the optional quantitative inputs of a risk: either
+// none or all six are set, none is negative and each range is ordered.
+func validateLossInputs(risk *RiskRegister) error {
+	fields := []*float64{risk.FrequencyMin, risk.FrequencyMostLikely, risk.FrequencyMax, risk.LossMin, risk.LossMostLikely, risk.LossMax}
+	set := 0
+	for _, f := range fields {
+		if f != nil {
+			set++
+			if *f < 0 || math.IsNaN(*f) || math.IsInf(*f, 0) {
+				return &riskInputError{"frequency and loss estimates must be non-negative numbers"}
+			}
+		}
+	}
+	if set == 0 {
+		return nil
+	}
+	if set != len(fields) {
+		return &riskInputError{"frequency_min, frequency_most_likely, frequency_max, loss_min, loss_most_likely and loss_max must be given together"}
+	}
+	if !(*risk.FrequencyMin <= *risk.FrequencyMostLikely && *risk.FrequencyMostLikely <= *risk.FrequencyMax) {
+		return &riskInputError{"frequency estimates must satisfy frequency_min <= frequency_most_likely <= frequency_max"}
+	}
+	if *risk.FrequencyMax > maxLossFrequency {
+		return &riskInputError{fmt.Sprintf("frequency_max must be at most %d loss events per year", maxLossFrequency)}
+	}
+	if !(*risk.LossMin <= *risk.LossMostLikely && *risk.LossMostLikely <= *risk.LossMax) {
+		return &riskInputError{"loss estimates must satisfy loss_min <= loss_most_likely <= loss_max"}
+	}
+	return nil
+}
+
+// samplePERT draws from the Beta-PERT distribution of r.
+func samplePERT(rng *rand.Rand, r pertRange) float64 {
+	if r.max <= r.min {
+		return r.min
+	}
+	alpha := 1 + 4*(r.mode-r.min)/(r.max-r.min)
+	beta := 1 + 4*(r.max-r.mode)/(r.max-r.min)
+	x, y := sampleGamma(rng, alpha), sampleGamma(rng, beta)
+	return r.min + (r.max-r.min)*x/(x+y)
+}
+
+// sumPERT draws the total loss of n events from r. Beyond exactLossEvents the
+// sum is drawn from its normal approximation, which keeps a simulation's cost
+// independent of how frequent the events are.
+func sumPERT(rng *rand.Rand, r pertRange, n int) float64 {
+	if n <= exactLossEvents {
+		total := 0.0
+		for e := 0; e < n; e++ {
+			total += samplePERT(rng, r)
+		}
+		return total
+	}
+	mean := (r.min + 4*r.mode + r.max) / 6
+	variance := (mean - r.min) * (r.max - mean) / 7
+	total := float64(n)*mean + math.Sqrt(float64(n)*variance)*rng.NormFloat64()
+	return math.Min(math.Max(total, float64(n)*r.min), float64(n)*r.max)
+}
+
+// sampleGamma draws from Gamma(shape, 1) for shape >= 1 (Marsaglia and Tsang).
+func sampleGamma(rng *rand.Rand, shape float64) float64 {
+	d := shape - 1.0/3
+	c := 1 / math.Sqrt(9*d)
+	for {
+		x := rng.NormFloat64()
+		v := 1 + c*x
+		if v <= 0 {
+			continue
+		}
+		v = v * v * v
+		u := rng.Float64()
+		if math.Log(u) < 0.5*x*x+d-d*v+d*math.Log(v) {
+			return d * v
+		}
+	}
+}
+
+// samplePoisson draws the number of loss events in a year with the given
+// mean frequency, using a normal approximation for frequent events.
+func samplePoisson(rng *rand.Rand, lambda float64) int {
+	if lambda <= 0 {
+		return 0
+	}
+	if lambda > 30 {
+		return int(math.Max(0, math.Round(lambda+math.Sqrt(lambda)*rng.NormFloat64())))
+	}
+	limit, n, p := math.Exp(-lambda), 0, rng.Float64()
+	for p > limit {
+		n++
+		p *= rng.Float64()
+	}
+	return n
+}
+
+// simulateAnnualLoss returns one simulated annual loss per iteration: the
+// event frequency is drawn from its range, the number of events from a
+// Poisson distribution with that mean, and each event's loss from the loss
+// magnitude range.
+func simulateAnnualLoss(rng *rand.Rand, frequency, loss pertRange, iterations int) []float64 {
+	losses := make([]float64, iterations)
+	for i := range losses {
+		losses[i] = sumPERT(rng, loss, samplePoisson(rng, samplePERT(rng, frequency)))
+	}
+	return losses
+}
+
+// quantile returns the value below which fraction p of sorted lies.
+func quantile(sorted []float64, p float64) float64 {
+	idx := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	if idx >= len(sorted) {
+		idx = len(sorted) - 1
+	}
+	return sorted[idx]
+}
+
+func roundMoney(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// summarizeLosses sorts losses in place and describes their distribution.
+func summarizeLosses(losses []float64) LossDistribution {
+	sort.Float64s(losses)
+	sum := 0.0
+	for _, l := range losses {
+		sum += l
+	}
+	return LossDistribution{
+		ALE: roundMoney(sum / float64(len(losses))),
+		P10: roundMoney(quantile(losses, 0.10)),
+		P50: roundMoney(quantile(losses, 0.50)),
+		P90: roundMoney(quantile(losses, 0.90)),
+		P95: roundMoney(quantile(losses, 0.95)),
+		P99: roundMoney(quantile(losses, 0.99)),
+		Max: roundMoney(losses[len(losses)-1]),
+	}
+}
+
+// riskSeed gives every risk its own random stream, so a risk's estimate does
+// not change when other risks are added or removed.
+func riskSeed(seed int64, riskID int) int64 {
+	return int64(uint64(seed) ^ uint64(riskID)*0x9E3779B97F4A7C15)
+}
+
+// getLossSimulation runs a Monte Carlo simulation of annual losses for every
+// risk with quantitative inputs. Query parameters: iterations (default
+// 10000, at most 100000) and seed (default 1); the same seed and inputs
+// always give the same result.
+func (app *App) getLossSimulation(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	iterations := defaultLossIterations
+	if v := q.Get("iterations"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 100 || n > maxLossIterations {
+			http.Error(w, fmt.Sprintf("iterations must be a number between 100 and %d", maxLossIterations), http.StatusBadRequest)
+			return
+		}
+		iterations = n
+	}
+	seed := int64(defaultLossSeed)
+	if v := q.Get("seed"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			http.Error(w, "seed must be an integer", http.StatusBadRequest)
+			return
+		}
+		seed = n
+	}
+
+	rows, err := app.DB.Query("SELECT " + riskColumns + " FROM risk_register ORDER BY id")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	sim := LossSimulation{Iterations: iterations, Seed: seed, Risks: []RiskLossEstimate{}, Unquantified: []string{}}
+	portfolio := make([]float64, iterations)
+	for rows.Next() {
+		var risk RiskRegister
+		if err := scanRisk(rows, &risk); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if risk.FrequencyMin == nil || risk.FrequencyMostLikely == nil || risk.FrequencyMax == nil ||
+			risk.LossMin == nil || risk.LossMostLikely == nil || risk.LossMax == nil {
+			sim.Unquantified = append(sim.Unquantified, risk.RiskID)
+			continue
+		}
+
+		rng := rand.New(rand.NewSource(riskSeed(seed, risk.ID)))
+		losses := simulateAnnualLoss(rng,
+			pertRange{*risk.FrequencyMin, *risk.FrequencyMostLikely, *risk.FrequencyMax},
+			pertRange{*risk.LossMin, *risk.LossMostLikely, *risk.LossMax},
+			iterations)
+		withLoss := 0
+		for i, l := range losses {
+			portfolio[i] += l
+			if l > 0 {
+				withLoss++
+			}
+		}
+		sim.Risks = append(sim.Risks, RiskLossEstimate{
+			ID:                risk.ID,
+			RiskID:            risk.RiskID,
+			Title:             risk.Title,
+			LossDistribution:  summarizeLosses(losses),
+			ProbabilityOfLoss: float64(withLoss) / float64(iterations),
+		})
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	sort.SliceStable(sim.Risks, func(i, j int) bool { return sim.Risks[i].ALE > sim.Risks[j].ALE })
+	sim.Portfolio = summarizeLosses(portfolio)
+	sim.ExceedanceCurve = []LossExceedancePoint{}
+	for _, p := range lossExceedanceProbabilities {
+		loss := quantile(portfolio, 1-p)
+		// Share of simulated years whose total loss reaches this amount
+		reached := len(portfolio) - sort.SearchFloat64s(portfolio, loss)
+		sim.ExceedanceCurve = append(sim.ExceedanceCurve, LossExceedancePoint{
+			Loss:        roundMoney(loss),
+			Probability: float64(reached) / float64(len(portfolio)),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sim)
+}
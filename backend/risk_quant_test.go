@@ -0,0 +1,74 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestSimulateAnnualLossIsSeeded(t *testing.T) {
+	tests := []struct {
+		name            string
+		frequency, loss pertRange
+		want            LossDistribution
+	}{
+		{
+			// Few events a year, each loss drawn on its own
+			name:      "rare events",
+			frequency: pertRange{0.5, 2, 6},
+			loss:      pertRange{1000, 5000, 50000},
+			want:      LossDistribution{ALE: 29654.94, P10: 0, P50: 24577.11, P90: 63089.84, P95: 77108.82, P99: 110348.92, Max: 120913.12},
+		},
+		{
+			// Hundreds of events a year, summed with the normal approximation
+			name:      "frequent events",
+			frequency: pertRange{200, 400, 1000},
+			loss:      pertRange{10, 50, 200},
+			want:      LossDistribution{ALE: 32121.39, P10: 19572.38, P50: 30966.26, P90: 46249.67, P95: 50888.78, P99: 57610.67, Max: 64428.62},
+		},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for run := 0; run < 2; run++ {
+				rng := rand.New(rand.NewSource(riskSeed(defaultLossSeed, i+1)))
+				got := summarizeLosses(simulateAnnualLoss(rng, tt.frequency, tt.loss, 1000))
+				if got != tt.want {
+					t.Fatalf("run %d: got %+v, want %+v", run, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestSumPERTApproximationMatchesMean(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	loss := pertRange{10, 50, 200}
+	mean := (loss.min + 4*loss.mode + loss.max) / 6
+	const events, years = maxLossFrequency, 2000
+	total := 0.0
+	for i := 0; i < years; i++ {
+		sum := sumPERT(rng, loss, events)
+		if sum < events*loss.min || sum > events*loss.max {
+			t.Fatalf("sum %v outside [%v, %v]", sum, events*loss.min, events*loss.max)
+		}
+		total += sum
+	}
+	if got, want := total/years, events*mean; math.Abs(got-want) > 0.01*want {
+		t.Errorf("mean annual loss %v, want about %v", got, want)
+	}
+}
+
+func TestValidateLossInputsCapsFrequency(t *testing.T) {
+	f := func(v float64) *float64 { return &v }
+	risk := RiskRegister{
+		FrequencyMin: f(1), FrequencyMostLikely: f(10), FrequencyMax: f(maxLossFrequency),
+		LossMin: f(100), LossMostLikely: f(1000), LossMax: f(10000),
+	}
+	if err := validateLossInputs(&risk); err != nil {
+		t.Fatalf("frequency_max at the cap rejected: %v", err)
+	}
+	risk.FrequencyMax = f(1e6)
+	if _, ok := validateLossInputs(&risk).(*riskInputError); !ok {
+		t.Errorf("frequency_max 1e6 accepted")
+	}
+}
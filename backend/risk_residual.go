@@ -0,0 +1,274 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// dbExecutor is satisfied by both *sql.DB and *sql.Tx.
+type dbExecutor interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// residualRating derives residual likelihood and impact from the inherent
+// rating and the average effectiveness of the linked controls, the weight of
+// their compliance level on the scale. Controls are
+// treated as preventive: they move likelihood down the scale in proportion to
+// their effectiveness (fully effective controls reach the lowest step) and
+// leave impact unchanged. Levels that are not applicable are left out of the
+// average and labels missing from the scale count as ineffective. Without
+// applicable controls residual equals inherent.
+func residualRating(m *RiskMatrix, cs *ComplianceScale, likelihood, impact string, compliance []string) (string, string, *float64) {
+	var total float64
+	applicable := 0
+	for _, c := range compliance {
+		l, ok := cs.level(c)
+		if ok && !l.Applicable {
+			continue
+		}
+		total += l.Weight
+		applicable++
+	}
+	if applicable == 0 {
+		return likelihood, impact, nil
+	}
+	effectiveness := total / float64(applicable)
+
+	scale := append([]RiskMatrixLabel(nil), m.Likelihood...)
+	sort.SliceStable(scale, func(i, j int) bool { return scale[i].Weight < scale[j].Weight })
+	for pos, l := range scale {
+		if l.Label == likelihood {
+			reduced := pos - int(math.Round(effectiveness*float64(pos)))
+			return scale[reduced].Label, impact, &effectiveness
+		}
+	}
+	return likelihood, impact, &effectiveness
+}
+
+// updateResidualRisk recalculates and stores the residual rating of one risk
+// from its inherent rating and the current compliance of its linked controls,
+// taking each control from the newest cycle it is linked in.
+func updateResidualRisk(db dbExecutor, m *RiskMatrix, risk *RiskRegister) error {
+	rows, err := db.Query(
+		`SELECT g.compliance FROM risk_controls rc
+		 JOIN gap_assessments g ON g.id = rc.gap_assessment_id
+		 WHERE rc.risk_register_id = $1 AND `+newestRiskControlSQL, risk.ID)
+	if err != nil {
+		return err
+	}
+	var compliance []string
+	for rows.Next() {
+		var c string
+		if err := rows.Scan(&c); err != nil {
+			rows.Close()
+			return err
+		}
+		compliance = append(compliance, c)
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return err
+	}
+
+	scale, err := loadComplianceScale(db)
+	if err != nil {
+		return err
+	}
+
+	risk.ResidualLikelihood, risk.ResidualImpact, risk.ResidualScore, risk.ResidualLevel, risk.ControlEffectiveness = nil, nil, nil, nil, nil
+	if _, _, err := m.Score(risk.Likelihood, risk.Impact); err == nil {
+		l, i, effectiveness := residualRating(m, scale, risk.Likelihood, risk.Impact, compliance)
+		score, level, _ := m.Score(l, i)
+		risk.ResidualLikelihood, risk.ResidualImpact = &l, &i
+		risk.ResidualScore, risk.ResidualLevel = &score, &level
+		risk.ControlEffectiveness = effectiveness
+	}
+
+	_, err = db.Exec(
+		"UPDATE risk_register SET residual_likelihood = $1, residual_impact = $2, residual_score = $3, residual_level = $4, control_effectiveness = $5 WHERE id = $6",
+		risk.ResidualLikelihood, risk.ResidualImpact, risk.ResidualScore, risk.ResidualLevel, risk.ControlEffectiveness, risk.ID,
+	)
+	return err
+}
+
+// refreshResidualRisks recalculates the residual rating of the given risks
+// (every risk when ids is nil).
+func refreshResidualRisks(db dbExecutor, m *RiskMatrix, ids []int) error {
+	query := "SELECT " + riskColumns + " FROM risk_register"
+	var args []interface{}
+	if ids != nil {
+		if len(ids) == 0 {
+			return nil
+		}
+		query += " WHERE id = ANY($1)"
+		args = append(args, pq.Array(ids))
+	}
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	var risks []RiskRegister
+	for rows.Next() {
+		var risk RiskRegister
+		if err := scanRisk(rows, &risk); err != nil {
+			rows.Close()
+			return err
+		}
+		risks = append(risks, risk)
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return err
+	}
+
+	for i := range risks {
+		if err := updateResidualRisk(db, m, &risks[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// refreshResidualForControl recalculates every risk linked to a gap
+// assessment row, e.g. after its compliance status changed.
+func refreshResidualForControl(db dbExecutor, gapAssessmentID int) error {
+	ids, err := queryIDs(db, "SELECT risk_register_id FROM risk_controls WHERE gap_assessment_id = $1", gapAssessmentID)
+	if err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	m, err := loadRiskMatrix(db)
+	if err != nil {
+		return err
+	}
+	return refreshResidualRisks(db, m, ids)
+}
+
+// RiskReductionItem compares the inherent and residual rating of one risk.
+type RiskReductionItem struct {
+	ID                   int      `json:"id"`
+	RiskID               string   `json:"risk_id"`
+	Title                string   `json:"title"`
+	TreatmentPlan        string   `json:"treatment_plan"`
+	InherentScore        *int     `json:"inherent_score"`
+	InherentLevel        string   `json:"inherent_level"`
+	ResidualScore        *int     `json:"residual_score"`
+	ResidualLevel        *string  `json:"residual_level"`
+	ControlEffectiveness *float64 `json:"control_effectiveness"`
+	Reduction            int      `json:"reduction"`
+}
+
+// RiskReductionGroup totals the risks sharing a treatment option.
+type RiskReductionGroup struct {
+	Treatment        string              `json:"treatment"`
+	Risks            int                 `json:"risks"`
+	InherentScore    int                 `json:"inherent_score"`
+	ResidualScore    int                 `json:"residual_score"`
+	Reduction        int                 `json:"reduction"`
+	ReductionPercent float64             `json:"reduction_percent"`
+	Items            []RiskReductionItem `json:"items"`
+}
+
+// RiskReductionReport is the inherent vs residual risk report.
+type RiskReductionReport struct {
+	GeneratedAt      string               `json:"generated_at"`
+	Risks            int                  `json:"risks"`
+	InherentScore    int                  `json:"inherent_score"`
+	ResidualScore    int                  `json:"residual_score"`
+	Reduction        int                  `json:"reduction"`
+	ReductionPercent float64              `json:"reduction_percent"`
+	Treatments       []RiskReductionGroup `json:"treatments"`
+}
+
+func reductionPercent(inherent, reduction int) float64 {
+	if inherent == 0 {
+		return 0
+	}
+	return math.Round(float64(reduction)*1000/float64(inherent)) / 10
+}
+
+// getRiskReductionReport shows, per treatment option, how far the linked controls
+// bring risks down from their inherent to their residual score.
+func (app *App) getRiskReductionReport(w http.ResponseWriter, r *http.Request) {
+	rows, err := app.DB.Query(
+		`SELECT id, risk_id, title, COALESCE(treatment_plan, ''), COALESCE(treatment_option, 'unspecified'),
+		        risk_score, risk_level, residual_score, residual_level, control_effectiveness
+		 FROM risk_register
+		 ORDER BY risk_score DESC NULLS LAST, id`)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	report := RiskReductionReport{
+		GeneratedAt: time.Now().UTC().Format(time.RFC3339),
+		Treatments:  []RiskReductionGroup{},
+	}
+	groups := map[string]int{}
+	for rows.Next() {
+		var item RiskReductionItem
+		var treatment string
+		if err := rows.Scan(&item.ID, &item.RiskID, &item.Title, &item.TreatmentPlan, &treatment,
+			&item.InherentScore, &item.InherentLevel, &item.ResidualScore, &item.ResidualLevel, &item.ControlEffectiveness); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		inherent, residual := 0, 0
+		if item.InherentScore != nil {
+			inherent = *item.InherentScore
+			residual = inherent
+		}
+		if item.ResidualScore != nil {
+			residual = *item.ResidualScore
+		}
+		item.Reduction = inherent - residual
+
+		idx, ok := groups[treatment]
+		if !ok {
+			idx = len(report.Treatments)
+			groups[treatment] = idx
+			report.Treatments = append(report.Treatments, RiskReductionGroup{Treatment: treatment, Items: []RiskReductionItem{}})
+		}
+		g := &report.Treatments[idx]
+		g.Risks++
+		g.InherentScore += inherent
+		g.ResidualScore += residual
+		g.Reduction += item.Reduction
+		g.Items = append(g.Items, item)
+
+		report.Risks++
+		report.InherentScore += inherent
+		report.ResidualScore += residual
+		report.Reduction += item.Reduction
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	for i := range report.Treatments {
+		g := &report.Treatments[i]
+		g.ReductionPercent = reductionPercent(g.InherentScore, g.Reduction)
+	}
+	report.ReductionPercent = reductionPercent(report.InherentScore, report.Reduction)
+	sort.SliceStable(report.Treatments, func(i, j int) bool {
+		return report.Treatments[i].Reduction > report.Treatments[j].Reduction
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"io/ioutil"
 	"log"
+
+	"github.com/lib/pq"
 )
 
 // SeedData handles automatic seeding of initial data
@@ -47,6 +49,22 @@ func (app *App) SeedData() error {
 		log.Println("maturity_assessments table already has data, skipping seed")
 	}
 
+	// Seed the threat and vulnerability catalogue if it is empty
+	hasThreatData, err := app.hasData("threats")
+	if err != nil {
+		return fmt.Errorf("error checking threats: %v", err)
+	}
+	if !hasThreatData {
+		log.Println("Seeding threat catalogue...")
+		if err := app.seedThreatCatalogue(); err != nil {
+			log.Printf("Warning: Failed to seed threat catalogue: %v", err)
+		} else {
+			log.Println("Successfully seeded threat catalogue")
+		}
+	} else {
+		log.Println("threats table already has data, skipping seed")
+	}
+
 	return nil
 }
 
@@ -105,8 +123,8 @@ func (app *App) seedGapAssessments() error {
 
 	stmt, err := tx.Prepare(`
 		INSERT INTO gap_assessments 
-		(category, section, standard_ref, assessment_question, compliance, notes)
-		VALUES ($1, $2, $3, $4, $5, $6)
+		(category, section, standard_ref, assessment_question, compliance, notes, cycle_id)
+		VALUES ($1, $2, $3, $4, $5, $6, ` + currentCycleSQL + `)
 	`)
 	if err != nil {
 		return fmt.Errorf("error preparing statement: %v", err)
@@ -181,8 +199,8 @@ func (app *App) seedMaturityAssessments() error {
 		INSERT INTO maturity_assessments 
 		(category, section, standard_ref, assessment_question, 
 		 current_maturity_level, current_maturity_score, current_maturity_comments,
-		 target_maturity_level, target_maturity_score, target_maturity_comments)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		 target_maturity_level, target_maturity_score, target_maturity_comments, cycle_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, ` + currentCycleSQL + `)
 	`)
 	if err != nil {
 		return fmt.Errorf("error preparing statement: %v", err)
@@ -232,3 +250,73 @@ func (app *App) seedMaturityAssessments() error {
 	log.Printf("Inserted %d maturity assessments", len(assessments))
 	return nil
 }
+
+// seedThreatCatalogue seeds threats and vulnerabilities from JSON file
+func (app *App) seedThreatCatalogue() error {
+	jsonPaths := []string{
+		"./sample_threat_catalogue.json",
+		"sample_threat_catalogue.json",
+		"../sample_threat_catalogue.json",
+		"/app/sample_threat_catalogue.json",
+		"/root/sample_threat_catalogue.json",
+	}
+
+	var jsonData []byte
+	var err error
+	var foundPath string
+
+	for _, path := range jsonPaths {
+		if jsonData, err = ioutil.ReadFile(path); err == nil {
+			foundPath = path
+			break
+		}
+	}
+
+	if jsonData == nil {
+		log.Printf("Warning: Could not find sample_threat_catalogue.json in any of these paths: %v", jsonPaths)
+		return fmt.Errorf("sample_threat_catalogue.json not found")
+	}
+
+	log.Printf("Found threat catalogue file at: %s", foundPath)
+
+	var catalogue ThreatCatalogue
+	if err := json.Unmarshal(jsonData, &catalogue); err != nil {
+		return fmt.Errorf("error parsing JSON: %v", err)
+	}
+
+	// Use transaction for atomicity
+	tx, err := app.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	for _, t := range catalogue.Threats {
+		_, err := tx.Exec(`
+			INSERT INTO threats (code, name, category, origin, description, suggested_controls)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			ON CONFLICT (code) DO NOTHING
+		`, t.Code, t.Name, t.Category, t.Origin, t.Description, pq.Array(t.SuggestedControls))
+		if err != nil {
+			return fmt.Errorf("error inserting threat %s: %v", t.Code, err)
+		}
+	}
+
+	for _, v := range catalogue.Vulnerabilities {
+		_, err := tx.Exec(`
+			INSERT INTO vulnerabilities (code, name, category, description, asset_types, suggested_controls)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			ON CONFLICT (code) DO NOTHING
+		`, v.Code, v.Name, v.Category, v.Description, pq.Array(v.AssetTypes), pq.Array(v.SuggestedControls))
+		if err != nil {
+			return fmt.Errorf("error inserting vulnerability %s: %v", v.Code, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %v", err)
+	}
+
+	log.Printf("Inserted %d threats and %d vulnerabilities", len(catalogue.Threats), len(catalogue.Vulnerabilities))
+	return nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Storage persists uploaded evidence files under opaque, slash-separated keys
+// such as "evidence/2026/03/9f2c...-policy.pdf". Implementations must report
+// missing objects with an error that satisfies errors.Is(err, os.ErrNotExist).
+type Storage interface {
+	// Put stores size bytes read from r under key.
+	Put(key string, r io.Reader, size int64, contentType string) error
+	// Open returns a seekable reader for key, suitable for http.ServeContent.
+	Open(key string) (io.ReadSeekCloser, StoredObjectInfo, error)
+	// Delete removes key. Deleting a missing object is not an error.
+	Delete(key string) error
+}
+
+// StoredObjectInfo is the metadata returned alongside an opened object.
+type StoredObjectInfo struct {
+	Size    int64
+	ModTime time.Time
+}
+
+// newStorageFromEnv selects the storage backend from STORAGE_BACKEND
+// ("local" by default, or "s3" for S3-compatible object storage such as MinIO).
+func newStorageFromEnv() (Storage, error) {
+	switch backend := strings.ToLower(getEnv("STORAGE_BACKEND", "local")); backend {
+	case "local":
+		return &LocalStorage{Root: getEnv("EVIDENCE_STORAGE_DIR", "./uploads")}, nil
+	case "s3":
+		return newS3StorageFromEnv()
+	default:
+		return nil, fmt.Errorf("unknown STORAGE_BACKEND %q (expected \"local\" or \"s3\")", backend)
+	}
+}
+
+// LocalStorage keeps files in a directory on the local filesystem.
+type LocalStorage struct {
+	Root string
+}
+
+// path maps a storage key onto the root directory, refusing keys that would
+// escape it (absolute paths, "..").
+func (s *LocalStorage) path(key string) (string, error) {
+	clean := filepath.Clean(filepath.FromSlash(key))
+	if key == "" || filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid storage path %q", key)
+	}
+	return filepath.Join(s.Root, clean), nil
+}
+
+func (s *LocalStorage) Put(key string, r io.Reader, size int64, contentType string) error {
+	dest, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0o750); err != nil {
+		return fmt.Errorf("error creating storage directory: %v", err)
+	}
+
+	out, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o640)
+	if err != nil {
+		return fmt.Errorf("error creating stored file: %v", err)
+	}
+	_, err = io.Copy(out, r)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(dest)
+		return fmt.Errorf("error writing stored file: %v", err)
+	}
+	return nil
+}
+
+func (s *LocalStorage) Open(key string) (io.ReadSeekCloser, StoredObjectInfo, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, StoredObjectInfo{}, fmt.Errorf("%v: %w", err, os.ErrNotExist)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, StoredObjectInfo{}, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, StoredObjectInfo{}, err
+	}
+	return f, StoredObjectInfo{Size: info.Size(), ModTime: info.ModTime()}, nil
+}
+
+func (s *LocalStorage) Delete(key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// S3Storage stores files in an S3-compatible bucket (AWS S3, MinIO, ...).
+// Requests are signed with AWS Signature Version 4; payloads are sent
+// unsigned so uploads can be streamed without buffering.
+type S3Storage struct {
+	Endpoint  *url.URL
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	Prefix    string
+	PathStyle bool
+	Client    *http.Client
+}
+
+const s3UnsignedPayload = "UNSIGNED-PAYLOAD"
+
+func newS3StorageFromEnv() (*S3Storage, error) {
+	region := getEnv("S3_REGION", "us-east-1")
+	endpoint := getEnv("S3_ENDPOINT", "")
+	pathStyleDefault := "true"
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", region)
+		pathStyleDefault = "false"
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid S3_ENDPOINT %q", endpoint)
+	}
+
+	s := &S3Storage{
+		Endpoint:  u,
+		Region:    region,
+		Bucket:    getEnv("S3_BUCKET", ""),
+		AccessKey: getEnv("S3_ACCESS_KEY_ID", ""),
+		SecretKey: getEnv("S3_SECRET_ACCESS_KEY", ""),
+		Prefix:    strings.Trim(getEnv("S3_PREFIX", ""), "/"),
+		Client:    &http.Client{Timeout: 10 * time.Minute},
+	}
+	s.PathStyle, err = strconv.ParseBool(getEnv("S3_PATH_STYLE", pathStyleDefault))
+	if err != nil {
+		return nil, fmt.Errorf("invalid S3_PATH_STYLE: %v", err)
+	}
+	if s.Bucket == "" || s.AccessKey == "" || s.SecretKey == "" {
+		return nil, errors.New("S3 storage requires S3_BUCKET, S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY")
+	}
+	return s, nil
+}
+
+// objectURL builds the request URL for a key in path-style
+// (endpoint/bucket/key, as MinIO expects) or virtual-hosted style.
+func (s *S3Storage) objectURL(key string) (*url.URL, error) {
+	if key == "" {
+		return nil, fmt.Errorf("invalid storage path %q", key)
+	}
+	// As in LocalStorage, only a ".." segment escapes; "v1..final.pdf" does not
+	for _, segment := range strings.Split(key, "/") {
+		if segment == ".." {
+			return nil, fmt.Errorf("invalid storage path %q", key)
+		}
+	}
+	if s.Prefix != "" {
+		key = s.Prefix + "/" + key
+	}
+
+	u := *s.Endpoint
+	basePath := strings.TrimRight(u.Path, "/")
+	if s.PathStyle {
+		u.Path = basePath + "/" + s.Bucket + "/" + key
+	} else {
+		u.Host = s.Bucket + "." + u.Host
+		u.Path = basePath + "/" + key
+	}
+	u.RawPath = s3EscapePath(u.Path)
+	return &u, nil
+}
+
+// s3EscapePath percent-encodes everything except unreserved characters and "/",
+// which is the canonical URI encoding SigV4 expects for S3.
+func s3EscapePath(p string) string {
+	var sb strings.Builder
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || c == '-' || c == '_' || c == '.' || c == '~' || c == '/' {
+			sb.WriteByte(c)
+		} else {
+			fmt.Fprintf(&sb, "%%%02X", c)
+		}
+	}
+	return sb.String()
+}
+
+func (s *S3Storage) newRequest(method, key string, body io.Reader) (*http.Request, error) {
+	u, err := s.objectURL(key)
+	if err != nil {
+		return nil, err
+	}
+	return http.NewRequest(method, u.String(), body)
+}
+
+// sign adds SigV4 authentication headers to req.
+func (s *S3Storage) sign(req *http.Request, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", s3UnsignedPayload)
+
+	headers := map[string]string{
+		"host":                 req.URL.Host,
+		"x-amz-content-sha256": s3UnsignedPayload,
+		"x-amz-date":           amzDate,
+	}
+	if ct := req.Header.Get("Content-Type"); ct != "" {
+		headers["content-type"] = ct
+	}
+	canonicalRequest, signedHeaders := sigV4CanonicalRequest(req.Method, req.URL.EscapedPath(), req.URL.RawQuery, headers, s3UnsignedPayload)
+	scope, signature := sigV4Signature(s.SecretKey, s.Region, "s3", amzDate, canonicalRequest)
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.AccessKey, scope, signedHeaders, signature))
+}
+
+// sigV4CanonicalRequest builds the SigV4 canonical request from an already
+// escaped path and query and lower-case header names, and returns it with
+// the signed header list.
+func sigV4CanonicalRequest(method, path, query string, headers map[string]string, payloadHash string) (string, string) {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(headers[name]) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	return strings.Join([]string{
+		method,
+		path,
+		query,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n"), signedHeaders
+}
+
+// sigV4Signature signs a canonical request made at amzDate and returns the
+// credential scope and the hex signature.
+func sigV4Signature(secretKey, region, service, amzDate, canonicalRequest string) (string, string) {
+	day := amzDate[:8]
+	scope := day + "/" + region + "/" + service + "/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), day)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	return scope, hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// do signs and sends req, turning error responses into Go errors.
+// A 404 is reported as os.ErrNotExist.
+func (s *S3Storage) do(req *http.Request) (*http.Response, error) {
+	s.sign(req, time.Now())
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("s3 %s %s: %w", req.Method, req.URL.Path, os.ErrNotExist)
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return nil, fmt.Errorf("s3 %s %s: %s: %s", req.Method, req.URL.Path, resp.Status, strings.TrimSpace(string(msg)))
+}
+
+func (s *S3Storage) Put(key string, r io.Reader, size int64, contentType string) error {
+	req, err := s.newRequest(http.MethodPut, key, r)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := s.do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *S3Storage) Open(key string) (io.ReadSeekCloser, StoredObjectInfo, error) {
+	req, err := s.newRequest(http.MethodHead, key, nil)
+	if err != nil {
+		return nil, StoredObjectInfo{}, fmt.Errorf("%v: %w", err, os.ErrNotExist)
+	}
+	resp, err := s.do(req)
+	if err != nil {
+		return nil, StoredObjectInfo{}, err
+	}
+	resp.Body.Close()
+
+	info := StoredObjectInfo{Size: resp.ContentLength}
+	if lm, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		info.ModTime = lm
+	}
+	return &s3Object{store: s, key: key, size: info.Size}, info, nil
+}
+
+func (s *S3Storage) Delete(key string) error {
+	req, err := s.newRequest(http.MethodDelete, key, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(req)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// s3Object is a lazily-fetched, seekable view of an object. Each Seek drops the
+// current response and the next Read issues a ranged GET from the new offset,
+// which is what http.ServeContent needs to answer Range requests.
+type s3Object struct {
+	store  *S3Storage
+	key    string
+	size   int64
+	offset int64
+	body   io.ReadCloser
+}
+
+func (o *s3Object) Read(p []byte) (int, error) {
+	if o.offset >= o.size {
+		return 0, io.EOF
+	}
+	if o.body == nil {
+		req, err := o.store.newRequest(http.MethodGet, o.key, nil)
+		if err != nil {
+			return 0, err
+		}
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", o.offset))
+		resp, err := o.store.do(req)
+		if err != nil {
+			return 0, err
+		}
+		o.body = resp.Body
+	}
+	n, err := o.body.Read(p)
+	o.offset += int64(n)
+	return n, err
+}
+
+func (o *s3Object) Seek(offset int64, whence int) (int64, error) {
+	var next int64
+	switch whence {
+	case io.SeekStart:
+		next = offset
+	case io.SeekCurrent:
+		next = o.offset + offset
+	case io.SeekEnd:
+		next = o.size + offset
+	default:
+		return 0, errors.New("s3Object.Seek: invalid whence")
+	}
+	if next < 0 {
+		return 0, errors.New("s3Object.Seek: negative position")
+	}
+	if next != o.offset && o.body != nil {
+		o.body.Close()
+		o.body = nil
+	}
+	o.offset = next
+	return next, nil
+}
+
+func (o *s3Object) Close() error {
+	if o.body != nil {
+		err := o.body.Close()
+		o.body = nil
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// emptyPayloadHash is the SHA-256 of an empty body.
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// Known answers published by AWS: the get-vanilla case of the SigV4 test
+// suite and the GET Object example of the S3 SigV4 documentation.
+func TestSigV4KnownAnswers(t *testing.T) {
+	tests := []struct {
+		name                                 string
+		secretKey, region, service, amzDate  string
+		method, path                         string
+		headers                              map[string]string
+		payloadHash                          string
+		wantScope, wantSigned, wantSignature string
+	}{
+		{
+			name:      "get-vanilla",
+			secretKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+			region:    "us-east-1",
+			service:   "service",
+			amzDate:   "20150830T123600Z",
+			method:    "GET",
+			path:      "/",
+			headers: map[string]string{
+				"host":       "example.amazonaws.com",
+				"x-amz-date": "20150830T123600Z",
+			},
+			payloadHash:   emptyPayloadHash,
+			wantScope:     "20150830/us-east-1/service/aws4_request",
+			wantSigned:    "host;x-amz-date",
+			wantSignature: "5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		},
+		{
+			name:      "s3 get object with range",
+			secretKey: "wJalrXUtnFEMI/K7MDENG/bPxRfiCYEXAMPLEKEY",
+			region:    "us-east-1",
+			service:   "s3",
+			amzDate:   "20130524T000000Z",
+			method:    "GET",
+			path:      "/test.txt",
+			headers: map[string]string{
+				"host":                 "examplebucket.s3.amazonaws.com",
+				"range":                "bytes=0-9",
+				"x-amz-content-sha256": emptyPayloadHash,
+				"x-amz-date":           "20130524T000000Z",
+			},
+			payloadHash:   emptyPayloadHash,
+			wantScope:     "20130524/us-east-1/s3/aws4_request",
+			wantSigned:    "host;range;x-amz-content-sha256;x-amz-date",
+			wantSignature: "f0e8bdb87c964420e857bd35b5d6ed310bd44f0170aba48dd91039c6036bdb41",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			canonical, signed := sigV4CanonicalRequest(tt.method, tt.path, "", tt.headers, tt.payloadHash)
+			if signed != tt.wantSigned {
+				t.Errorf("signed headers %q, want %q", signed, tt.wantSigned)
+			}
+			scope, signature := sigV4Signature(tt.secretKey, tt.region, tt.service, tt.amzDate, canonical)
+			if scope != tt.wantScope {
+				t.Errorf("scope %q, want %q", scope, tt.wantScope)
+			}
+			if signature != tt.wantSignature {
+				t.Errorf("signature %s, want %s\ncanonical request:\n%s", signature, tt.wantSignature, canonical)
+			}
+		})
+	}
+}
+
+func TestS3SignHeaders(t *testing.T) {
+	s := &S3Storage{
+		Endpoint:  &url.URL{Scheme: "https", Host: "s3.eu-west-1.amazonaws.com"},
+		Region:    "eu-west-1",
+		Bucket:    "evidence",
+		AccessKey: "AKIDEXAMPLE",
+		SecretKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+	}
+	req, err := s.newRequest(http.MethodPut, "evidence/12/Access Policy (v2).pdf", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/pdf")
+	s.sign(req, time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+
+	if got, want := req.URL.EscapedPath(), "/evidence/12/Access%20Policy%20%28v2%29.pdf"; got != want {
+		t.Errorf("path %q, want %q", got, want)
+	}
+	if got := req.Header.Get("X-Amz-Date"); got != "20150830T123600Z" {
+		t.Errorf("X-Amz-Date %q", got)
+	}
+	canonical, _ := sigV4CanonicalRequest("PUT", "/evidence/12/Access%20Policy%20%28v2%29.pdf", "", map[string]string{
+		"content-type":         "application/pdf",
+		"host":                 "evidence.s3.eu-west-1.amazonaws.com",
+		"x-amz-content-sha256": s3UnsignedPayload,
+		"x-amz-date":           "20150830T123600Z",
+	}, s3UnsignedPayload)
+	_, signature := sigV4Signature(s.SecretKey, "eu-west-1", "s3", "20150830T123600Z", canonical)
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/eu-west-1/s3/aws4_request, " +
+		"SignedHeaders=content-type;host;x-amz-content-sha256;x-amz-date, Signature=" + signature
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Errorf("Authorization\n got %s\nwant %s", got, want)
+	}
+}
+
+func TestS3ObjectURLKeys(t *testing.T) {
+	s := &S3Storage{
+		Endpoint:  &url.URL{Scheme: "http", Host: "minio:9000"},
+		Bucket:    "evidence",
+		PathStyle: true,
+	}
+	tests := map[string]string{
+		"evidence/12/v1..final.pdf": "/evidence/evidence/12/v1..final.pdf",
+		"evidence/..hidden":         "/evidence/evidence/..hidden",
+		"..":                        "",
+		"evidence/../secret":        "",
+		"../evidence/12/report.pdf": "",
+		"":                          "",
+	}
+	for key, want := range tests {
+		u, err := s.objectURL(key)
+		if want == "" {
+			if err == nil {
+				t.Errorf("objectURL(%q) accepted: %s", key, u)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("objectURL(%q): %v", key, err)
+		} else if u.Path != want {
+			t.Errorf("objectURL(%q) path %q, want %q", key, u.Path, want)
+		}
+	}
+}
+
+func TestS3ObjectRangedReads(t *testing.T) {
+	content := []byte("0123456789abcdefghij")
+	modTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	var mu sync.Mutex
+	var ranges []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/bucket/files/evidence/report.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 ") {
+			http.Error(w, "unsigned request", http.StatusForbidden)
+			return
+		}
+		if r.Method == http.MethodGet {
+			mu.Lock()
+			ranges = append(ranges, r.Header.Get("Range"))
+			mu.Unlock()
+		}
+		http.ServeContent(w, r, "report.txt", modTime, bytes.NewReader(content))
+	}))
+	defer srv.Close()
+
+	endpoint, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &S3Storage{
+		Endpoint:  endpoint,
+		Region:    "us-east-1",
+		Bucket:    "bucket",
+		AccessKey: "AKIDEXAMPLE",
+		SecretKey: "secret",
+		Prefix:    "files",
+		PathStyle: true,
+		Client:    srv.Client(),
+	}
+
+	obj, info, err := s.Open("evidence/report.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer obj.Close()
+	if info.Size != int64(len(content)) || !info.ModTime.Equal(modTime) {
+		t.Errorf("info %+v, want size %d and mod time %v", info, len(content), modTime)
+	}
+
+	// Seeking before the first read costs no request
+	if _, err := obj.Seek(15, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "fghij" {
+		t.Errorf("read from 15: %q", got)
+	}
+
+	if _, err := obj.Seek(-15, io.SeekEnd); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 3)
+	if _, err := io.ReadFull(obj, buf); err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != "567" {
+		t.Errorf("read from 5: %q", buf)
+	}
+	// Reading on from the current position keeps the open response
+	if _, err := obj.Seek(0, io.SeekCurrent); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadFull(obj, buf); err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != "89a" {
+		t.Errorf("read on from 8: %q", buf)
+	}
+
+	if _, err := obj.Seek(-1, io.SeekStart); err == nil {
+		t.Error("seek to a negative position accepted")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if want := []string{"bytes=15-", "bytes=5-"}; strings.Join(ranges, ",") != strings.Join(want, ",") {
+		t.Errorf("ranged GETs %q, want %q", ranges, want)
+	}
+
+	if _, _, err := s.Open("evidence/missing.txt"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("missing object: got %v, want os.ErrNotExist", err)
+	}
+}
@@ -740,6 +740,7 @@ func buildAssessmentSnapshot(clauseRef string, clauseNum string, data map[string
 	category := getString(data, "category")
 	section := getString(data, "section")
 	compliance := getString(data, "compliance")
+	implementationStatus := getString(data, "implementation_status")
 	targetDate := getString(data, "target_date")
 	question := getString(data, "assessment_question")
 	notes := getString(data, "notes")
@@ -772,6 +773,9 @@ func buildAssessmentSnapshot(clauseRef string, clauseNum string, data map[string
 	if compliance != "" {
 		sb.WriteString(fmt.Sprintf("| Compliance | %s |\n", escapePipes(compliance)))
 	}
+	if implementationStatus != "" {
+		sb.WriteString(fmt.Sprintf("| Implementation Status | %s |\n", escapePipes(implementationStatus)))
+	}
 	if targetDate != "" {
 		sb.WriteString(fmt.Sprintf("| Target Date | %s |\n", escapePipes(targetDate)))
 	}
@@ -934,7 +938,7 @@ func populateTemplate(template string, data map[string]interface{}) string {
 }
 
 // Generate Statement of Applicability
-func generateSoA(gapAssessments []map[string]interface{}) string {
+func generateSoA(gapAssessments []map[string]interface{}, scale *ComplianceScale) string {
 	var sb strings.Builder
 	
 	sb.WriteString("# STATEMENT OF APPLICABILITY (SoA)\n\n")
@@ -981,9 +985,12 @@ func generateSoA(gapAssessments []map[string]interface{}) string {
 			}
 		}
 		
+		// Applicability and implementation status come from the compliance scale;
+		// a label missing from the scale is reported rather than guessed
+		level, known := scale.level(compliance)
 		applicable := "Yes"
 		justification := ""
-		if compliance == "Not Applicable" {
+		if known && !level.Applicable {
 			applicable = "No"
 			justification = "Not applicable to our business context"
 		} else {
@@ -1002,12 +1009,7 @@ func generateSoA(gapAssessments []map[string]interface{}) string {
 			}
 		}
 		
-		status := "Planned"
-		if compliance == "Fully Compliant" {
-			status = "Implemented"
-		} else if compliance == "Partially Compliant" {
-			status = "Partially Implemented"
-		}
+		status := scale.soaStatus(compliance)
 		
 		// Determine control group from reference
 		controlGroup := "Organisational Controls"
@@ -1041,7 +1043,9 @@ func generateSoA(gapAssessments []map[string]interface{}) string {
 	
 	sb.WriteString("\n## Notes\n")
 	sb.WriteString("- This SoA is based on the current gap assessment results\n")
-	sb.WriteString("- Controls marked as 'Not Applicable' have been excluded with documented justification\n")
+	if excluded := scale.notApplicableLabels(); len(excluded) > 0 {
+		sb.WriteString(fmt.Sprintf("- Controls marked as '%s' have been excluded with documented justification\n", strings.Join(excluded, "' or '")))
+	}
 	sb.WriteString("- This document shall be reviewed at least annually or when significant changes occur\n")
 	sb.WriteString("- Implementation status reflects the current state as of the last assessment\n\n")
 	
@@ -1063,13 +1067,14 @@ func generateNotionExport(gapAssessments []map[string]interface{}, maturityAsses
 	
 	// Gap Assessment Section
 	sb.WriteString("## Gap Assessment Summary\n\n")
-	sb.WriteString("| Clause | Assessment Question | Compliance Status | Notes |\n")
-	sb.WriteString("|--------|---------------------|-------------------|-------|\n")
+	sb.WriteString("| Clause | Assessment Question | Compliance Status | Implementation Status | Notes |\n")
+	sb.WriteString("|--------|---------------------|-------------------|-----------------------|-------|\n")
 	
 	for _, assessment := range gapAssessments {
 		ref := getString(assessment, "standard_ref")
 		question := getString(assessment, "assessment_question")
 		compliance := getString(assessment, "compliance")
+		status := getString(assessment, "implementation_status")
 		notes := getString(assessment, "notes")
 		
 		// Truncate long questions
@@ -1077,8 +1082,8 @@ func generateNotionExport(gapAssessments []map[string]interface{}, maturityAsses
 			question = question[:80] + "..."
 		}
 		
-		sb.WriteString(fmt.Sprintf("| %s | %s | %s | %s |\n",
-			ref, question, compliance, notes))
+		sb.WriteString(fmt.Sprintf("| %s | %s | %s | %s | %s |\n",
+			ref, question, compliance, status, notes))
 	}
 	
 	// Maturity Assessment Section
@@ -0,0 +1,370 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/zlib"
+	"encoding/xml"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxExtractedText caps the text kept per file; Postgres refuses tsvectors
+// over 1 MB and the first few hundred KB are plenty to find a document.
+const maxExtractedText = 256 << 10
+
+// maxExtractSource caps how much decompressed data is read while extracting,
+// so a small archive cannot expand without bound.
+const maxExtractSource = 64 << 20
+
+// extractText returns the searchable text of a file, chosen by extension.
+// Unsupported types yield "" without an error; extraction is best-effort and
+// scanned PDFs or PDFs with embedded font encodings may produce little text.
+func extractText(name string, r io.ReaderAt, size int64) (string, error) {
+	var text string
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".txt", ".csv", ".md", ".log", ".json":
+		data, err := io.ReadAll(io.LimitReader(io.NewSectionReader(r, 0, size), maxExtractedText*4))
+		if err != nil {
+			return "", err
+		}
+		text = string(data)
+	case ".docx":
+		var err error
+		if text, err = extractDOCXText(r, size); err != nil {
+			return "", err
+		}
+	case ".pdf":
+		data, err := io.ReadAll(io.LimitReader(io.NewSectionReader(r, 0, size), maxExtractSource))
+		if err != nil {
+			return "", err
+		}
+		text = extractPDFText(data)
+	default:
+		return "", nil
+	}
+	return normalizeExtractedText(text), nil
+}
+
+// normalizeExtractedText makes text safe to store (valid UTF-8, no NULs, which
+// Postgres rejects), collapses whitespace and truncates it.
+func normalizeExtractedText(s string) string {
+	s = strings.ToValidUTF8(s, " ")
+	s = strings.ReplaceAll(s, "\x00", " ")
+	s = strings.Join(strings.Fields(s), " ")
+	if len(s) > maxExtractedText {
+		cut := maxExtractedText
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		s = s[:cut]
+	}
+	return s
+}
+
+// extractDOCXText reads the paragraphs of word/document.xml.
+func extractDOCXText(r io.ReaderAt, size int64) (string, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return "", err
+	}
+	for _, f := range zr.File {
+		if f.Name != "word/document.xml" {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return "", err
+		}
+		defer rc.Close()
+
+		var sb strings.Builder
+		dec := xml.NewDecoder(io.LimitReader(rc, maxExtractSource))
+		inText := false
+		for sb.Len() < maxExtractedText {
+			tok, err := dec.Token()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return sb.String(), err
+			}
+			switch t := tok.(type) {
+			case xml.StartElement:
+				switch t.Name.Local {
+				case "t":
+					inText = true
+				case "tab":
+					sb.WriteByte('\t')
+				case "br", "cr":
+					sb.WriteByte('\n')
+				}
+			case xml.EndElement:
+				switch t.Name.Local {
+				case "t":
+					inText = false
+				case "p":
+					sb.WriteByte('\n')
+				}
+			case xml.CharData:
+				if inText {
+					sb.Write(t)
+				}
+			}
+		}
+		return sb.String(), nil
+	}
+	return "", nil
+}
+
+// extractPDFText pulls the strings drawn by text operators (Tj, TJ, ', ") out
+// of every content stream. FlateDecode and unfiltered streams are supported;
+// streams using other filters (images, fonts) are skipped.
+func extractPDFText(data []byte) string {
+	var sb strings.Builder
+	pos := 0
+	for sb.Len() < maxExtractedText {
+		i := bytes.Index(data[pos:], []byte("stream"))
+		if i < 0 {
+			break
+		}
+		start := pos + i
+		pos = start + len("stream")
+		if start >= 3 && string(data[start-3:start]) == "end" {
+			continue
+		}
+
+		// The stream dictionary sits between the object header and the keyword
+		dictStart := bytes.LastIndex(data[:start], []byte("obj"))
+		if dictStart < 0 {
+			dictStart = 0
+		}
+		dict := data[dictStart:start]
+
+		if pos < len(data) && data[pos] == '\r' {
+			pos++
+		}
+		if pos < len(data) && data[pos] == '\n' {
+			pos++
+		}
+		end := bytes.Index(data[pos:], []byte("endstream"))
+		if end < 0 {
+			break
+		}
+		raw := data[pos : pos+end]
+		pos += end + len("endstream")
+
+		var content []byte
+		switch {
+		case bytes.Contains(dict, []byte("/FlateDecode")):
+			zr, err := zlib.NewReader(bytes.NewReader(raw))
+			if err != nil {
+				continue
+			}
+			// Truncated or slightly corrupt streams still yield their prefix
+			content, _ = io.ReadAll(io.LimitReader(zr, maxExtractSource))
+			zr.Close()
+		case bytes.Contains(dict, []byte("/Filter")):
+			continue
+		default:
+			content = raw
+		}
+		if bytes.Contains(content, []byte("BT")) {
+			pdfContentText(content, &sb)
+		}
+	}
+	return sb.String()
+}
+
+// pdfContentText interprets the text-showing operators of a content stream.
+func pdfContentText(content []byte, sb *strings.Builder) {
+	var operands []string // strings since the last operator
+	var numbers []float64 // numbers since the last operator
+	inArray := false
+	var arrayText strings.Builder
+
+	for i := 0; i < len(content); {
+		c := content[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\f' || c == 0:
+			i++
+		case c == '%':
+			for i < len(content) && content[i] != '\n' && content[i] != '\r' {
+				i++
+			}
+		case c == '(':
+			s, next := pdfLiteralString(content, i)
+			i = next
+			if inArray {
+				arrayText.WriteString(s)
+			} else {
+				operands = append(operands, s)
+			}
+		case c == '<' && i+1 < len(content) && content[i+1] == '<':
+			i += 2
+		case c == '>' && i+1 < len(content) && content[i+1] == '>':
+			i += 2
+		case c == '<':
+			end := bytes.IndexByte(content[i:], '>')
+			if end < 0 {
+				return
+			}
+			s := pdfHexString(content[i+1 : i+end])
+			i += end + 1
+			if inArray {
+				arrayText.WriteString(s)
+			} else {
+				operands = append(operands, s)
+			}
+		case c == '[':
+			inArray = true
+			arrayText.Reset()
+			i++
+		case c == ']':
+			inArray = false
+			operands = append(operands, arrayText.String())
+			i++
+		case c == '/':
+			i++
+			for i < len(content) && !pdfDelimiter(content[i]) {
+				i++
+			}
+		default:
+			start := i
+			for i < len(content) && !pdfDelimiter(content[i]) {
+				i++
+			}
+			if i == start {
+				i++
+				continue
+			}
+			word := string(content[start:i])
+			if n, err := strconv.ParseFloat(word, 64); err == nil {
+				// Large negative kerning inside TJ arrays separates words
+				if inArray && n < -200 {
+					arrayText.WriteByte(' ')
+				}
+				numbers = append(numbers, n)
+				continue
+			}
+
+			switch word {
+			case "Tj", "TJ":
+				if len(operands) > 0 {
+					sb.WriteString(operands[len(operands)-1])
+				}
+			case "'", "\"":
+				sb.WriteByte('\n')
+				if len(operands) > 0 {
+					sb.WriteString(operands[len(operands)-1])
+				}
+			case "T*", "ET":
+				sb.WriteByte('\n')
+			case "Td", "TD":
+				if len(numbers) >= 2 && numbers[len(numbers)-1] != 0 {
+					sb.WriteByte('\n')
+				} else {
+					sb.WriteByte(' ')
+				}
+			case "Tm":
+				sb.WriteByte(' ')
+			case "BI":
+				// Skip inline image data
+				end := bytes.Index(content[i:], []byte("EI"))
+				if end < 0 {
+					return
+				}
+				i += end + 2
+			}
+			operands = operands[:0]
+			numbers = numbers[:0]
+		}
+	}
+}
+
+func pdfDelimiter(c byte) bool {
+	switch c {
+	case ' ', '\t', '\r', '\n', '\f', 0, '(', ')', '<', '>', '[', ']', '{', '}', '/', '%':
+		return true
+	}
+	return false
+}
+
+// pdfLiteralString decodes a (...) string starting at content[i] and returns
+// it with the index just past the closing parenthesis. Bytes are read as
+// Latin-1, which matches PDFDocEncoding for ordinary text.
+func pdfLiteralString(content []byte, i int) (string, int) {
+	var sb strings.Builder
+	depth := 0
+	for i++; i < len(content); i++ {
+		c := content[i]
+		switch c {
+		case '\\':
+			i++
+			if i >= len(content) {
+				return sb.String(), i
+			}
+			switch e := content[i]; e {
+			case 'n':
+				sb.WriteByte('\n')
+			case 'r':
+				sb.WriteByte('\r')
+			case 't':
+				sb.WriteByte('\t')
+			case 'b', 'f':
+			case '\r', '\n':
+				// Line continuation
+			default:
+				if e >= '0' && e <= '7' {
+					n := 0
+					for j := 0; j < 3 && i < len(content) && content[i] >= '0' && content[i] <= '7'; j++ {
+						n = n*8 + int(content[i]-'0')
+						i++
+					}
+					i--
+					sb.WriteRune(rune(n & 0xff))
+				} else {
+					sb.WriteRune(rune(e))
+				}
+			}
+		case '(':
+			depth++
+			sb.WriteByte(c)
+		case ')':
+			if depth == 0 {
+				return sb.String(), i + 1
+			}
+			depth--
+			sb.WriteByte(c)
+		default:
+			sb.WriteRune(rune(c))
+		}
+	}
+	return sb.String(), i
+}
+
+// pdfHexString decodes a <...> string. Two-byte glyph IDs used by embedded
+// CID fonts cannot be mapped without the font's CMap and are dropped.
+func pdfHexString(hex []byte) string {
+	var digits []byte
+	for _, c := range hex {
+		if (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F') {
+			digits = append(digits, c)
+		}
+	}
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	var sb strings.Builder
+	for i := 0; i < len(digits); i += 2 {
+		n, _ := strconv.ParseUint(string(digits[i:i+2]), 16, 8)
+		if n < 0x20 && n != '\t' && n != '\n' && n != '\r' {
+			return ""
+		}
+		sb.WriteRune(rune(n))
+	}
+	return sb.String()
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestPDFLiteralString(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+		next           int // index just past the string, -1 for len(in)
+	}{
+		{"plain", `(Access control) Tj`, "Access control", 16},
+		{"balanced parentheses", `(Policy (draft) v2)`, "Policy (draft) v2", -1},
+		{"escaped parentheses", `(a\) b\( c)`, "a) b( c", -1},
+		{"escaped backslash", `(C:\\evidence)`, `C:\evidence`, -1},
+		{"control escapes", `(one\ntwo\tthree\rfour\b\f)`, "one\ntwo\tthree\rfour", -1},
+		{"octal escapes", `(\101\102C \60\0610)`, "ABC 010", -1},
+		{"latin-1 octal", `(caf\351)`, "café", -1},
+		{"line continuation", "(split \\\nline)", "split line", -1},
+		{"unknown escape", `(\q)`, "q", -1},
+		{"unterminated", `(no end`, "no end", -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, next := pdfLiteralString([]byte(tt.in), 0)
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+			want := tt.next
+			if want < 0 {
+				want = len(tt.in)
+			}
+			if next != want {
+				t.Errorf("next = %d, want %d", next, want)
+			}
+		})
+	}
+}
+
+func TestPDFHexString(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"upper case", "41636365737320636F6E74726F6C", "Access control"},
+		{"lower case with spaces", "41 63 63 65 73 73", "Access"},
+		{"odd digit count pads with zero", "41424", "AB@"},
+		{"two-byte glyph IDs are dropped", "00410042", ""},
+		{"empty", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := pdfHexString([]byte(tt.in)); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPDFContentText(t *testing.T) {
+	tests := []struct {
+		name, content, want string
+	}{
+		{"show string", "BT /F1 12 Tf 72 712 Td (Hello) Tj ET", "Hello"},
+		{"hex string", "BT <48656C6C6F> Tj ET", "Hello"},
+		{"TJ kerning", "BT [(Ac) 20 (cess) -250 (control)] TJ ET", "Access control"},
+		{"new line operators", "BT (one) Tj 0 -14 Td (two) Tj T* (three) Tj (four) ' ET", "one two three four"},
+		{"comments and dictionaries", "% header\nBT /Span << /ActualText (x) >> BDC (kept) Tj EMC ET", "kept"},
+		{"inline image", "BT (before) Tj ET BI /W 1 /H 1 ID (junk) Tj EI BT (after) Tj ET", "before after"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sb strings.Builder
+			pdfContentText([]byte(tt.content), &sb)
+			if got := normalizeExtractedText(sb.String()); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// pdfFixture builds a minimal PDF with one object per stream.
+func pdfFixture(streams ...string) []byte {
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n")
+	for i, s := range streams {
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, s)
+	}
+	b.WriteString("trailer\n<< /Root 1 0 R >>\n%%EOF\n")
+	return b.Bytes()
+}
+
+func pdfStream(dict string, data []byte) string {
+	return fmt.Sprintf("<< /Length %d%s >>\nstream\r\n%s\nendstream", len(data), dict, data)
+}
+
+func flate(t *testing.T, s string) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	zw := zlib.NewWriter(&b)
+	if _, err := zw.Write([]byte(s)); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func TestExtractTextPDF(t *testing.T) {
+	compressed := flate(t, "BT (Compressed \\(Flate\\)) Tj ET")
+	data := pdfFixture(
+		"<< /Type /Catalog >>",
+		pdfStream("", []byte("BT (Plain stream) Tj ET")),
+		pdfStream(" /Filter /FlateDecode", compressed),
+		pdfStream(" /Filter /FlateDecode", compressed[:len(compressed)/2]),
+		pdfStream(" /Filter /DCTDecode", []byte("BT (image bytes) Tj ET")),
+		pdfStream("", []byte("q 1 0 0 1 0 0 cm Q")),
+	)
+	got, err := extractText("policy.PDF", bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Plain stream Compressed (Flate)"; !strings.HasPrefix(got, want) {
+		t.Errorf("got %q, want it to start with %q", got, want)
+	}
+	if strings.Contains(got, "image bytes") {
+		t.Errorf("stream with an unsupported filter was read: %q", got)
+	}
+}
+
+func docxFixture(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	zw := zip.NewWriter(&b)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func TestExtractTextDOCX(t *testing.T) {
+	document := `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+<w:body>
+<w:p><w:r><w:t>Access</w:t></w:r><w:r><w:t xml:space="preserve"> control </w:t></w:r><w:r><w:t>policy</w:t></w:r></w:p>
+<w:p><w:r><w:t>Owner:</w:t><w:tab/><w:t>CISO</w:t><w:br/><w:t>Review &amp; approve</w:t></w:r></w:p>
+<w:p><w:r><w:instrText>PAGE</w:instrText></w:r></w:p>
+</w:body>
+</w:document>`
+	data := docxFixture(t, map[string]string{
+		"[Content_Types].xml":          `<Types/>`,
+		"word/document.xml":            document,
+		"word/header1.xml":             `<w:hdr xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:p><w:r><w:t>Header</w:t></w:r></w:p></w:hdr>`,
+		"docProps/core.xml":            `<cp:coreProperties/>`,
+		"word/_rels/document.xml.rels": `<Relationships/>`,
+	})
+	got, err := extractText("policy.docx", bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Access control policy Owner: CISO Review & approve"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	if _, err := extractText("broken.docx", strings.NewReader("not a zip"), 9); err == nil {
+		t.Error("a file that is not a zip archive was accepted")
+	}
+	empty := docxFixture(t, map[string]string{"word/styles.xml": "<w:styles/>"})
+	if got, err := extractText("empty.docx", bytes.NewReader(empty), int64(len(empty))); err != nil || got != "" {
+		t.Errorf("docx without a document: got %q, %v", got, err)
+	}
+}
+
+func TestExtractTextPlainAndUnsupported(t *testing.T) {
+	text := "line one\r\n\tline\x00two\xff"
+	got, err := extractText("notes.txt", strings.NewReader(text), int64(len(text)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "line one line two"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	got, err = extractText("photo.png", strings.NewReader("BT (x) Tj ET"), 12)
+	if err != nil || got != "" {
+		t.Errorf("unsupported type: got %q, %v", got, err)
+	}
+}
+
+func TestNormalizeExtractedTextTruncatesOnRuneBoundary(t *testing.T) {
+	s := strings.Repeat("a", maxExtractedText-1) + "é and more"
+	got := normalizeExtractedText(s)
+	if len(got) != maxExtractedText-1 || strings.ContainsRune(got, 'é') {
+		t.Errorf("got %d bytes ending %q, want %d bytes of a", len(got), got[len(got)-3:], maxExtractedText-1)
+	}
+}
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/lib/pq"
+)
+
+// Threat is an entry of the threat catalogue (in the style of ISO/IEC 27005
+// Annex C). SuggestedControls holds Annex A references such as "Control-8.7".
+type Threat struct {
+	ID                int      `json:"id"`
+	Code              string   `json:"code"`
+	Name              string   `json:"name"`
+	Category          string   `json:"category"`
+	Origin            string   `json:"origin"`
+	Description       string   `json:"description"`
+	SuggestedControls []string `json:"suggested_controls"`
+}
+
+// Vulnerability is an entry of the vulnerability catalogue. AssetTypes lists
+// the asset types it typically applies to.
+type Vulnerability struct {
+	ID                int      `json:"id"`
+	Code              string   `json:"code"`
+	Name              string   `json:"name"`
+	Category          string   `json:"category"`
+	Description       string   `json:"description"`
+	AssetTypes        []string `json:"asset_types"`
+	SuggestedControls []string `json:"suggested_controls"`
+}
+
+// ThreatCatalogue is the layout of sample_threat_catalogue.json.
+type ThreatCatalogue struct {
+	Threats         []Threat        `json:"threats"`
+	Vulnerabilities []Vulnerability `json:"vulnerabilities"`
+}
+
+// CatalogueRiskRequest picks a threat, a vulnerability and optionally an
+// asset, all by code. Likelihood and impact default to the middle of the
+// matrix; with an asset, impact follows the asset's value instead.
+type CatalogueRiskRequest struct {
+	Threat          string  `json:"threat"`
+	Vulnerability   string  `json:"vulnerability"`
+	Asset           string  `json:"asset,omitempty"`
+	RiskID          string  `json:"risk_id,omitempty"`
+	Title           string  `json:"title,omitempty"`
+	Category        string  `json:"category,omitempty"`
+	Likelihood      string  `json:"likelihood,omitempty"`
+	Impact          string  `json:"impact,omitempty"`
+	Owner           string  `json:"owner,omitempty"`
+	TreatmentOption *string `json:"treatment_option,omitempty"`
+	TargetDate      *string `json:"target_date,omitempty"`
+}
+
+// CatalogueRiskResponse is the created risk together with the suggested
+// controls that match no gap assessment and were therefore not linked.
+type CatalogueRiskResponse struct {
+	RiskRegister
+	UnlinkedControls []string `json:"unlinked_controls"`
+}
+
+const threatColumns = "id, code, name, category, COALESCE(origin, ''), COALESCE(description, ''), suggested_controls"
+
+const vulnerabilityColumns = "id, code, name, category, COALESCE(description, ''), asset_types, suggested_controls"
+
+func scanThreat(row rowScanner, t *Threat) error {
+	t.SuggestedControls = []string{}
+	return row.Scan(&t.ID, &t.Code, &t.Name, &t.Category, &t.Origin, &t.Description, pq.Array(&t.SuggestedControls))
+}
+
+func scanVulnerability(row rowScanner, v *Vulnerability) error {
+	v.AssetTypes, v.SuggestedControls = []string{}, []string{}
+	return row.Scan(&v.ID, &v.Code, &v.Name, &v.Category, &v.Description, pq.Array(&v.AssetTypes), pq.Array(&v.SuggestedControls))
+}
+
+// middleLabel is the default rating when the caller gives none.
+func middleLabel(labels []RiskMatrixLabel) string {
+	sorted := sortedLabels(labels)
+	return sorted[(len(sorted)-1)/2].Label
+}
+
+func lowerFirst(s string) string {
+	r, n := utf8.DecodeRuneInString(s)
+	return string(unicode.ToLower(r)) + s[n:]
+}
+
+// Threat Catalogue Handlers
+func (app *App) getThreats(w http.ResponseWriter, r *http.Request) {
+	query := "SELECT " + threatColumns + " FROM threats"
+	var args []interface{}
+	if category := r.URL.Query().Get("category"); category != "" {
+		query += " WHERE LOWER(category) = LOWER($1)"
+		args = append(args, category)
+	}
+	rows, err := app.DB.Query(query+" ORDER BY code", args...)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	threats := []Threat{}
+	for rows.Next() {
+		var t Threat
+		if err := scanThreat(rows, &t); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		threats = append(threats, t)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(threats)
+}
+
+// getVulnerabilities accepts category and asset_type filters.
+func (app *App) getVulnerabilities(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	var conditions []string
+	var args []interface{}
+	if category := q.Get("category"); category != "" {
+		args = append(args, category)
+		conditions = append(conditions, fmt.Sprintf("LOWER(category) = LOWER($%d)", len(args)))
+	}
+	if assetType := q.Get("asset_type"); assetType != "" {
+		args = append(args, assetType)
+		conditions = append(conditions, fmt.Sprintf("EXISTS (SELECT 1 FROM unnest(asset_types) t WHERE LOWER(t) = LOWER($%d))", len(args)))
+	}
+	query := "SELECT " + vulnerabilityColumns + " FROM vulnerabilities"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	rows, err := app.DB.Query(query+" ORDER BY code", args...)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	vulnerabilities := []Vulnerability{}
+	for rows.Next() {
+		var v Vulnerability
+		if err := scanVulnerability(rows, &v); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		vulnerabilities = append(vulnerabilities, v)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(vulnerabilities)
+}
+
+// createRiskFromCatalogue creates a risk for a threat exploiting a
+// vulnerability, optionally on an asset. The description is pre-filled from
+// the catalogue and the risk is linked to every suggested control found in
+// the gap assessment; suggestions without a matching control are listed in
+// unlinked_controls.
+func (app *App) createRiskFromCatalogue(w http.ResponseWriter, r *http.Request) {
+	var req CatalogueRiskRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.Threat) == "" || strings.TrimSpace(req.Vulnerability) == "" {
+		http.Error(w, "threat and vulnerability are required", http.StatusBadRequest)
+		return
+	}
+
+	var t Threat
+	err := scanThreat(app.DB.QueryRow("SELECT "+threatColumns+" FROM threats WHERE LOWER(code) = LOWER($1)", strings.TrimSpace(req.Threat)), &t)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Threat not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var v Vulnerability
+	err = scanVulnerability(app.DB.QueryRow("SELECT "+vulnerabilityColumns+" FROM vulnerabilities WHERE LOWER(code) = LOWER($1)", strings.TrimSpace(req.Vulnerability)), &v)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Vulnerability not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var asset *Asset
+	if strings.TrimSpace(req.Asset) != "" {
+		asset = &Asset{}
+		err = scanAsset(app.DB.QueryRow("SELECT "+assetColumns+" FROM assets WHERE LOWER(asset_id) = LOWER($1)", strings.TrimSpace(req.Asset)), asset)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Asset not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	matrix, err := loadRiskMatrix(app.DB)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	description := fmt.Sprintf("Threat %s: %s. %s\nVulnerability %s: %s. %s", t.Code, t.Name, t.Description, v.Code, v.Name, v.Description)
+	risk := RiskRegister{
+		RiskID:          req.RiskID,
+		Title:           req.Title,
+		Category:        req.Category,
+		Likelihood:      req.Likelihood,
+		Impact:          req.Impact,
+		TreatmentOption: req.TreatmentOption,
+		TreatmentStatus: "Open",
+		Owner:           req.Owner,
+		TargetDate:      req.TargetDate,
+	}
+	if risk.Title == "" {
+		risk.Title = t.Name + " exploiting " + lowerFirst(v.Name)
+		if asset != nil {
+			risk.Title += " on " + asset.Name
+		}
+	}
+	if risk.Category == "" {
+		risk.Category = t.Category
+	}
+	if risk.Likelihood == "" {
+		risk.Likelihood = middleLabel(matrix.Likelihood)
+	}
+	if asset != nil {
+		risk.ImpactFromAssets = true
+		risk.Impact = assetImpact(matrix, asset.Value)
+		description += fmt.Sprintf("\nAsset %s: %s (%s, %s).", asset.AssetID, asset.Name, asset.Type, asset.Classification)
+		if risk.Owner == "" {
+			risk.Owner = asset.Owner
+		}
+	} else if risk.Impact == "" {
+		risk.Impact = middleLabel(matrix.Impact)
+	}
+	risk.Description = description
+	if strings.TrimSpace(risk.Owner) == "" {
+		http.Error(w, "owner is required when the asset has no owner", http.StatusBadRequest)
+		return
+	}
+
+	if err := scoreRisk(matrix, &risk); err != nil {
+		writeRiskError(w, err)
+		return
+	}
+	if err := normalizeTreatmentOption(&risk); err != nil {
+		writeRiskError(w, err)
+		return
+	}
+
+	var controls []int
+	seen := map[int]bool{}
+	unlinked := []string{}
+	missing := map[string]bool{}
+	for _, ref := range append(t.SuggestedControls, v.SuggestedControls...) {
+		id, err := findControl(app.DB, ref)
+		if err == sql.ErrNoRows {
+			if key := strings.ToUpper(strings.TrimSpace(ref)); !missing[key] {
+				unlinked = append(unlinked, ref)
+				missing[key] = true
+			}
+			continue
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !seen[id] {
+			controls = append(controls, id)
+			seen[id] = true
+		}
+	}
+
+	tx, err := app.DB.Begin()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	if err := app.insertRisk(tx, matrix, &risk, controls); err != nil {
+		writeRiskSaveError(w, &risk, err)
+		return
+	}
+	if asset != nil {
+		_, err = tx.Exec("INSERT INTO risk_assets (risk_register_id, asset_id) VALUES ($1, $2)", risk.ID, asset.ID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(CatalogueRiskResponse{RiskRegister: risk, UnlinkedControls: unlinked})
+}
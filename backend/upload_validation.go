@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// defaultAllowedUploadTypes is used when UPLOAD_ALLOWED_TYPES is not set:
+// documents, spreadsheets, presentations, images and plain text exports.
+const defaultAllowedUploadTypes = "pdf,doc,docx,xls,xlsx,ppt,pptx,odt,ods,odp,png,jpg,jpeg,gif,webp,txt,csv,md,log,json"
+
+// UploadPolicy decides which uploaded files are accepted into evidence storage.
+type UploadPolicy struct {
+	AllowedExtensions map[string]bool // lower-case, without the dot
+	MaxSize           int64           // bytes
+}
+
+// newUploadPolicyFromEnv reads UPLOAD_ALLOWED_TYPES (comma-separated file
+// extensions) and UPLOAD_MAX_SIZE_MB.
+func newUploadPolicyFromEnv() (*UploadPolicy, error) {
+	maxMB, err := strconv.Atoi(getEnv("UPLOAD_MAX_SIZE_MB", "50"))
+	if err != nil || maxMB <= 0 {
+		return nil, fmt.Errorf("invalid UPLOAD_MAX_SIZE_MB %q", getEnv("UPLOAD_MAX_SIZE_MB", ""))
+	}
+
+	policy := &UploadPolicy{AllowedExtensions: map[string]bool{}, MaxSize: int64(maxMB) << 20}
+	for _, ext := range strings.Split(getEnv("UPLOAD_ALLOWED_TYPES", defaultAllowedUploadTypes), ",") {
+		ext = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(ext), "."))
+		if ext != "" {
+			policy.AllowedExtensions[ext] = true
+		}
+	}
+	if len(policy.AllowedExtensions) == 0 {
+		return nil, errors.New("UPLOAD_ALLOWED_TYPES must list at least one file extension")
+	}
+	return policy, nil
+}
+
+// fileSignatures maps an extension to the magic bytes its content must start
+// with. Extensions that are allowed but not listed here are only checked
+// against executableSignatures.
+var fileSignatures = map[string][][]byte{
+	"pdf":  {[]byte("%PDF-")},
+	"png":  {[]byte("\x89PNG\r\n\x1a\n")},
+	"jpg":  {[]byte("\xff\xd8\xff")},
+	"jpeg": {[]byte("\xff\xd8\xff")},
+	"gif":  {[]byte("GIF87a"), []byte("GIF89a")},
+	"webp": {[]byte("RIFF")},
+	// Office Open XML and OpenDocument files are ZIP containers
+	"docx": {[]byte("PK\x03\x04")},
+	"xlsx": {[]byte("PK\x03\x04")},
+	"pptx": {[]byte("PK\x03\x04")},
+	"odt":  {[]byte("PK\x03\x04")},
+	"ods":  {[]byte("PK\x03\x04")},
+	"odp":  {[]byte("PK\x03\x04")},
+	"zip":  {[]byte("PK\x03\x04"), []byte("PK\x05\x06")},
+	// Legacy Office files are OLE compound documents
+	"doc": {[]byte("\xd0\xcf\x11\xe0\xa1\xb1\x1a\xe1")},
+	"xls": {[]byte("\xd0\xcf\x11\xe0\xa1\xb1\x1a\xe1")},
+	"ppt": {[]byte("\xd0\xcf\x11\xe0\xa1\xb1\x1a\xe1")},
+	"msg": {[]byte("\xd0\xcf\x11\xe0\xa1\xb1\x1a\xe1")},
+}
+
+// textExtensions must sniff as plain text (no binary content, no HTML).
+var textExtensions = map[string]bool{
+	"txt": true, "csv": true, "md": true, "log": true, "json": true,
+}
+
+// executableSignatures are rejected whatever the extension or allowlist says:
+// Windows PE, ELF, Mach-O (32/64-bit and universal) and shebang scripts.
+var executableSignatures = [][]byte{
+	[]byte("MZ"),
+	[]byte("\x7fELF"),
+	[]byte("\xfe\xed\xfa\xce"), []byte("\xce\xfa\xed\xfe"),
+	[]byte("\xfe\xed\xfa\xcf"), []byte("\xcf\xfa\xed\xfe"),
+	[]byte("\xca\xfe\xba\xbe"),
+	[]byte("#!/"),
+}
+
+// check validates an upload from its name, size and first bytes. Problems are
+// returned as *uploadError: 413 for oversized files, 415 for disallowed or
+// mismatched content.
+func (p *UploadPolicy) check(name string, size int64, head []byte) error {
+	if size > p.MaxSize {
+		return &uploadError{http.StatusRequestEntityTooLarge, fmt.Sprintf("File is larger than the %d MB upload limit", p.MaxSize>>20)}
+	}
+
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(name), "."))
+	if !p.AllowedExtensions[ext] {
+		return &uploadError{http.StatusUnsupportedMediaType, fmt.Sprintf("File type %q is not allowed; allowed types: %s", "."+ext, p.allowedList())}
+	}
+
+	for _, sig := range executableSignatures {
+		if bytes.HasPrefix(head, sig) {
+			return &uploadError{http.StatusUnsupportedMediaType, "Executable files are not allowed"}
+		}
+	}
+
+	if sigs, ok := fileSignatures[ext]; ok {
+		matched := false
+		for _, sig := range sigs {
+			if bytes.HasPrefix(head, sig) {
+				matched = true
+				break
+			}
+		}
+		if ext == "webp" && (len(head) < 12 || string(head[8:12]) != "WEBP") {
+			matched = false
+		}
+		if !matched {
+			return &uploadError{http.StatusUnsupportedMediaType, fmt.Sprintf("File content does not match its .%s extension", ext)}
+		}
+	}
+
+	if textExtensions[ext] && len(head) > 0 && !strings.HasPrefix(http.DetectContentType(head), "text/plain") {
+		return &uploadError{http.StatusUnsupportedMediaType, fmt.Sprintf("File content does not match its .%s extension", ext)}
+	}
+	return nil
+}
+
+func (p *UploadPolicy) allowedList() string {
+	exts := make([]string, 0, len(p.AllowedExtensions))
+	for ext := range p.AllowedExtensions {
+		exts = append(exts, "."+ext)
+	}
+	sort.Strings(exts)
+	return strings.Join(exts, ", ")
+}
+
+// parseUploadForm parses a multipart upload, refusing request bodies that are
+// clearly over the size limit before they are spooled to disk.
+func (app *App) parseUploadForm(w http.ResponseWriter, r *http.Request) error {
+	// Leave headroom for the other form fields and multipart framing
+	r.Body = http.MaxBytesReader(w, r.Body, app.Uploads.MaxSize+1<<20)
+	if err := r.ParseMultipartForm(maxUploadMemory); err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			return &uploadError{http.StatusRequestEntityTooLarge, fmt.Sprintf("File is larger than the %d MB upload limit", app.Uploads.MaxSize>>20)}
+		}
+		return &uploadError{http.StatusBadRequest, fmt.Sprintf("Invalid multipart form: %v", err)}
+	}
+	return nil
+}
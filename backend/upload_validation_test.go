@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestUploadPolicyCheck(t *testing.T) {
+	p := &UploadPolicy{
+		AllowedExtensions: map[string]bool{"pdf": true, "docx": true, "png": true, "webp": true, "txt": true},
+		MaxSize:           1 << 20,
+	}
+	tests := []struct {
+		name   string
+		file   string
+		size   int64
+		head   string
+		status int // 0 when accepted
+		err    string
+	}{
+		{"pdf", "policy.pdf", 2048, "%PDF-1.7\n", 0, ""},
+		{"extension in upper case", "Policy.PDF", 2048, "%PDF-1.4\n", 0, ""},
+		{"docx is a zip container", "procedure.docx", 4096, "PK\x03\x04\x14\x00", 0, ""},
+		{"webp", "diagram.webp", 512, "RIFF\x00\x01\x00\x00WEBPVP8 ", 0, ""},
+		{"plain text", "export.txt", 64, "user,last_login\nalice,2024-01-02\n", 0, ""},
+		{"empty text file", "notes.txt", 0, "", 0, ""},
+		{"exactly the size limit", "scan.png", 1 << 20, "\x89PNG\r\n\x1a\n", 0, ""},
+
+		{"disallowed extension", "setup.exe", 2048, "MZ\x90\x00", http.StatusUnsupportedMediaType, `File type ".exe" is not allowed`},
+		{"no extension", "README", 64, "hello", http.StatusUnsupportedMediaType, `File type "." is not allowed`},
+		{"double extension", "report.pdf.sh", 64, "#!/bin/sh\n", http.StatusUnsupportedMediaType, `File type ".sh" is not allowed`},
+		{"pdf that is not a pdf", "policy.pdf", 2048, "PK\x03\x04", http.StatusUnsupportedMediaType, "does not match its .pdf extension"},
+		{"riff that is not webp", "clip.webp", 512, "RIFF\x00\x01\x00\x00WAVEfmt ", http.StatusUnsupportedMediaType, "does not match its .webp extension"},
+		{"html saved as text", "page.txt", 64, "<html><script>alert(1)</script>", http.StatusUnsupportedMediaType, "does not match its .txt extension"},
+		{"executable renamed to an allowed type", "invoice.pdf", 2048, "MZ\x90\x00", http.StatusUnsupportedMediaType, "Executable files are not allowed"},
+		{"oversize", "scan.png", 1<<20 + 1, "\x89PNG\r\n\x1a\n", http.StatusRequestEntityTooLarge, "larger than the 1 MB upload limit"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := p.check(tt.file, tt.size, []byte(tt.head))
+			if tt.status == 0 {
+				if err != nil {
+					t.Fatalf("rejected: %v", err)
+				}
+				return
+			}
+			var ue *uploadError
+			if !errors.As(err, &ue) {
+				t.Fatalf("got %v, want *uploadError", err)
+			}
+			if ue.Status != tt.status {
+				t.Errorf("status %d, want %d", ue.Status, tt.status)
+			}
+			if !strings.Contains(ue.Message, tt.err) {
+				t.Errorf("message %q, want it to contain %q", ue.Message, tt.err)
+			}
+		})
+	}
+}
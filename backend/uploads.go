@@ -0,0 +1,453 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// maxUploadMemory caps how much of a multipart upload is held in memory;
+// anything larger is spilled to a temporary file by net/http.
+const maxUploadMemory = 32 << 20
+
+// storedFile describes a file that has been written to evidence storage.
+type storedFile struct {
+	Name   string // sanitised original file name
+	Path   string // storage key, relative to the storage root
+	Size   int64
+	Type   string
+	SHA256 string // hex-encoded digest of the stored content
+	Text   string // extracted searchable text, "" for unsupported types
+}
+
+// uploadError is returned for problems with the upload itself, so handlers can
+// answer with a 4xx instead of a 500.
+type uploadError struct {
+	Status  int
+	Message string
+}
+
+func (e *uploadError) Error() string {
+	return e.Message
+}
+
+func writeUploadError(w http.ResponseWriter, err error) {
+	if ue, ok := err.(*uploadError); ok {
+		http.Error(w, ue.Message, ue.Status)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+// saveUploadedFile validates the multipart file in the given form field against
+// the upload policy, copies it into evidence storage under prefix and reports
+// where it ended up. The request must already have been parsed with parseUploadForm.
+func (app *App) saveUploadedFile(r *http.Request, field, prefix string) (*storedFile, error) {
+	file, header, err := r.FormFile(field)
+	if err != nil {
+		return nil, &uploadError{http.StatusBadRequest, fmt.Sprintf("Missing file in form field %q", field)}
+	}
+	defer file.Close()
+
+	name := sanitizeFileName(header.Filename)
+
+	// Sniff the content type from the first bytes, then rewind for the copy
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+	if err := app.Uploads.check(name, header.Size, head[:n]); err != nil {
+		return nil, err
+	}
+	contentType := detectContentType(name, head[:n])
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	key, err := newStorageKey(prefix, name)
+	if err != nil {
+		return nil, err
+	}
+
+	// Hash and count while streaming to storage
+	hasher := sha256.New()
+	counter := &byteCounter{}
+	if err := app.Storage.Put(key, io.TeeReader(file, io.MultiWriter(hasher, counter)), header.Size, contentType); err != nil {
+		return nil, err
+	}
+
+	stored := &storedFile{Name: name, Path: key, Size: counter.n, Type: contentType, SHA256: hex.EncodeToString(hasher.Sum(nil))}
+
+	// Text extraction is best-effort; a file that cannot be read is still stored
+	if stored.Text, err = extractText(name, file, header.Size); err != nil {
+		log.Printf("Text extraction failed for %s: %v", key, err)
+	}
+	return stored, nil
+}
+
+// byteCounter is an io.Writer that only counts what passes through it.
+type byteCounter struct {
+	n int64
+}
+
+func (c *byteCounter) Write(p []byte) (int, error) {
+	c.n += int64(len(p))
+	return len(p), nil
+}
+
+// removeStoredFile deletes a previously stored file. Missing files are ignored.
+func (app *App) removeStoredFile(key string) error {
+	return app.Storage.Delete(key)
+}
+
+// removeOwnedFiles is the best-effort cleanup after owner's row was deleted.
+// Only keys the upload handlers issue under prefix are removed, and only once
+// no evidence revision or action item refers to them any more: rows written
+// before file fields were server-only may carry any client-chosen path.
+func (app *App) removeOwnedFiles(owner, prefix string, paths []string) {
+	for _, path := range paths {
+		if !strings.HasPrefix(path, prefix+"/") {
+			log.Printf("Keeping stored file %s of %s: not an uploaded %s file", path, owner, prefix)
+			continue
+		}
+		var inUse bool
+		err := app.DB.QueryRow(
+			`SELECT EXISTS (SELECT 1 FROM evidence WHERE file_path = $1)
+			     OR EXISTS (SELECT 1 FROM evidence_versions WHERE file_path = $1)
+			     OR EXISTS (SELECT 1 FROM action_items WHERE file_path = $1)`, path).Scan(&inUse)
+		if err != nil {
+			log.Printf("Warning: could not check stored file %s of %s: %v", path, owner, err)
+			continue
+		}
+		if inUse {
+			continue
+		}
+		if err := app.removeStoredFile(path); err != nil {
+			log.Printf("Warning: could not remove stored file for %s: %v", owner, err)
+		}
+	}
+}
+
+// newStorageKey builds a collision-free key such as
+// "evidence/2026/03/9f2c...-access-policy.pdf".
+func newStorageKey(prefix, name string) (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s/%s/%s-%s", prefix, time.Now().Format("2006/01"), hex.EncodeToString(buf), name), nil
+}
+
+// sanitizeFileName strips any directory part and replaces characters that are
+// awkward in paths or headers.
+func sanitizeFileName(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	var sb strings.Builder
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+			sb.WriteRune(r)
+		default:
+			sb.WriteRune('_')
+		}
+	}
+	clean := strings.Trim(sb.String(), ".")
+	if clean == "" {
+		clean = "file"
+	}
+	if len(clean) > 100 {
+		ext := filepath.Ext(clean)
+		if len(ext) > 10 {
+			ext = ""
+		}
+		clean = clean[:100-len(ext)] + ext
+	}
+	return clean
+}
+
+// detectContentType prefers the sniffed type, falling back to the extension
+// when sniffing is inconclusive (e.g. Office documents sniff as plain ZIP).
+func detectContentType(name string, head []byte) string {
+	sniffed := http.DetectContentType(head)
+	byExt := mime.TypeByExtension(strings.ToLower(filepath.Ext(name)))
+	if byExt != "" && (sniffed == "application/octet-stream" || sniffed == "application/zip" || strings.HasPrefix(sniffed, "text/plain")) {
+		return byExt
+	}
+	return sniffed
+}
+
+// inlineContentTypes are rendered in the browser; anything else (HTML, SVG,
+// scripts, archives...) is always sent as an attachment.
+var inlineContentTypes = map[string]bool{
+	"application/pdf": true,
+	"image/png":       true,
+	"image/jpeg":      true,
+	"image/gif":       true,
+	"image/webp":      true,
+	"text/plain":      true,
+	"text/csv":        true,
+}
+
+// serveStoredFile streams a stored file with Content-Type, Content-Disposition
+// and Range support (via http.ServeContent). Pass ?download=1 to force an
+// attachment instead of inline preview.
+func (app *App) serveStoredFile(w http.ResponseWriter, r *http.Request, key, name, contentType string) {
+	f, info, err := app.Storage.Open(key)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			http.Error(w, "File not found", http.StatusNotFound)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	defer f.Close()
+
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	disposition := "inline"
+	if !inlineContentTypes[mediaType] || r.URL.Query().Get("download") != "" {
+		disposition = "attachment"
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": name}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	http.ServeContent(w, r, name, info.ModTime, f)
+}
+
+// formOptionalInt reads an optional integer form field; blank means nil.
+func formOptionalInt(r *http.Request, key string) (*int, error) {
+	value := strings.TrimSpace(r.FormValue(key))
+	if value == "" {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, &uploadError{http.StatusBadRequest, fmt.Sprintf("Invalid %s", key)}
+	}
+	return &n, nil
+}
+
+// formOptionalString reads an optional form field; blank means nil.
+func formOptionalString(r *http.Request, key string) *string {
+	value := strings.TrimSpace(r.FormValue(key))
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+// Upload Handlers
+func (app *App) uploadEvidence(w http.ResponseWriter, r *http.Request) {
+	if err := app.parseUploadForm(w, r); err != nil {
+		writeUploadError(w, err)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	item := Evidence{
+		Title:           strings.TrimSpace(r.FormValue("title")),
+		Description:     r.FormValue("description"),
+		ClauseReference: r.FormValue("clause_reference"),
+		AnnexReference:  r.FormValue("annex_reference"),
+		UploadedBy:      strings.TrimSpace(r.FormValue("uploaded_by")),
+		CollectedAt:     formOptionalString(r, "collected_at"),
+		ValidUntil:      formOptionalString(r, "valid_until"),
+	}
+	if item.UploadedBy == "" {
+		http.Error(w, "uploaded_by is required", http.StatusBadRequest)
+		return
+	}
+
+	var err error
+	if item.GapAssessmentID, err = formOptionalInt(r, "gap_assessment_id"); err != nil {
+		writeUploadError(w, err)
+		return
+	}
+	if item.MaturityAssessmentID, err = formOptionalInt(r, "maturity_assessment_id"); err != nil {
+		writeUploadError(w, err)
+		return
+	}
+
+	stored, err := app.saveUploadedFile(r, "file", "evidence")
+	if err != nil {
+		writeUploadError(w, err)
+		return
+	}
+
+	size := int(stored.Size)
+	item.FileName = stored.Name
+	item.FilePath = stored.Path
+	item.FileSize = &size
+	item.FileType = stored.Type
+	item.SHA256 = &stored.SHA256
+	if item.Title == "" {
+		item.Title = stored.Name
+	}
+
+	// The evidence row and its first revision are created together
+	tx, err := app.DB.Begin()
+	if err != nil {
+		app.removeStoredFile(stored.Path)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(
+		"INSERT INTO evidence (title, description, file_name, file_path, file_size, file_type, sha256, gap_assessment_id, maturity_assessment_id, clause_reference, annex_reference, uploaded_by, collected_at, valid_until) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14) RETURNING id, uploaded_at, created_at, updated_at",
+		item.Title, item.Description, item.FileName, item.FilePath, item.FileSize, item.FileType, item.SHA256, item.GapAssessmentID, item.MaturityAssessmentID, item.ClauseReference, item.AnnexReference, item.UploadedBy, item.CollectedAt, item.ValidUntil,
+	).Scan(&item.ID, &item.UploadedAt, &item.CreatedAt, &item.UpdatedAt)
+	if err == nil && item.GapAssessmentID != nil {
+		err = linkEvidenceControl(tx, item.ID, *item.GapAssessmentID)
+	}
+	if err == nil {
+		var version *EvidenceVersion
+		if version, err = addEvidenceVersion(tx, item.ID, stored, item.UploadedBy, r.FormValue("change_note")); err == nil {
+			item.CurrentVersionID = &version.ID
+			err = tx.Commit()
+		}
+	}
+	if err != nil {
+		app.removeStoredFile(stored.Path)
+		writeCycleError(w, err, "Control not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(item)
+}
+
+func (app *App) uploadActionItem(w http.ResponseWriter, r *http.Request) {
+	if err := app.parseUploadForm(w, r); err != nil {
+		writeUploadError(w, err)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	item := ActionItem{
+		Title:           strings.TrimSpace(r.FormValue("title")),
+		Description:     r.FormValue("description"),
+		Status:          firstNonEmpty(r.FormValue("status"), "Not Started"),
+		Priority:        firstNonEmpty(r.FormValue("priority"), "Medium"),
+		AssignedTo:      r.FormValue("assigned_to"),
+		DueDate:         formOptionalString(r, "due_date"),
+		CompletedDate:   formOptionalString(r, "completed_date"),
+		Category:        r.FormValue("category"),
+		ClauseReference: formOptionalString(r, "clause_reference"),
+		AnnexReference:  formOptionalString(r, "annex_reference"),
+	}
+
+	var err error
+	if item.GapAssessmentID, err = formOptionalInt(r, "gap_assessment_id"); err != nil {
+		writeUploadError(w, err)
+		return
+	}
+	if item.MaturityAssessmentID, err = formOptionalInt(r, "maturity_assessment_id"); err != nil {
+		writeUploadError(w, err)
+		return
+	}
+
+	stored, err := app.saveUploadedFile(r, "file", "action-items")
+	if err != nil {
+		writeUploadError(w, err)
+		return
+	}
+
+	size := int(stored.Size)
+	item.FileName = &stored.Name
+	item.FilePath = &stored.Path
+	item.FileSize = &size
+	item.FileType = &stored.Type
+	item.SHA256 = &stored.SHA256
+	if item.Title == "" {
+		item.Title = stored.Name
+	}
+
+	err = app.DB.QueryRow(
+		"INSERT INTO action_items (title, description, status, priority, assigned_to, due_date, completed_date, gap_assessment_id, maturity_assessment_id, category, file_name, file_path, file_size, file_type, sha256, clause_reference, annex_reference) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17) RETURNING id, created_at, updated_at",
+		item.Title, item.Description, item.Status, item.Priority, item.AssignedTo, item.DueDate, item.CompletedDate, item.GapAssessmentID, item.MaturityAssessmentID, item.Category, item.FileName, item.FilePath, item.FileSize, item.FileType, item.SHA256, item.ClauseReference, item.AnnexReference,
+	).Scan(&item.ID, &item.CreatedAt, &item.UpdatedAt)
+	if err != nil {
+		app.removeStoredFile(stored.Path)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(item)
+}
+
+// Download Handlers
+func (app *App) downloadEvidenceFile(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	var path, name, fileType sql.NullString
+	err = app.DB.QueryRow("SELECT file_path, file_name, file_type FROM evidence WHERE id = $1", id).Scan(&path, &name, &fileType)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Evidence not found", http.StatusNotFound)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	if path.String == "" {
+		http.Error(w, "Evidence has no stored file", http.StatusNotFound)
+		return
+	}
+
+	app.serveStoredFile(w, r, path.String, name.String, fileType.String)
+}
+
+func (app *App) downloadActionItemFile(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	var path, name, fileType sql.NullString
+	err = app.DB.QueryRow("SELECT file_path, file_name, file_type FROM action_items WHERE id = $1", id).Scan(&path, &name, &fileType)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Action item not found", http.StatusNotFound)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	if path.String == "" {
+		http.Error(w, "Action item has no stored file", http.StatusNotFound)
+		return
+	}
+
+	app.serveStoredFile(w, r, path.String, name.String, fileType.String)
+}
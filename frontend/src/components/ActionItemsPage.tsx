@@ -50,10 +50,6 @@ const ActionItemsPage: React.FC = () => {
     due_date: '',
     gap_assessment_id: '',
     category: '',
-    file_name: '',
-    file_path: '',
-    file_size: '',
-    file_type: '',
     clause_reference: '',
     annex_reference: '',
   });
@@ -96,10 +92,6 @@ const ActionItemsPage: React.FC = () => {
         due_date: item.due_date || '',
         gap_assessment_id: item.gap_assessment_id?.toString() || '',
         category: item.category,
-        file_name: item.file_name || '',
-        file_path: item.file_path || '',
-        file_size: item.file_size?.toString() || '',
-        file_type: item.file_type || '',
         clause_reference: item.clause_reference || '',
         annex_reference: item.annex_reference || '',
       });
@@ -114,10 +106,6 @@ const ActionItemsPage: React.FC = () => {
         due_date: '',
         gap_assessment_id: '',
         category: '',
-        file_name: '',
-        file_path: '',
-        file_size: '',
-        file_type: '',
         clause_reference: '',
         annex_reference: '',
       });
@@ -137,10 +125,6 @@ const ActionItemsPage: React.FC = () => {
         gap_assessment_id: formData.gap_assessment_id ? parseInt(formData.gap_assessment_id) : null,
         due_date: formData.due_date || null,
         completed_date: formData.status === 'Completed' ? new Date().toISOString().split('T')[0] : null,
-        file_size: formData.file_size ? parseInt(formData.file_size) : null,
-        file_name: formData.file_name || null,
-        file_path: formData.file_path || null,
-        file_type: formData.file_type || null,
         clause_reference: formData.clause_reference || null,
         annex_reference: formData.annex_reference || null,
       };
@@ -532,40 +516,6 @@ const ActionItemsPage: React.FC = () => {
                 placeholder="e.g., A.5.1"
               />
             </Box>
-            <Typography variant="subtitle2" sx={{ mt: 1, mb: -1 }}>
-              Evidence/Document Information
-            </Typography>
-            <Box sx={{ display: 'flex', gap: 2 }}>
-              <TextField
-                label="File Name"
-                value={formData.file_name}
-                onChange={(e) => setFormData({ ...formData, file_name: e.target.value })}
-                fullWidth
-              />
-              <TextField
-                label="File Type"
-                value={formData.file_type}
-                onChange={(e) => setFormData({ ...formData, file_type: e.target.value })}
-                fullWidth
-                placeholder="e.g., PDF, DOCX"
-              />
-            </Box>
-            <Box sx={{ display: 'flex', gap: 2 }}>
-              <TextField
-                label="File Path"
-                value={formData.file_path}
-                onChange={(e) => setFormData({ ...formData, file_path: e.target.value })}
-                fullWidth
-                placeholder="/uploads/evidence/..."
-              />
-              <TextField
-                label="File Size (bytes)"
-                type="number"
-                value={formData.file_size}
-                onChange={(e) => setFormData({ ...formData, file_size: e.target.value })}
-                fullWidth
-              />
-            </Box>
           </Box>
         </DialogContent>
         <DialogActions>
@@ -34,14 +34,39 @@ import {
   Legend,
   ResponsiveContainer,
 } from 'recharts';
-import { gapAssessmentService, maturityAssessmentService, actionItemService } from '../services/api';
-import { GapAssessment, MaturityAssessment, ActionItem } from '../types';
+import { gapAssessmentService, maturityAssessmentService, actionItemService, complianceScaleService } from '../services/api';
+import { GapAssessment, MaturityAssessment, ActionItem, ComplianceLevel } from '../types';
 
-const COLORS = {
-  'Fully Compliant': '#4caf50',
-  'Partially Compliant': '#ff9800',
-  'Not Compliant': '#f44336',
-  'Not Applicable': '#9e9e9e',
+// Each compliance level is shown in one of four tones, derived from its
+// weight so a relabelled scale keeps its colours
+type ComplianceTone = 'success' | 'warning' | 'error' | 'default';
+
+const COLORS: Record<ComplianceTone, string> = {
+  success: '#4caf50',
+  warning: '#ff9800',
+  error: '#f44336',
+  default: '#9e9e9e',
+};
+
+// Shown for a tone no level on the scale falls into
+const TONE_NAMES: Record<ComplianceTone, string> = {
+  success: 'Fully Compliant',
+  warning: 'Partially Compliant',
+  error: 'Not Compliant',
+  default: 'Not Applicable',
+};
+
+const DEFAULT_COMPLIANCE_LEVELS: ComplianceLevel[] = [
+  { label: 'Fully Compliant', weight: 1, applicable: true, soa_status: 'Implemented' },
+  { label: 'Partially Compliant', weight: 0.5, applicable: true, soa_status: 'Partially Implemented' },
+  { label: 'Not Compliant', weight: 0, applicable: true, soa_status: 'Planned' },
+  { label: 'Not Applicable', weight: 0, applicable: false, soa_status: 'Not Applicable' },
+];
+
+const complianceTone = (level: ComplianceLevel, topWeight: number): ComplianceTone => {
+  if (!level.applicable) return 'default';
+  if (level.weight <= 0) return 'error';
+  return level.weight >= topWeight ? 'success' : 'warning';
 };
 
 
@@ -49,16 +74,18 @@ const Dashboard: React.FC = () => {
   const [gapData, setGapData] = useState<GapAssessment[]>([]);
   const [maturityData, setMaturityData] = useState<MaturityAssessment[]>([]);
   const [actionItems, setActionItems] = useState<ActionItem[]>([]);
+  const [complianceLevels, setComplianceLevels] = useState<ComplianceLevel[]>(DEFAULT_COMPLIANCE_LEVELS);
   const [loading, setLoading] = useState(true);
 
   useEffect(() => {
     const fetchData = async () => {
       try {
         // Fetch data separately to handle partial failures gracefully
-        const [gapResponse, maturityResponse, actionItemsResponse] = await Promise.allSettled([
+        const [gapResponse, maturityResponse, actionItemsResponse, scaleResponse] = await Promise.allSettled([
           gapAssessmentService.getAll(),
           maturityAssessmentService.getAll(),
           actionItemService.getAll(),
+          complianceScaleService.get(),
         ]);
         
         if (gapResponse.status === 'fulfilled') {
@@ -84,6 +111,12 @@ const Dashboard: React.FC = () => {
           console.error('Error fetching action items:', actionItemsResponse.reason);
           setActionItems([]);
         }
+
+        if (scaleResponse.status === 'fulfilled' && Array.isArray(scaleResponse.value.data?.levels)) {
+          setComplianceLevels(scaleResponse.value.data.levels);
+        } else if (scaleResponse.status === 'rejected') {
+          console.error('Error fetching compliance scale:', scaleResponse.reason);
+        }
       } catch (error) {
         console.error('Unexpected error fetching data:', error);
         // Set empty arrays on error to prevent crashes
@@ -97,6 +130,26 @@ const Dashboard: React.FC = () => {
     fetchData();
   }, []);
 
+  // Tone of each label on the compliance scale and the labels shown for each tone
+  const { toneOf, toneLabels } = useMemo(() => {
+    const topWeight = Math.max(0, ...complianceLevels.filter((l) => l.applicable).map((l) => l.weight));
+    const tones = new Map<string, ComplianceTone>();
+    const names: Record<ComplianceTone, string[]> = { success: [], warning: [], error: [], default: [] };
+    complianceLevels.forEach((level) => {
+      const tone = complianceTone(level, topWeight);
+      tones.set(level.label, tone);
+      names[tone].push(level.label);
+    });
+    const labels = {} as Record<ComplianceTone, string>;
+    (Object.keys(names) as ComplianceTone[]).forEach((tone) => {
+      labels[tone] = names[tone].length > 0 ? names[tone].join(' / ') : TONE_NAMES[tone];
+    });
+    return {
+      toneOf: (label: string): ComplianceTone => tones.get(label) || 'default',
+      toneLabels: labels,
+    };
+  }, [complianceLevels]);
+
   // Gap Assessment Statistics - moved before conditional returns
   const gapComplianceStats = useMemo(() => {
     if (!Array.isArray(gapData) || gapData.length === 0) {
@@ -116,10 +169,13 @@ const Dashboard: React.FC = () => {
   }, [gapComplianceStats]);
 
   const totalGapItems = Array.isArray(gapData) ? gapData.length : 0;
-  const fullyCompliant = gapComplianceStats['Fully Compliant'] || 0;
-  const partiallyCompliant = gapComplianceStats['Partially Compliant'] || 0;
-  const notCompliant = gapComplianceStats['Not Compliant'] || 0;
-  const notApplicable = gapComplianceStats['Not Applicable'] || 0;
+  const toneCount = (tone: ComplianceTone) => Object.entries(gapComplianceStats)
+    .filter(([label]) => toneOf(label) === tone)
+    .reduce((sum, [, count]) => sum + count, 0);
+  const fullyCompliant = toneCount('success');
+  const partiallyCompliant = toneCount('warning');
+  const notCompliant = toneCount('error');
+  const notApplicable = toneCount('default');
   const compliancePercentage = totalGapItems > 0
     ? ((fullyCompliant / totalGapItems) * 100).toFixed(1)
     : '0';
@@ -271,10 +327,11 @@ const Dashboard: React.FC = () => {
       clause.gapItems.push(item);
       clause.standardRefs.add(item.standard_ref);
       clause.gapStats.total++;
-      if (item.compliance === 'Fully Compliant') clause.gapStats.fullyCompliant++;
-      else if (item.compliance === 'Partially Compliant') clause.gapStats.partiallyCompliant++;
-      else if (item.compliance === 'Not Compliant') clause.gapStats.notCompliant++;
-      else if (item.compliance === 'Not Applicable') clause.gapStats.notApplicable++;
+      const tone = toneOf(item.compliance);
+      if (tone === 'success') clause.gapStats.fullyCompliant++;
+      else if (tone === 'warning') clause.gapStats.partiallyCompliant++;
+      else if (tone === 'error') clause.gapStats.notCompliant++;
+      else clause.gapStats.notApplicable++;
       });
     }
 
@@ -346,7 +403,7 @@ const Dashboard: React.FC = () => {
       const bNum = parseInt(b.section) || 999;
       return aNum - bNum;
     });
-  }, [gapData, maturityData]);
+  }, [gapData, maturityData, toneOf]);
 
   // All hooks must be called before any conditional returns
   // Now we can safely return conditionally
@@ -469,7 +526,7 @@ const Dashboard: React.FC = () => {
                   display: 'block'
                 }}
               >
-                Fully Compliant
+                {toneLabels.success}
               </Typography>
               <Typography 
                 variant="h3" 
@@ -663,7 +720,7 @@ const Dashboard: React.FC = () => {
                     {gapChartData.map((entry, index) => (
                       <Cell
                         key={`cell-${index}`}
-                        fill={COLORS[entry.name as keyof typeof COLORS] || '#8884d8'}
+                        fill={COLORS[toneOf(entry.name)]}
                       />
                     ))}
                   </Pie>
@@ -703,7 +760,7 @@ const Dashboard: React.FC = () => {
                       sx={{
                         width: 12,
                         height: 12,
-                        bgcolor: COLORS[item.name as keyof typeof COLORS] || '#8884d8',
+                        bgcolor: COLORS[toneOf(item.name)],
                         mr: 1.5,
                         borderRadius: '50%',
                         boxShadow: '0 2px 4px rgba(0,0,0,0.1)'
@@ -801,7 +858,7 @@ const Dashboard: React.FC = () => {
                       {gapChartData.map((entry, index) => (
                         <Cell
                           key={`cell-${index}`}
-                          fill={COLORS[entry.name as keyof typeof COLORS] || '#8884d8'}
+                          fill={COLORS[toneOf(entry.name)]}
                         />
                       ))}
                     </Bar>
@@ -1002,7 +1059,7 @@ const Dashboard: React.FC = () => {
                     color: 'text.primary'
                   }}
                 >
-                  Fully Compliant
+                  {toneLabels.success}
                 </Typography>
                 <Typography 
                   variant="body2" 
@@ -1029,7 +1086,7 @@ const Dashboard: React.FC = () => {
                     color: 'text.primary'
                   }}
                 >
-                  Partially Compliant
+                  {toneLabels.warning}
                 </Typography>
                 <Typography 
                   variant="body2" 
@@ -1056,7 +1113,7 @@ const Dashboard: React.FC = () => {
                     color: 'text.primary'
                   }}
                 >
-                  Not Compliant
+                  {toneLabels.error}
                 </Typography>
                 <Typography 
                   variant="body2" 
@@ -1085,7 +1142,7 @@ const Dashboard: React.FC = () => {
                         color: 'text.primary'
                       }}
                     >
-                      Not Applicable
+                      {toneLabels.default}
                     </Typography>
                     <Typography 
                       variant="body2" 
@@ -1506,7 +1563,7 @@ const Dashboard: React.FC = () => {
                                 </Typography>
                               </Box>
                               <Box sx={{ display: 'flex', justifyContent: 'space-between', mb: 1 }}>
-                                <Typography variant="body2">Fully Compliant</Typography>
+                                <Typography variant="body2">{toneLabels.success}</Typography>
                                 <Typography variant="body2" fontWeight="bold" color="success.main">
                                   {clause.gapStats.fullyCompliant} ({((clause.gapStats.fullyCompliant / clause.gapStats.total) * 100).toFixed(1)}%)
                                 </Typography>
@@ -1518,7 +1575,7 @@ const Dashboard: React.FC = () => {
                                 sx={{ height: 8, borderRadius: 4, mb: 2 }}
                               />
                               <Box sx={{ display: 'flex', justifyContent: 'space-between', mb: 1 }}>
-                                <Typography variant="body2">Partially Compliant</Typography>
+                                <Typography variant="body2">{toneLabels.warning}</Typography>
                                 <Typography variant="body2" fontWeight="bold" color="warning.main">
                                   {clause.gapStats.partiallyCompliant} ({((clause.gapStats.partiallyCompliant / clause.gapStats.total) * 100).toFixed(1)}%)
                                 </Typography>
@@ -1530,7 +1587,7 @@ const Dashboard: React.FC = () => {
                                 sx={{ height: 8, borderRadius: 4, mb: 2 }}
                               />
                               <Box sx={{ display: 'flex', justifyContent: 'space-between', mb: 1 }}>
-                                <Typography variant="body2">Not Compliant</Typography>
+                                <Typography variant="body2">{toneLabels.error}</Typography>
                                 <Typography variant="body2" fontWeight="bold" color="error.main">
                                   {clause.gapStats.notCompliant} ({((clause.gapStats.notCompliant / clause.gapStats.total) * 100).toFixed(1)}%)
                                 </Typography>
@@ -1649,15 +1706,7 @@ const Dashboard: React.FC = () => {
                                         <Chip
                                           label={item.compliance}
                                           size="small"
-                                          color={
-                                            item.compliance === 'Fully Compliant'
-                                              ? 'success'
-                                              : item.compliance === 'Partially Compliant'
-                                              ? 'warning'
-                                              : item.compliance === 'Not Compliant'
-                                              ? 'error'
-                                              : 'default'
-                                          }
+                                          color={toneOf(item.compliance)}
                                         />
                                       </TableCell>
                                       {clause.maturityItems.length === 0 && <TableCell colSpan={2} />}
@@ -21,8 +21,8 @@ import {
 } from '@mui/material';
 import DownloadIcon from '@mui/icons-material/Download';
 import DescriptionIcon from '@mui/icons-material/Description';
-import { gapAssessmentService } from '../services/api';
-import { GapAssessment } from '../types';
+import { gapAssessmentService, assessmentCycleService } from '../services/api';
+import { GapAssessment, AssessmentCycle } from '../types';
 
 const compareClauseRefs = (a: string, b: string) => {
   const pa = a.split('.').map(x => parseInt(x, 10));
@@ -40,6 +40,8 @@ const DocumentGenerator: React.FC = () => {
   const [selectedClause, setSelectedClause] = useState('');
   const [availableClauses, setAvailableClauses] = useState<string[]>([]);
   const [gapAssessments, setGapAssessments] = useState<GapAssessment[]>([]);
+  const [cycles, setCycles] = useState<AssessmentCycle[]>([]);
+  const [cycle, setCycle] = useState(''); // '' is the current cycle
   const [loading, setLoading] = useState(false);
   const [loadingClauses, setLoadingClauses] = useState(true);
 
@@ -47,12 +49,23 @@ const DocumentGenerator: React.FC = () => {
     fetchData();
   }, []);
 
+  useEffect(() => {
+    if (!loadingClauses) {
+      gapAssessmentService.getAll(cycle ? { cycle } : undefined)
+        .then(response => setGapAssessments(Array.isArray(response.data) ? response.data : []))
+        .catch(error => console.error('Error fetching gap assessments:', error));
+    }
+  }, [cycle]);
+
+  const cycleQuery = cycle ? `?cycle=${encodeURIComponent(cycle)}` : '';
+
   const fetchData = async () => {
     try {
       const API_URL = import.meta.env.VITE_API_URL || 'http://localhost:8080/api';
-      const [clausesResponse, gapResponse] = await Promise.all([
+      const [clausesResponse, gapResponse, cyclesResponse] = await Promise.all([
         fetch(`${API_URL}/templates/clauses`),
         gapAssessmentService.getAll(),
+        assessmentCycleService.getAll(),
       ]);
 
       if (clausesResponse.ok) {
@@ -62,6 +75,7 @@ const DocumentGenerator: React.FC = () => {
       }
 
       setGapAssessments(Array.isArray(gapResponse.data) ? gapResponse.data : []);
+      setCycles(Array.isArray(cyclesResponse.data) ? cyclesResponse.data : []);
     } catch (error) {
       console.error('Error fetching data:', error);
     } finally {
@@ -73,7 +87,7 @@ const DocumentGenerator: React.FC = () => {
     setLoading(true);
     try {
       const API_URL = import.meta.env.VITE_API_URL || 'http://localhost:8080/api';
-      const response = await fetch(`${API_URL}/generate/clause/${clause}`);
+      const response = await fetch(`${API_URL}/generate/clause/${clause}${cycleQuery}`);
       if (response.ok) {
         const blob = await response.blob();
         const url = window.URL.createObjectURL(blob);
@@ -99,7 +113,7 @@ const DocumentGenerator: React.FC = () => {
     setLoading(true);
     try {
       const API_URL = import.meta.env.VITE_API_URL || 'http://localhost:8080/api';
-      const response = await fetch(`${API_URL}/generate/soa`);
+      const response = await fetch(`${API_URL}/generate/soa${cycleQuery}`);
       if (response.ok) {
         const blob = await response.blob();
         const url = window.URL.createObjectURL(blob);
@@ -125,7 +139,7 @@ const DocumentGenerator: React.FC = () => {
     setLoading(true);
     try {
       const API_URL = import.meta.env.VITE_API_URL || 'http://localhost:8080/api';
-      const response = await fetch(`${API_URL}/generate/notion-export`);
+      const response = await fetch(`${API_URL}/generate/notion-export${cycleQuery}`);
       if (response.ok) {
         const blob = await response.blob();
         const url = window.URL.createObjectURL(blob);
@@ -147,6 +161,32 @@ const DocumentGenerator: React.FC = () => {
     }
   };
 
+  const handleExportAuditPack = async () => {
+    setLoading(true);
+    try {
+      const API_URL = import.meta.env.VITE_API_URL || 'http://localhost:8080/api';
+      const response = await fetch(`${API_URL}/export/audit-pack${cycleQuery}`);
+      if (response.ok) {
+        const blob = await response.blob();
+        const url = window.URL.createObjectURL(blob);
+        const a = document.createElement('a');
+        a.href = url;
+        a.download = `ISO27001-Audit-Pack-${new Date().toISOString().split('T')[0]}.zip`;
+        document.body.appendChild(a);
+        a.click();
+        window.URL.revokeObjectURL(url);
+        document.body.removeChild(a);
+      } else {
+        alert('Error exporting audit pack');
+      }
+    } catch (error) {
+      console.error('Error exporting audit pack:', error);
+      alert('Error exporting audit pack');
+    } finally {
+      setLoading(false);
+    }
+  };
+
   // Get unique clauses from gap assessments
   const uniqueClauses = Array.from(
     new Set(gapAssessments.map(a => {
@@ -186,6 +226,21 @@ const DocumentGenerator: React.FC = () => {
               <Typography variant="h6" gutterBottom>
                 Quick Actions
               </Typography>
+              <FormControl fullWidth sx={{ mb: 2 }}>
+                <InputLabel>Assessment Cycle</InputLabel>
+                <Select
+                  value={cycle}
+                  onChange={(e) => setCycle(e.target.value)}
+                  label="Assessment Cycle"
+                >
+                  <MenuItem value="">Current cycle</MenuItem>
+                  {cycles.map((c) => (
+                    <MenuItem key={c.id} value={String(c.id)}>
+                      {c.name}{c.status === 'closed' ? ' (closed)' : ''}
+                    </MenuItem>
+                  ))}
+                </Select>
+              </FormControl>
               <Box sx={{ display: 'flex', flexDirection: 'column', gap: 2 }}>
                 <Button
                   variant="contained"
@@ -205,6 +260,15 @@ const DocumentGenerator: React.FC = () => {
                 >
                   Export All to Notion Format
                 </Button>
+                <Button
+                  variant="outlined"
+                  startIcon={<DownloadIcon />}
+                  onClick={handleExportAuditPack}
+                  disabled={loading}
+                  fullWidth
+                >
+                  Download Audit Pack (ZIP)
+                </Button>
               </Box>
             </CardContent>
           </Card>
@@ -43,10 +43,6 @@ const EvidencePage: React.FC = () => {
   const [formData, setFormData] = useState({
     title: '',
     description: '',
-    file_name: '',
-    file_path: '',
-    file_size: '',
-    file_type: '',
     gap_assessment_id: '',
     clause_reference: '',
     annex_reference: '',
@@ -80,10 +76,6 @@ const EvidencePage: React.FC = () => {
       setFormData({
         title: item.title,
         description: item.description,
-        file_name: item.file_name,
-        file_path: item.file_path,
-        file_size: item.file_size?.toString() || '',
-        file_type: item.file_type,
         gap_assessment_id: item.gap_assessment_id?.toString() || '',
         clause_reference: item.clause_reference,
         annex_reference: item.annex_reference,
@@ -94,10 +86,6 @@ const EvidencePage: React.FC = () => {
       setFormData({
         title: '',
         description: '',
-        file_name: '',
-        file_path: '',
-        file_size: '',
-        file_type: '',
         gap_assessment_id: '',
         clause_reference: '',
         annex_reference: '',
@@ -117,7 +105,6 @@ const EvidencePage: React.FC = () => {
       const payload = {
         ...formData,
         gap_assessment_id: formData.gap_assessment_id ? parseInt(formData.gap_assessment_id) : null,
-        file_size: formData.file_size ? parseInt(formData.file_size) : null,
       };
       if (editing) {
         await evidenceService.update(editing.id, payload);
@@ -340,37 +327,6 @@ const EvidencePage: React.FC = () => {
               multiline
               rows={3}
             />
-            <Box sx={{ display: 'flex', gap: 2 }}>
-              <TextField
-                label="File Name"
-                value={formData.file_name}
-                onChange={(e) => setFormData({ ...formData, file_name: e.target.value })}
-                fullWidth
-              />
-              <TextField
-                label="File Type"
-                value={formData.file_type}
-                onChange={(e) => setFormData({ ...formData, file_type: e.target.value })}
-                fullWidth
-                placeholder="e.g., PDF, DOCX"
-              />
-            </Box>
-            <Box sx={{ display: 'flex', gap: 2 }}>
-              <TextField
-                label="File Path"
-                value={formData.file_path}
-                onChange={(e) => setFormData({ ...formData, file_path: e.target.value })}
-                fullWidth
-                placeholder="/uploads/evidence/..."
-              />
-              <TextField
-                label="File Size (bytes)"
-                type="number"
-                value={formData.file_size}
-                onChange={(e) => setFormData({ ...formData, file_size: e.target.value })}
-                fullWidth
-              />
-            </Box>
             <Box sx={{ display: 'flex', gap: 2 }}>
               <TextField
                 label="Clause Reference"
@@ -26,7 +26,7 @@ import {
 import EditIcon from '@mui/icons-material/Edit';
 import DeleteIcon from '@mui/icons-material/Delete';
 import AddIcon from '@mui/icons-material/Add';
-import { gapAssessmentService } from '../services/api';
+import { gapAssessmentService, complianceScaleService } from '../services/api';
 import { GapAssessment } from '../types';
 
 const GapAssessmentPage: React.FC = () => {
@@ -48,17 +48,27 @@ const GapAssessmentPage: React.FC = () => {
     target_date: '',
   });
 
-  const complianceOptions = [
+  const [complianceOptions, setComplianceOptions] = useState<string[]>([
     'Fully Compliant',
     'Partially Compliant',
     'Not Compliant',
     'Not Applicable',
-  ];
+  ]);
 
   useEffect(() => {
     fetchAssessments();
+    fetchComplianceScale();
   }, []);
 
+  const fetchComplianceScale = async () => {
+    try {
+      const response = await complianceScaleService.get();
+      setComplianceOptions(response.data.levels.map((level) => level.label));
+    } catch (error) {
+      console.error('Error fetching compliance scale:', error);
+    }
+  };
+
   const fetchAssessments = async () => {
     try {
       const response = await gapAssessmentService.getAll();
@@ -23,17 +23,20 @@ import {
   CircularProgress,
   Chip,
   Menu,
+  Checkbox,
+  FormControlLabel,
 } from '@mui/material';
 import EditIcon from '@mui/icons-material/Edit';
 import DeleteIcon from '@mui/icons-material/Delete';
 import AddIcon from '@mui/icons-material/Add';
 import FilterListIcon from '@mui/icons-material/FilterList';
-import { riskService, gapAssessmentService } from '../services/api';
-import { RiskRegister, GapAssessment } from '../types';
+import { riskService, riskMatrixService, gapAssessmentService } from '../services/api';
+import { RiskRegister, RiskMatrix, GapAssessment, TreatmentOption } from '../types';
 
 const RiskRegisterPage: React.FC = () => {
   const [risks, setRisks] = useState<RiskRegister[]>([]);
   const [gapAssessments, setGapAssessments] = useState<GapAssessment[]>([]);
+  const [matrix, setMatrix] = useState<RiskMatrix | null>(null);
   const [loading, setLoading] = useState(true);
   const [open, setOpen] = useState(false);
   const [editing, setEditing] = useState<RiskRegister | null>(null);
@@ -47,20 +50,37 @@ const RiskRegisterPage: React.FC = () => {
     category: '',
     likelihood: 'Medium',
     impact: 'Medium',
+    impact_from_assets: false,
     risk_level: 'Medium',
     current_controls: '',
     treatment_plan: '',
+    treatment_option: '',
     treatment_status: 'Open',
     owner: '',
     target_date: '',
     gap_assessment_id: '',
     annex_a_controls: '',
+    frequency_min: '',
+    frequency_most_likely: '',
+    frequency_max: '',
+    loss_min: '',
+    loss_most_likely: '',
+    loss_max: '',
   });
 
-  const likelihoodOptions = ['Very Low', 'Low', 'Medium', 'High', 'Very High'];
-  const impactOptions = ['Very Low', 'Low', 'Medium', 'High', 'Very High'];
-  const riskLevelOptions = ['Very Low', 'Low', 'Medium', 'High', 'Very High', 'Critical'];
+  const defaultScale = ['Very Low', 'Low', 'Medium', 'High', 'Very High'];
+  const likelihoodOptions = matrix ? matrix.likelihood.map(l => l.label) : defaultScale;
+  const impactOptions = matrix ? matrix.impact.map(l => l.label) : defaultScale;
+  const riskLevelOptions = matrix
+    ? [...matrix.thresholds].sort((a, b) => a.min_score - b.min_score).map(t => t.level)
+    : [...defaultScale, 'Critical'];
   const statusOptions = ['Open', 'In Progress', 'Mitigated', 'Accepted', 'Transferred'];
+  const treatmentOptions: { value: TreatmentOption; label: string }[] = [
+    { value: 'modify', label: 'Modify (mitigate)' },
+    { value: 'retain', label: 'Retain (accept)' },
+    { value: 'avoid', label: 'Avoid' },
+    { value: 'share', label: 'Share (transfer)' },
+  ];
 
   useEffect(() => {
     fetchData();
@@ -68,10 +88,12 @@ const RiskRegisterPage: React.FC = () => {
 
   const fetchData = async () => {
     try {
-      const [risksResponse, gapResponse] = await Promise.all([
+      const [risksResponse, gapResponse, matrixResponse] = await Promise.all([
         riskService.getAll(),
         gapAssessmentService.getAll(),
+        riskMatrixService.get(),
       ]);
+      setMatrix(matrixResponse.data);
       setRisks(Array.isArray(risksResponse.data) ? risksResponse.data : []);
       setGapAssessments(Array.isArray(gapResponse.data) ? gapResponse.data : []);
     } catch (error) {
@@ -83,23 +105,17 @@ const RiskRegisterPage: React.FC = () => {
     }
   };
 
+  // Preview only: the backend recalculates score and level from the risk matrix on save
   const calculateRiskLevel = (likelihood: string, impact: string): string => {
-    const likelihoodMap: Record<string, number> = {
-      'Very Low': 1,
-      'Low': 2,
-      'Medium': 3,
-      'High': 4,
-      'Very High': 5,
-    };
-    const impactMap: Record<string, number> = {
-      'Very Low': 1,
-      'Low': 2,
-      'Medium': 3,
-      'High': 4,
-      'Very High': 5,
-    };
-
-    const score = (likelihoodMap[likelihood] || 3) * (impactMap[impact] || 3);
+    if (matrix) {
+      const l = matrix.likelihood.find(x => x.label === likelihood)?.weight ?? 0;
+      const i = matrix.impact.find(x => x.label === impact)?.weight ?? 0;
+      const score = l * i;
+      const match = matrix.thresholds.find(t => score >= t.min_score);
+      return match ? match.level : matrix.thresholds[matrix.thresholds.length - 1].level;
+    }
+    const weight = (label: string) => defaultScale.indexOf(label) + 1 || 3;
+    const score = weight(likelihood) * weight(impact);
     if (score >= 20) return 'Critical';
     if (score >= 15) return 'Very High';
     if (score >= 10) return 'High';
@@ -118,32 +134,48 @@ const RiskRegisterPage: React.FC = () => {
         category: risk.category,
         likelihood: risk.likelihood,
         impact: risk.impact,
+        impact_from_assets: !!risk.impact_from_assets,
         risk_level: risk.risk_level,
         current_controls: risk.current_controls,
         treatment_plan: risk.treatment_plan,
+        treatment_option: risk.treatment_option || '',
         treatment_status: risk.treatment_status,
         owner: risk.owner,
         target_date: risk.target_date || '',
         gap_assessment_id: risk.gap_assessment_id?.toString() || '',
         annex_a_controls: risk.annex_a_controls,
+        frequency_min: risk.frequency_min?.toString() ?? '',
+        frequency_most_likely: risk.frequency_most_likely?.toString() ?? '',
+        frequency_max: risk.frequency_max?.toString() ?? '',
+        loss_min: risk.loss_min?.toString() ?? '',
+        loss_most_likely: risk.loss_most_likely?.toString() ?? '',
+        loss_max: risk.loss_max?.toString() ?? '',
       });
     } else {
       setEditing(null);
       setFormData({
-        risk_id: `RISK-${Date.now()}`,
+        risk_id: '',
         title: '',
         description: '',
         category: '',
         likelihood: 'Medium',
         impact: 'Medium',
+        impact_from_assets: false,
         risk_level: 'Medium',
         current_controls: '',
         treatment_plan: '',
+        treatment_option: '',
         treatment_status: 'Open',
         owner: '',
         target_date: '',
         gap_assessment_id: '',
         annex_a_controls: '',
+        frequency_min: '',
+        frequency_most_likely: '',
+        frequency_max: '',
+        loss_min: '',
+        loss_most_likely: '',
+        loss_max: '',
       });
     }
     setOpen(true);
@@ -163,12 +195,21 @@ const RiskRegisterPage: React.FC = () => {
     setFormData({ ...newFormData, risk_level: newRiskLevel });
   };
 
+  const toNumber = (value: string) => (value.trim() === '' ? null : Number(value));
+
   const handleSubmit = async () => {
     try {
       const payload = {
         ...formData,
         gap_assessment_id: formData.gap_assessment_id ? parseInt(formData.gap_assessment_id) : null,
         target_date: formData.target_date || null,
+        treatment_option: (formData.treatment_option || null) as TreatmentOption | null,
+        frequency_min: toNumber(formData.frequency_min),
+        frequency_most_likely: toNumber(formData.frequency_most_likely),
+        frequency_max: toNumber(formData.frequency_max),
+        loss_min: toNumber(formData.loss_min),
+        loss_most_likely: toNumber(formData.loss_most_likely),
+        loss_max: toNumber(formData.loss_max),
       };
       if (editing) {
         await riskService.update(editing.id, payload);
@@ -308,6 +349,7 @@ const RiskRegisterPage: React.FC = () => {
               <TableCell>Likelihood</TableCell>
               <TableCell>Impact</TableCell>
               <TableCell>Risk Level</TableCell>
+              <TableCell>Residual</TableCell>
               <TableCell>Status</TableCell>
               <TableCell>Owner</TableCell>
               <TableCell>Target Date</TableCell>
@@ -317,7 +359,7 @@ const RiskRegisterPage: React.FC = () => {
           <TableBody>
             {filteredRisks.length === 0 ? (
               <TableRow>
-                <TableCell colSpan={10} align="center" sx={{ py: 4 }}>
+                <TableCell colSpan={11} align="center" sx={{ py: 4 }}>
                   <Typography variant="body2" color="text.secondary">
                     {risks && risks.length === 0
                       ? 'No risks found. Click "Add Risk" to create one.'
@@ -369,6 +411,18 @@ const RiskRegisterPage: React.FC = () => {
                         size="small"
                       />
                     </TableCell>
+                    <TableCell>
+                      {risk.residual_level ? (
+                        <Chip
+                          label={risk.residual_level}
+                          color={getRiskLevelColor(risk.residual_level) as any}
+                          size="small"
+                          variant="outlined"
+                        />
+                      ) : (
+                        '-'
+                      )}
+                    </TableCell>
                     <TableCell>
                       <Chip
                         label={risk.treatment_status}
@@ -425,9 +479,9 @@ const RiskRegisterPage: React.FC = () => {
               <TextField
                 label="Risk ID"
                 value={formData.risk_id}
-                onChange={(e) => setFormData({ ...formData, risk_id: e.target.value })}
                 fullWidth
-                required
+                disabled
+                helperText={editing ? undefined : 'Assigned when the risk is created'}
               />
               <TextField
                 label="Category"
@@ -472,6 +526,7 @@ const RiskRegisterPage: React.FC = () => {
                   value={formData.impact}
                   onChange={(e) => handleLikelihoodOrImpactChange('impact', e.target.value)}
                   label="Impact"
+                  disabled={formData.impact_from_assets}
                 >
                   {impactOptions.map((option) => (
                     <MenuItem key={option} value={option}>
@@ -484,8 +539,8 @@ const RiskRegisterPage: React.FC = () => {
                 <InputLabel>Risk Level (Auto-calculated)</InputLabel>
                 <Select
                   value={formData.risk_level}
-                  onChange={(e) => setFormData({ ...formData, risk_level: e.target.value })}
                   label="Risk Level (Auto-calculated)"
+                  disabled
                 >
                   {riskLevelOptions.map((option) => (
                     <MenuItem key={option} value={option}>
@@ -495,6 +550,15 @@ const RiskRegisterPage: React.FC = () => {
                 </Select>
               </FormControl>
             </Box>
+            <FormControlLabel
+              control={
+                <Checkbox
+                  checked={formData.impact_from_assets}
+                  onChange={(e) => setFormData({ ...formData, impact_from_assets: e.target.checked })}
+                />
+              }
+              label="Derive impact from the most valuable linked asset"
+            />
             <TextField
               label="Current Controls"
               value={formData.current_controls}
@@ -512,6 +576,23 @@ const RiskRegisterPage: React.FC = () => {
               rows={3}
             />
             <Box sx={{ display: 'flex', gap: 2 }}>
+              <FormControl fullWidth>
+                <InputLabel>Treatment Option</InputLabel>
+                <Select
+                  value={formData.treatment_option}
+                  onChange={(e) => setFormData({ ...formData, treatment_option: e.target.value })}
+                  label="Treatment Option"
+                >
+                  <MenuItem value="">
+                    <em>Not decided</em>
+                  </MenuItem>
+                  {treatmentOptions.map((option) => (
+                    <MenuItem key={option.value} value={option.value}>
+                      {option.label}
+                    </MenuItem>
+                  ))}
+                </Select>
+              </FormControl>
               <FormControl fullWidth>
                 <InputLabel>Treatment Status</InputLabel>
                 <Select
@@ -520,7 +601,11 @@ const RiskRegisterPage: React.FC = () => {
                   label="Treatment Status"
                 >
                   {statusOptions.map((status) => (
-                    <MenuItem key={status} value={status}>
+                    <MenuItem
+                      key={status}
+                      value={status}
+                      disabled={status === 'Accepted' && editing?.treatment_status !== 'Accepted'}
+                    >
                       {status}
                     </MenuItem>
                   ))}
@@ -562,13 +647,68 @@ const RiskRegisterPage: React.FC = () => {
               onChange={(e) => setFormData({ ...formData, annex_a_controls: e.target.value })}
               fullWidth
               placeholder="e.g., A.5.1, A.8.2"
-              helperText="Comma-separated list of Annex A controls"
+              helperText="Comma-separated list of Annex A controls; each must match a gap assessment control"
             />
+            <Typography variant="subtitle2" color="text.secondary">
+              Quantitative estimate (optional; fill in all six to include the risk in loss simulations)
+            </Typography>
+            <Box sx={{ display: 'flex', gap: 2 }}>
+              <TextField
+                label="Events/Year Min"
+                type="number"
+                value={formData.frequency_min}
+                onChange={(e) => setFormData({ ...formData, frequency_min: e.target.value })}
+                fullWidth
+                inputProps={{ min: 0 }}
+              />
+              <TextField
+                label="Events/Year Most Likely"
+                type="number"
+                value={formData.frequency_most_likely}
+                onChange={(e) => setFormData({ ...formData, frequency_most_likely: e.target.value })}
+                fullWidth
+                inputProps={{ min: 0 }}
+              />
+              <TextField
+                label="Events/Year Max"
+                type="number"
+                value={formData.frequency_max}
+                onChange={(e) => setFormData({ ...formData, frequency_max: e.target.value })}
+                fullWidth
+                inputProps={{ min: 0 }}
+              />
+            </Box>
+            <Box sx={{ display: 'flex', gap: 2 }}>
+              <TextField
+                label="Loss/Event Min"
+                type="number"
+                value={formData.loss_min}
+                onChange={(e) => setFormData({ ...formData, loss_min: e.target.value })}
+                fullWidth
+                inputProps={{ min: 0 }}
+              />
+              <TextField
+                label="Loss/Event Most Likely"
+                type="number"
+                value={formData.loss_most_likely}
+                onChange={(e) => setFormData({ ...formData, loss_most_likely: e.target.value })}
+                fullWidth
+                inputProps={{ min: 0 }}
+              />
+              <TextField
+                label="Loss/Event Max"
+                type="number"
+                value={formData.loss_max}
+                onChange={(e) => setFormData({ ...formData, loss_max: e.target.value })}
+                fullWidth
+                inputProps={{ min: 0 }}
+              />
+            </Box>
           </Box>
         </DialogContent>
         <DialogActions>
           <Button onClick={handleClose}>Cancel</Button>
-          <Button onClick={handleSubmit} variant="contained" disabled={!formData.title || !formData.risk_id}>
+          <Button onClick={handleSubmit} variant="contained" disabled={!formData.title}>
             {editing ? 'Update' : 'Create'}
           </Button>
         </DialogActions>
@@ -1,5 +1,5 @@
 import axios from 'axios';
-import { GapAssessment, MaturityAssessment, ActionItem, Evidence, RiskRegister } from '../types';
+import { GapAssessment, ComplianceScale, AssessmentCycle, AssessmentCycleRequest, MaturityAssessment, ActionItem, Evidence, EvidenceVersion, EvidenceControl, RiskRegister, RiskMatrix, RiskReductionReport, RiskAcceptance, RiskControl, RiskHeatmap, RiskAppetite, RiskAppetiteBreach, LossSimulation, Asset, AssetImportReport, Threat, Vulnerability, CatalogueRiskRequest, CatalogueRiskResponse, IntegrityReport, IntegrityIssue, BulkOperation, BulkResponse, StaleEvidence, EvidenceSearchResult } from '../types';
 
 const API_URL = import.meta.env.VITE_API_URL || 'http://localhost:8080/api';
 
@@ -10,52 +10,172 @@ const api = axios.create({
   },
 });
 
+// Filters, sort, limit and offset for list endpoints; the total is in the X-Total-Count header
+export type ListParams = Record<string, string | number | boolean>;
+
+// JSON Merge Patch: omitted fields are left alone, null clears a field
+export type MergePatch<T> = { [K in keyof T]?: T[K] | null };
+
+const mergePatch = <T,>(url: string, data: MergePatch<T>) =>
+  api.patch<T>(url, data, { headers: { 'Content-Type': 'application/merge-patch+json' } });
+
 export const gapAssessmentService = {
-  getAll: () => api.get<GapAssessment[]>('/gap-assessments'),
+  getAll: (params?: ListParams) => api.get<GapAssessment[]>('/gap-assessments', { params }),
   getById: (id: number) => api.get<GapAssessment>(`/gap-assessments/${id}`),
   create: (data: Omit<GapAssessment, 'id' | 'created_at' | 'updated_at'>) =>
     api.post<GapAssessment>('/gap-assessments', data),
   update: (id: number, data: Partial<GapAssessment>) =>
     api.put<GapAssessment>(`/gap-assessments/${id}`, data),
+  patch: (id: number, data: MergePatch<GapAssessment>) => mergePatch<GapAssessment>(`/gap-assessments/${id}`, data),
+  bulk: (operations: BulkOperation<GapAssessment>[]) =>
+    api.post<BulkResponse<GapAssessment>>('/gap-assessments/bulk', { operations }),
   delete: (id: number) => api.delete(`/gap-assessments/${id}`),
 };
 
+export const complianceScaleService = {
+  get: () => api.get<ComplianceScale>('/compliance-scale'),
+  update: (scale: Omit<ComplianceScale, 'updated_at'>) => api.put<ComplianceScale>('/compliance-scale', scale),
+};
+
+export const assessmentCycleService = {
+  getAll: () => api.get<AssessmentCycle[]>('/assessment-cycles'),
+  getById: (id: number) => api.get<AssessmentCycle>(`/assessment-cycles/${id}`),
+  create: (data: AssessmentCycleRequest) => api.post<AssessmentCycle>('/assessment-cycles', data),
+  close: (id: number) => api.post<AssessmentCycle>(`/assessment-cycles/${id}/close`),
+};
+
 export const maturityAssessmentService = {
-  getAll: () => api.get<MaturityAssessment[]>('/maturity-assessments'),
+  getAll: (params?: ListParams) => api.get<MaturityAssessment[]>('/maturity-assessments', { params }),
   getById: (id: number) => api.get<MaturityAssessment>(`/maturity-assessments/${id}`),
   create: (data: Omit<MaturityAssessment, 'id' | 'created_at' | 'updated_at'>) =>
     api.post<MaturityAssessment>('/maturity-assessments', data),
   update: (id: number, data: Partial<MaturityAssessment>) =>
     api.put<MaturityAssessment>(`/maturity-assessments/${id}`, data),
+  patch: (id: number, data: MergePatch<MaturityAssessment>) => mergePatch<MaturityAssessment>(`/maturity-assessments/${id}`, data),
   delete: (id: number) => api.delete(`/maturity-assessments/${id}`),
 };
 
 export const actionItemService = {
-  getAll: () => api.get<ActionItem[]>('/action-items'),
+  getAll: (params?: ListParams) => api.get<ActionItem[]>('/action-items', { params }),
   getById: (id: number) => api.get<ActionItem>(`/action-items/${id}`),
   create: (data: Omit<ActionItem, 'id' | 'created_at' | 'updated_at'>) =>
     api.post<ActionItem>('/action-items', data),
+  upload: (form: FormData) =>
+    api.post<ActionItem>('/action-items/upload', form, {
+      headers: { 'Content-Type': 'multipart/form-data' },
+    }),
   update: (id: number, data: Partial<ActionItem>) =>
     api.put<ActionItem>(`/action-items/${id}`, data),
+  patch: (id: number, data: MergePatch<ActionItem>) => mergePatch<ActionItem>(`/action-items/${id}`, data),
+  bulk: (operations: BulkOperation<ActionItem>[]) =>
+    api.post<BulkResponse<ActionItem>>('/action-items/bulk', { operations }),
   delete: (id: number) => api.delete(`/action-items/${id}`),
+  fileUrl: (id: number, download = false) =>
+    `${API_URL}/action-items/${id}/file${download ? '?download=1' : ''}`,
 };
 
 export const evidenceService = {
-  getAll: () => api.get<Evidence[]>('/evidence'),
+  getAll: (params?: ListParams) => api.get<Evidence[]>('/evidence', { params }),
   getById: (id: number) => api.get<Evidence>(`/evidence/${id}`),
-  create: (data: Omit<Evidence, 'id' | 'uploaded_at' | 'created_at' | 'updated_at'>) =>
+  create: (data: Omit<Evidence, 'id' | 'file_name' | 'file_path' | 'file_size' | 'file_type' | 'uploaded_at' | 'created_at' | 'updated_at'>) =>
     api.post<Evidence>('/evidence', data),
+  upload: (form: FormData) =>
+    api.post<Evidence>('/evidence/upload', form, {
+      headers: { 'Content-Type': 'multipart/form-data' },
+    }),
   update: (id: number, data: Partial<Evidence>) =>
     api.put<Evidence>(`/evidence/${id}`, data),
+  patch: (id: number, data: MergePatch<Evidence>) => mergePatch<Evidence>(`/evidence/${id}`, data),
   delete: (id: number) => api.delete(`/evidence/${id}`),
+  fileUrl: (id: number, download = false) =>
+    `${API_URL}/evidence/${id}/file${download ? '?download=1' : ''}`,
+  verify: () => api.post<IntegrityReport>('/evidence/verify'),
+  getStale: (days = 30) => api.get<StaleEvidence[]>('/evidence/stale', { params: { days } }),
+  search: (q: string, limit = 20) => api.get<EvidenceSearchResult[]>('/evidence/search', { params: { q, limit } }),
+  reindex: () => api.post<{ checked: number; indexed: number; errors: IntegrityIssue[] }>('/evidence/reindex'),
+  getVersions: (id: number) => api.get<EvidenceVersion[]>(`/evidence/${id}/versions`),
+  uploadVersion: (id: number, form: FormData) =>
+    api.post<EvidenceVersion>(`/evidence/${id}/versions`, form, {
+      headers: { 'Content-Type': 'multipart/form-data' },
+    }),
+  versionFileUrl: (id: number, version: number) =>
+    `${API_URL}/evidence/${id}/versions/${version}/file`,
+  setCurrentVersion: (id: number, version: number) =>
+    api.put<Evidence>(`/evidence/${id}/versions/${version}/current`),
+  getControls: (id: number) => api.get<EvidenceControl[]>(`/evidence/${id}/controls`),
+  attachControl: (id: number, link: { gap_assessment_id?: number; standard_ref?: string }) =>
+    api.post<EvidenceControl>(`/evidence/${id}/controls`, link),
+  detachControl: (id: number, gapAssessmentId: number) =>
+    api.delete(`/evidence/${id}/controls/${gapAssessmentId}`),
+  getByControl: (gapAssessmentId: number) => api.get<Evidence[]>(`/gap-assessments/${gapAssessmentId}/evidence`),
 };
 
 export const riskService = {
-  getAll: () => api.get<RiskRegister[]>('/risks'),
+  getAll: (params?: ListParams) => api.get<RiskRegister[]>('/risks', { params }),
   getById: (id: number) => api.get<RiskRegister>(`/risks/${id}`),
-  create: (data: Omit<RiskRegister, 'id' | 'created_at' | 'updated_at'>) =>
+  create: (data: Omit<RiskRegister, 'id' | 'risk_id' | 'created_at' | 'updated_at'> & { risk_id?: string }) =>
     api.post<RiskRegister>('/risks', data),
   update: (id: number, data: Partial<RiskRegister>) =>
     api.put<RiskRegister>(`/risks/${id}`, data),
+  patch: (id: number, data: MergePatch<RiskRegister>) => mergePatch<RiskRegister>(`/risks/${id}`, data),
   delete: (id: number) => api.delete(`/risks/${id}`),
+  getReductionReport: () => api.get<RiskReductionReport>('/reports/risk-reduction'),
+  getUncontrolled: () => api.get<RiskRegister[]>('/risks/uncontrolled'),
+  getHeatmap: (params?: { basis?: 'inherent' | 'residual'; from?: string; to?: string }) =>
+    api.get<RiskHeatmap>('/risks/heatmap', { params }),
+  getAppetiteBreaches: () => api.get<RiskAppetiteBreach[]>('/risks/appetite-breaches'),
+  getLossSimulation: (params?: { iterations?: number; seed?: number }) =>
+    api.get<LossSimulation>('/risks/loss-simulation', { params }),
+  getControls: (id: number) => api.get<RiskControl[]>(`/risks/${id}/controls`),
+  attachControl: (id: number, control: { gap_assessment_id?: number; standard_ref?: string }) =>
+    api.post<RiskControl>(`/risks/${id}/controls`, control),
+  detachControl: (id: number, gapAssessmentId: number) =>
+    api.delete(`/risks/${id}/controls/${gapAssessmentId}`),
+  getByControl: (gapAssessmentId: number) => api.get<RiskRegister[]>(`/gap-assessments/${gapAssessmentId}/risks`),
+  getAssets: (id: number) => api.get<Asset[]>(`/risks/${id}/assets`),
+  attachAsset: (id: number, asset: { id?: number; asset_id?: string }) =>
+    api.post<Asset>(`/risks/${id}/assets`, asset),
+  detachAsset: (id: number, assetId: number) => api.delete(`/risks/${id}/assets/${assetId}`),
+  createFromCatalogue: (data: CatalogueRiskRequest) => api.post<CatalogueRiskResponse>('/risks/from-catalogue', data),
+};
+
+export const riskAcceptanceService = {
+  getByRisk: (riskId: number) => api.get<RiskAcceptance[]>(`/risks/${riskId}/acceptances`),
+  request: (riskId: number, data: { requested_by: string; rationale: string; expires_at: string }) =>
+    api.post<RiskAcceptance>(`/risks/${riskId}/acceptances`, data),
+  decide: (id: number, data: { approver: string; decision: 'approve' | 'reject'; decision_note: string; expires_at?: string }) =>
+    api.post<RiskAcceptance>(`/risk-acceptances/${id}/decision`, data),
+  getPending: () => api.get<RiskAcceptance[]>('/risk-acceptances/pending'),
+  getExpired: () => api.get<RiskAcceptance[]>('/risk-acceptances/expired'),
+};
+
+export const assetService = {
+  getAll: () => api.get<Asset[]>('/assets'),
+  getById: (id: number) => api.get<Asset>(`/assets/${id}`),
+  create: (data: Omit<Asset, 'id' | 'value' | 'created_at' | 'updated_at'>) =>
+    api.post<Asset>('/assets', data),
+  update: (id: number, data: Omit<Asset, 'id' | 'value' | 'created_at' | 'updated_at'>) =>
+    api.put<Asset>(`/assets/${id}`, data),
+  delete: (id: number) => api.delete(`/assets/${id}`),
+  import: (form: FormData) =>
+    api.post<AssetImportReport>('/assets/import', form, {
+      headers: { 'Content-Type': 'multipart/form-data' },
+    }),
+  getRisks: (id: number) => api.get<RiskRegister[]>(`/assets/${id}/risks`),
+};
+
+export const catalogueService = {
+  getThreats: (category?: string) => api.get<Threat[]>('/catalogue/threats', { params: { category } }),
+  getVulnerabilities: (params?: { category?: string; asset_type?: string }) =>
+    api.get<Vulnerability[]>('/catalogue/vulnerabilities', { params }),
+};
+
+export const riskMatrixService = {
+  get: () => api.get<RiskMatrix>('/risk-matrix'),
+  update: (matrix: RiskMatrix) => api.put<RiskMatrix>('/risk-matrix', matrix),
+};
+
+export const riskAppetiteService = {
+  get: () => api.get<RiskAppetite[]>('/risk-appetite'),
+  update: (appetite: Omit<RiskAppetite, 'updated_at'>[]) => api.put<RiskAppetite[]>('/risk-appetite', appetite),
 };
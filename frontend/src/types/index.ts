@@ -8,10 +8,46 @@ export interface GapAssessment {
   notes: string;
   target_date?: string | null;
   action_item_id?: number | null;
+  evidence_refresh_days?: number | null;
+  cycle_id?: number;
+  previous_id?: number | null;
   created_at: string;
   updated_at: string;
 }
 
+export interface AssessmentCycle {
+  id: number;
+  name: string;
+  description: string;
+  status: 'open' | 'closed';
+  copied_from_id?: number | null;
+  closed_at?: string | null;
+  gap_assessments: number;
+  maturity_assessments: number;
+  current: boolean;
+  created_at: string;
+  updated_at: string;
+}
+
+export interface AssessmentCycleRequest {
+  name: string;
+  description?: string;
+  copy_from?: number | string;
+  blank?: boolean;
+}
+
+export interface ComplianceLevel {
+  label: string;
+  weight: number;
+  applicable: boolean;
+  soa_status: string;
+}
+
+export interface ComplianceScale {
+  levels: ComplianceLevel[];
+  updated_at?: string;
+}
+
 export interface ActionItem {
   id: number;
   title: string;
@@ -28,6 +64,7 @@ export interface ActionItem {
   file_path?: string | null;
   file_size?: number | null;
   file_type?: string | null;
+  sha256?: string | null;
   clause_reference?: string | null;
   annex_reference?: string | null;
   created_at: string;
@@ -42,16 +79,67 @@ export interface Evidence {
   file_path: string;
   file_size?: number | null;
   file_type: string;
+  sha256?: string | null;
+  current_version_id?: number | null;
   gap_assessment_id?: number | null;
   maturity_assessment_id?: number | null;
   clause_reference: string;
   annex_reference: string;
   uploaded_by: string;
   uploaded_at: string;
+  collected_at?: string | null;
+  valid_until?: string | null;
   created_at: string;
   updated_at: string;
 }
 
+export interface EvidenceVersion {
+  id: number;
+  evidence_id: number;
+  version_number: number;
+  file_name: string;
+  file_path: string;
+  file_size?: number | null;
+  file_type: string;
+  sha256?: string | null;
+  uploaded_by: string;
+  change_note: string;
+  uploaded_at: string;
+  is_current: boolean;
+}
+
+export interface EvidenceControl {
+  evidence_id: number;
+  gap_assessment_id: number;
+  standard_ref: string;
+  category: string;
+  section: string;
+  assessment_question: string;
+  compliance: string;
+  linked_at: string;
+}
+
+export interface EvidenceSearchResult {
+  evidence_id: number;
+  title: string;
+  file_name: string;
+  snippet: string;
+  rank: number;
+  controls: { gap_assessment_id: number; standard_ref: string }[];
+}
+
+export interface StaleEvidence {
+  evidence_id: number;
+  title: string;
+  file_name: string;
+  controls: string[];
+  collected_at: string;
+  valid_until: string;
+  validity_source: 'valid_until' | 'refresh_interval';
+  days_remaining: number;
+  status: 'expired' | 'expiring';
+}
+
 export interface RiskRegister {
   id: number;
   risk_id: string;
@@ -60,18 +148,239 @@ export interface RiskRegister {
   category: string;
   likelihood: string;
   impact: string;
+  impact_from_assets?: boolean;
   risk_level: string;
+  risk_score?: number | null;
+  residual_likelihood?: string | null;
+  residual_impact?: string | null;
+  residual_score?: number | null;
+  residual_level?: string | null;
+  control_effectiveness?: number | null;
   current_controls: string;
   treatment_plan: string;
+  treatment_option?: TreatmentOption | null;
   treatment_status: string;
   owner: string;
   target_date?: string | null;
   gap_assessment_id?: number | null;
+  frequency_min?: number | null;
+  frequency_most_likely?: number | null;
+  frequency_max?: number | null;
+  loss_min?: number | null;
+  loss_most_likely?: number | null;
+  loss_max?: number | null;
   annex_a_controls: string;
   created_at: string;
   updated_at: string;
 }
 
+export interface Asset {
+  id: number;
+  asset_id: string;
+  name: string;
+  type: 'Information' | 'Software' | 'Hardware' | 'Service' | 'People' | 'Site' | 'Other';
+  description: string;
+  owner: string;
+  location: string;
+  classification: 'Public' | 'Internal' | 'Confidential' | 'Restricted';
+  confidentiality: number;
+  integrity: number;
+  availability: number;
+  value: number;
+  created_at: string;
+  updated_at: string;
+}
+
+export interface AssetImportReport {
+  created: number;
+  updated: number;
+  errors: { line: number; error: string }[];
+}
+
+export interface RiskAppetite {
+  category: string;
+  max_level: string;
+  statement: string;
+  approved_by: string;
+  updated_at?: string;
+}
+
+export interface RiskAppetiteBreach {
+  risk: RiskRegister;
+  category: string;
+  level: string;
+  max_level: string;
+  appetite_category: string;
+  reasons: string[];
+}
+
+export interface LossDistribution {
+  ale: number;
+  p10: number;
+  p50: number;
+  p90: number;
+  p95: number;
+  p99: number;
+  max: number;
+}
+
+export interface RiskLossEstimate extends LossDistribution {
+  id: number;
+  risk_id: string;
+  title: string;
+  probability_of_loss: number;
+}
+
+export interface LossSimulation {
+  iterations: number;
+  seed: number;
+  risks: RiskLossEstimate[];
+  portfolio: LossDistribution;
+  exceedance_curve: { loss: number; probability: number }[];
+  unquantified: string[];
+}
+
+export interface Threat {
+  id: number;
+  code: string;
+  name: string;
+  category: string;
+  origin: string;
+  description: string;
+  suggested_controls: string[];
+}
+
+export interface Vulnerability {
+  id: number;
+  code: string;
+  name: string;
+  category: string;
+  description: string;
+  asset_types: Asset['type'][];
+  suggested_controls: string[];
+}
+
+export interface CatalogueRiskRequest {
+  threat: string;
+  vulnerability: string;
+  asset?: string;
+  risk_id?: string;
+  title?: string;
+  category?: string;
+  likelihood?: string;
+  impact?: string;
+  owner?: string;
+  treatment_option?: TreatmentOption;
+  target_date?: string;
+}
+
+export interface CatalogueRiskResponse extends RiskRegister {
+  unlinked_controls: string[];
+}
+
+export interface RiskControl {
+  risk_register_id: number;
+  gap_assessment_id: number;
+  standard_ref: string;
+  category: string;
+  section: string;
+  assessment_question: string;
+  compliance: string;
+  linked_at: string;
+}
+
+export type TreatmentOption = 'modify' | 'retain' | 'avoid' | 'share';
+
+export interface RiskAcceptance {
+  id: number;
+  risk_register_id: number;
+  risk_ref: string;
+  risk_title: string;
+  risk_owner: string;
+  status: 'pending' | 'approved' | 'rejected' | 'cancelled';
+  requested_by: string;
+  requested_at: string;
+  rationale: string;
+  expires_at: string;
+  approver?: string;
+  decided_at?: string;
+  decision_note?: string;
+}
+
+export interface RiskMatrixLabel {
+  label: string;
+  weight: number;
+}
+
+export interface RiskMatrix {
+  likelihood: RiskMatrixLabel[];
+  impact: RiskMatrixLabel[];
+  thresholds: { level: string; min_score: number }[];
+  updated_at?: string;
+}
+
+export interface RiskHeatmapCell {
+  likelihood: string;
+  impact: string;
+  score: number;
+  level: string;
+  count: number;
+  risk_ids: string[];
+}
+
+export interface RiskBreakdown {
+  key: string;
+  count: number;
+}
+
+export interface RiskHeatmap {
+  basis: 'inherent' | 'residual';
+  from?: string;
+  to?: string;
+  total: number;
+  likelihood: string[];
+  impact: string[];
+  cells: RiskHeatmapCell[];
+  unplaced: string[];
+  by_level: RiskBreakdown[];
+  by_category: RiskBreakdown[];
+  by_owner: RiskBreakdown[];
+  by_treatment_status: RiskBreakdown[];
+}
+
+export interface RiskReductionItem {
+  id: number;
+  risk_id: string;
+  title: string;
+  treatment_plan: string;
+  inherent_score: number | null;
+  inherent_level: string;
+  residual_score: number | null;
+  residual_level: string | null;
+  control_effectiveness: number | null;
+  reduction: number;
+}
+
+export interface RiskReductionGroup {
+  treatment: string;
+  risks: number;
+  inherent_score: number;
+  residual_score: number;
+  reduction: number;
+  reduction_percent: number;
+  items: RiskReductionItem[];
+}
+
+export interface RiskReductionReport {
+  generated_at: string;
+  risks: number;
+  inherent_score: number;
+  residual_score: number;
+  reduction: number;
+  reduction_percent: number;
+  treatments: RiskReductionGroup[];
+}
+
 export interface MaturityAssessment {
   id: number;
   category: string;
@@ -84,6 +393,47 @@ export interface MaturityAssessment {
   target_maturity_level: string;
   target_maturity_score: number | null;
   target_maturity_comments: string;
+  cycle_id?: number;
+  previous_id?: number | null;
   created_at: string;
   updated_at: string;
 }
+
+export interface IntegrityIssue {
+  resource: string;
+  id: number;
+  title: string;
+  file_path: string;
+  expected_sha256: string;
+  actual_sha256?: string;
+  error?: string;
+}
+
+export interface IntegrityReport {
+  verified_at: string;
+  checked: number;
+  passed: number;
+  missing: IntegrityIssue[];
+  modified: IntegrityIssue[];
+  errors: IntegrityIssue[];
+}
+
+// One step of a bulk request: create takes the full record, update a JSON merge patch
+export type BulkOperation<T> =
+  | { op: 'create'; data: Omit<T, 'id' | 'created_at' | 'updated_at'> }
+  | { op: 'update'; id: number; data: { [K in keyof T]?: T[K] | null } }
+  | { op: 'delete'; id: number };
+
+export interface BulkResult<T> {
+  index: number;
+  op: 'create' | 'update' | 'delete';
+  id?: number;
+  status: number;
+  error?: string;
+  data?: T;
+}
+
+export interface BulkResponse<T> {
+  committed: boolean;
+  results: BulkResult<T>[];
+}
@@ -0,0 +1,6 @@
+-- Record a SHA-256 digest of every uploaded file so it can be re-verified later
+ALTER TABLE evidence
+ADD COLUMN IF NOT EXISTS sha256 VARCHAR(64);
+
+ALTER TABLE action_items
+ADD COLUMN IF NOT EXISTS sha256 VARCHAR(64);
@@ -0,0 +1,33 @@
+-- Keep every uploaded evidence file as an immutable revision
+CREATE TABLE IF NOT EXISTS evidence_versions (
+    id SERIAL PRIMARY KEY,
+    evidence_id INTEGER NOT NULL REFERENCES evidence(id) ON DELETE CASCADE,
+    version_number INTEGER NOT NULL,
+    file_name VARCHAR(255) NOT NULL,
+    file_path TEXT NOT NULL,
+    file_size INTEGER,
+    file_type VARCHAR(100) NOT NULL,
+    sha256 VARCHAR(64),
+    uploaded_by VARCHAR(255) NOT NULL,
+    change_note TEXT,
+    uploaded_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (evidence_id, version_number)
+);
+
+-- The evidence row mirrors the file fields of its current revision
+ALTER TABLE evidence
+ADD COLUMN IF NOT EXISTS current_version_id INTEGER REFERENCES evidence_versions(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_evidence_versions_evidence ON evidence_versions(evidence_id);
+
+-- Turn files uploaded before versioning existed into revision 1
+INSERT INTO evidence_versions (evidence_id, version_number, file_name, file_path, file_size, file_type, sha256, uploaded_by, uploaded_at)
+SELECT e.id, 1, e.file_name, e.file_path, e.file_size, e.file_type, e.sha256, e.uploaded_by, e.uploaded_at
+FROM evidence e
+WHERE e.sha256 IS NOT NULL AND e.current_version_id IS NULL
+  AND NOT EXISTS (SELECT 1 FROM evidence_versions v WHERE v.evidence_id = e.id);
+
+UPDATE evidence e SET current_version_id = v.id
+FROM evidence_versions v
+WHERE v.evidence_id = e.id AND e.current_version_id IS NULL
+  AND v.version_number = (SELECT MAX(version_number) FROM evidence_versions WHERE evidence_id = e.id);
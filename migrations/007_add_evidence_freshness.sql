@@ -0,0 +1,10 @@
+-- When evidence was collected and until when it counts
+ALTER TABLE evidence
+ADD COLUMN IF NOT EXISTS collected_at DATE,
+ADD COLUMN IF NOT EXISTS valid_until DATE;
+
+-- How often evidence for a control must be refreshed (e.g. 90 for quarterly access reviews)
+ALTER TABLE gap_assessments
+ADD COLUMN IF NOT EXISTS evidence_refresh_days INTEGER;
+
+CREATE INDEX IF NOT EXISTS idx_evidence_valid_until ON evidence(valid_until);
@@ -0,0 +1,22 @@
+-- Many-to-many link between evidence and the controls (gap assessment rows) it supports
+CREATE TABLE IF NOT EXISTS evidence_controls (
+    evidence_id INTEGER NOT NULL REFERENCES evidence(id) ON DELETE CASCADE,
+    gap_assessment_id INTEGER NOT NULL REFERENCES gap_assessments(id) ON DELETE CASCADE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (evidence_id, gap_assessment_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_evidence_controls_gap_id ON evidence_controls(gap_assessment_id);
+
+-- Carry over existing single-control links
+INSERT INTO evidence_controls (evidence_id, gap_assessment_id)
+SELECT id, gap_assessment_id FROM evidence WHERE gap_assessment_id IS NOT NULL
+ON CONFLICT DO NOTHING;
+
+INSERT INTO evidence_controls (evidence_id, gap_assessment_id)
+SELECT e.id, g.id
+FROM evidence e
+JOIN gap_assessments g ON g.standard_ref IN (
+    TRIM(e.clause_reference), 'Clause-' || TRIM(e.clause_reference), 'Clause ' || TRIM(e.clause_reference),
+    TRIM(e.annex_reference), 'Control-' || TRIM(e.annex_reference))
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,16 @@
+-- Text extracted from uploaded evidence files, per revision and for the current one
+ALTER TABLE evidence_versions
+ADD COLUMN IF NOT EXISTS content_text TEXT;
+
+ALTER TABLE evidence
+ADD COLUMN IF NOT EXISTS content_text TEXT;
+
+-- Full-text search over title, description and file contents
+ALTER TABLE evidence
+ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('english', COALESCE(title, '')), 'A') ||
+    setweight(to_tsvector('english', COALESCE(description, '')), 'B') ||
+    setweight(to_tsvector('english', COALESCE(content_text, '')), 'C')
+) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_evidence_search_vector ON evidence USING GIN (search_vector);
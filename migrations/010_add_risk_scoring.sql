@@ -0,0 +1,27 @@
+-- Single-row likelihood × impact matrix used by the backend to score risks
+CREATE TABLE IF NOT EXISTS risk_matrix (
+    id INTEGER PRIMARY KEY DEFAULT 1 CHECK (id = 1),
+    config JSONB NOT NULL,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Numeric score (likelihood weight × impact weight) so risks sort by severity
+ALTER TABLE risk_register
+ADD COLUMN IF NOT EXISTS risk_score INTEGER;
+
+CREATE INDEX IF NOT EXISTS idx_risk_register_score ON risk_register(risk_score);
+
+-- Score existing risks with the default 5×5 matrix
+UPDATE risk_register r
+SET risk_score = l.weight * i.weight,
+    risk_level = CASE
+        WHEN l.weight * i.weight >= 20 THEN 'Critical'
+        WHEN l.weight * i.weight >= 15 THEN 'Very High'
+        WHEN l.weight * i.weight >= 10 THEN 'High'
+        WHEN l.weight * i.weight >= 6 THEN 'Medium'
+        WHEN l.weight * i.weight >= 3 THEN 'Low'
+        ELSE 'Very Low'
+    END
+FROM (VALUES ('Very Low', 1), ('Low', 2), ('Medium', 3), ('High', 4), ('Very High', 5)) AS l(label, weight),
+     (VALUES ('Very Low', 1), ('Low', 2), ('Medium', 3), ('High', 4), ('Very High', 5)) AS i(label, weight)
+WHERE r.risk_score IS NULL AND r.likelihood = l.label AND r.impact = i.label;
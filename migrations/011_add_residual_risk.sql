@@ -0,0 +1,9 @@
+-- Residual rating of each risk after its linked controls, kept next to the
+-- inherent rating (likelihood, impact, risk_score, risk_level). The backend
+-- recalculates these from control compliance on startup and on every change.
+ALTER TABLE risk_register
+ADD COLUMN IF NOT EXISTS residual_likelihood VARCHAR(50),
+ADD COLUMN IF NOT EXISTS residual_impact VARCHAR(50),
+ADD COLUMN IF NOT EXISTS residual_score INTEGER,
+ADD COLUMN IF NOT EXISTS residual_level VARCHAR(50),
+ADD COLUMN IF NOT EXISTS control_effectiveness REAL;
@@ -0,0 +1,31 @@
+-- Structured ISO 27001 6.1.3 treatment option for each risk
+ALTER TABLE risk_register
+ADD COLUMN IF NOT EXISTS treatment_option VARCHAR(20)
+    CHECK (treatment_option IN ('modify', 'retain', 'avoid', 'share'));
+
+-- Derive the option from the free-text treatment status used so far
+UPDATE risk_register SET treatment_option = CASE
+    WHEN treatment_status = 'Accepted' THEN 'retain'
+    WHEN treatment_status = 'Transferred' THEN 'share'
+    WHEN treatment_status IN ('In Progress', 'Mitigated') THEN 'modify'
+END
+WHERE treatment_option IS NULL;
+
+-- Risk owner approval of retained (accepted) risks, with an expiry
+CREATE TABLE IF NOT EXISTS risk_acceptances (
+    id SERIAL PRIMARY KEY,
+    risk_register_id INTEGER NOT NULL REFERENCES risk_register(id) ON DELETE CASCADE,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'rejected')),
+    requested_by VARCHAR(255),
+    requested_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    rationale TEXT NOT NULL,
+    expires_at DATE NOT NULL,
+    approver VARCHAR(255),
+    decided_at TIMESTAMP,
+    decision_note TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_risk_acceptances_risk_id ON risk_acceptances(risk_register_id);
+
+-- At most one pending request per risk
+CREATE UNIQUE INDEX IF NOT EXISTS idx_risk_acceptances_one_pending ON risk_acceptances(risk_register_id) WHERE status = 'pending';
@@ -0,0 +1,46 @@
+-- Many-to-many links between risks and the controls (gap assessment rows)
+-- that mitigate them, replacing the free-text annex_a_controls column
+CREATE TABLE IF NOT EXISTS risk_controls (
+    risk_register_id INTEGER NOT NULL REFERENCES risk_register(id) ON DELETE CASCADE,
+    gap_assessment_id INTEGER NOT NULL REFERENCES gap_assessments(id) ON DELETE CASCADE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (risk_register_id, gap_assessment_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_risk_controls_gap_id ON risk_controls(gap_assessment_id);
+
+-- Each risk's primary gap assessment link
+INSERT INTO risk_controls (risk_register_id, gap_assessment_id)
+SELECT id, gap_assessment_id FROM risk_register WHERE gap_assessment_id IS NOT NULL
+ON CONFLICT DO NOTHING;
+
+-- Entries of annex_a_controls such as "5.15", "A.5.15" or "Control-5.15"
+INSERT INTO risk_controls (risk_register_id, gap_assessment_id)
+SELECT DISTINCT r.id, g.id
+FROM risk_register r,
+     regexp_split_to_table(COALESCE(r.annex_a_controls, ''), '[,;\n]') AS t(token),
+     gap_assessments g
+WHERE TRIM(token) <> ''
+  AND LOWER(g.standard_ref) IN (
+      LOWER(TRIM(token)),
+      LOWER('Control-' || regexp_replace(TRIM(token), '^(A\.|Control-)\s*', '', 'i')))
+ON CONFLICT DO NOTHING;
+
+-- Entries that match no gap assessment are kept in current_controls
+UPDATE risk_register r
+SET current_controls = CONCAT_WS(E'\n', NULLIF(r.current_controls, ''), 'Unlinked Annex A controls: ' || u.refs)
+FROM (
+    SELECT r.id, string_agg(TRIM(t.token), ', ' ORDER BY t.n) AS refs
+    FROM risk_register r,
+         regexp_split_to_table(COALESCE(r.annex_a_controls, ''), '[,;\n]') WITH ORDINALITY AS t(token, n)
+    WHERE TRIM(t.token) <> ''
+      AND NOT EXISTS (
+          SELECT 1 FROM gap_assessments g
+          WHERE LOWER(g.standard_ref) IN (
+              LOWER(TRIM(t.token)),
+              LOWER('Control-' || regexp_replace(TRIM(t.token), '^(A\.|Control-)\s*', '', 'i'))))
+    GROUP BY r.id
+) u
+WHERE r.id = u.id;
+
+ALTER TABLE risk_register DROP COLUMN IF EXISTS annex_a_controls;
@@ -0,0 +1,33 @@
+-- Information asset inventory (Control-5.9) with CIA ratings from 1 to 5
+CREATE TABLE IF NOT EXISTS assets (
+    id SERIAL PRIMARY KEY,
+    asset_id VARCHAR(100) UNIQUE NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    type VARCHAR(50) NOT NULL,
+    description TEXT,
+    owner VARCHAR(255),
+    location VARCHAR(255),
+    classification VARCHAR(50) NOT NULL,
+    confidentiality INTEGER NOT NULL CHECK (confidentiality BETWEEN 1 AND 5),
+    integrity INTEGER NOT NULL CHECK (integrity BETWEEN 1 AND 5),
+    availability INTEGER NOT NULL CHECK (availability BETWEEN 1 AND 5),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Asset IDs are matched case-insensitively, so they are unique regardless of case
+CREATE UNIQUE INDEX IF NOT EXISTS idx_assets_asset_id_lower ON assets(LOWER(asset_id));
+
+-- Assets a risk was identified against
+CREATE TABLE IF NOT EXISTS risk_assets (
+    risk_register_id INTEGER NOT NULL REFERENCES risk_register(id) ON DELETE CASCADE,
+    asset_id INTEGER NOT NULL REFERENCES assets(id) ON DELETE CASCADE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (risk_register_id, asset_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_risk_assets_asset_id ON risk_assets(asset_id);
+
+-- When set, the risk's impact follows the highest value of its linked assets
+ALTER TABLE risk_register
+ADD COLUMN IF NOT EXISTS impact_from_assets BOOLEAN NOT NULL DEFAULT FALSE;
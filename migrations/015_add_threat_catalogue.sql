@@ -0,0 +1,23 @@
+-- Threat and vulnerability catalogue (in the style of ISO/IEC 27005 Annex C),
+-- seeded from sample_threat_catalogue.json on startup
+CREATE TABLE IF NOT EXISTS threats (
+    id SERIAL PRIMARY KEY,
+    code VARCHAR(50) UNIQUE NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    category VARCHAR(100) NOT NULL,
+    origin VARCHAR(255),
+    description TEXT,
+    suggested_controls TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS vulnerabilities (
+    id SERIAL PRIMARY KEY,
+    code VARCHAR(50) UNIQUE NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    category VARCHAR(100) NOT NULL,
+    description TEXT,
+    asset_types TEXT[] NOT NULL DEFAULT '{}',
+    suggested_controls TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
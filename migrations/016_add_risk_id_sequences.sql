@@ -0,0 +1,6 @@
+-- Counters for server-assigned risk IDs, one row per rendered pattern scope
+-- (e.g. "R-2025-{seq}" with the default pattern R-{YYYY}-{seq:4})
+CREATE TABLE IF NOT EXISTS risk_id_sequences (
+    scope VARCHAR(100) PRIMARY KEY,
+    last_value INTEGER NOT NULL
+);
@@ -0,0 +1,12 @@
+-- Risk appetite set by top management (Clause 6.1): the highest residual risk
+-- level acceptable per risk category. Category '*' is the default.
+CREATE TABLE IF NOT EXISTS risk_appetite (
+    id SERIAL PRIMARY KEY,
+    category VARCHAR(255) NOT NULL,
+    max_level VARCHAR(50) NOT NULL,
+    statement TEXT,
+    approved_by VARCHAR(255),
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_risk_appetite_category ON risk_appetite(LOWER(category));
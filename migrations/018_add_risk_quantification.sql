@@ -0,0 +1,9 @@
+-- Optional FAIR-style estimates: loss event frequency (events per year) and
+-- loss magnitude (per event), each as min / most likely / max
+ALTER TABLE risk_register
+ADD COLUMN IF NOT EXISTS frequency_min DOUBLE PRECISION,
+ADD COLUMN IF NOT EXISTS frequency_most_likely DOUBLE PRECISION,
+ADD COLUMN IF NOT EXISTS frequency_max DOUBLE PRECISION,
+ADD COLUMN IF NOT EXISTS loss_min DOUBLE PRECISION,
+ADD COLUMN IF NOT EXISTS loss_most_likely DOUBLE PRECISION,
+ADD COLUMN IF NOT EXISTS loss_max DOUBLE PRECISION;
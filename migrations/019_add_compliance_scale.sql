@@ -0,0 +1,16 @@
+-- Configurable compliance scale for gap assessments: the labels a row can be
+-- rated with, their weight as control effectiveness for residual risk (0 to 1),
+-- whether they count as applicable and the SoA implementation status. While
+-- the table is empty the backend uses the built-in scale (Fully / Partially /
+-- Not Compliant, Not Applicable).
+CREATE TABLE IF NOT EXISTS compliance_scale (
+    id SERIAL PRIMARY KEY,
+    label VARCHAR(50) NOT NULL,
+    weight DOUBLE PRECISION NOT NULL,
+    applicable BOOLEAN NOT NULL DEFAULT TRUE,
+    soa_status VARCHAR(100) NOT NULL,
+    position INTEGER NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_compliance_scale_label ON compliance_scale(LOWER(label));
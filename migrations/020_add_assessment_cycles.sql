@@ -0,0 +1,32 @@
+-- Assessment cycles: each yearly reassessment or audit readiness check is a
+-- cycle with its own gap and maturity assessment rows, so earlier results are
+-- kept. Closed cycles are read-only. previous_id points at the row of the
+-- cycle a row was copied forward from. Existing rows go into an initial cycle.
+CREATE TABLE IF NOT EXISTS assessment_cycles (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    description TEXT,
+    status VARCHAR(20) NOT NULL DEFAULT 'open',
+    copied_from_id INTEGER REFERENCES assessment_cycles(id) ON DELETE SET NULL,
+    closed_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_assessment_cycles_name ON assessment_cycles(LOWER(name));
+
+INSERT INTO assessment_cycles (name, description)
+SELECT 'Initial assessment', 'Assessments recorded before assessment cycles were introduced'
+WHERE NOT EXISTS (SELECT 1 FROM assessment_cycles);
+
+ALTER TABLE gap_assessments ADD COLUMN IF NOT EXISTS cycle_id INTEGER REFERENCES assessment_cycles(id);
+ALTER TABLE gap_assessments ADD COLUMN IF NOT EXISTS previous_id INTEGER REFERENCES gap_assessments(id) ON DELETE SET NULL;
+UPDATE gap_assessments SET cycle_id = (SELECT MIN(id) FROM assessment_cycles) WHERE cycle_id IS NULL;
+ALTER TABLE gap_assessments ALTER COLUMN cycle_id SET NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_gap_assessments_cycle_id ON gap_assessments(cycle_id);
+
+ALTER TABLE maturity_assessments ADD COLUMN IF NOT EXISTS cycle_id INTEGER REFERENCES assessment_cycles(id);
+ALTER TABLE maturity_assessments ADD COLUMN IF NOT EXISTS previous_id INTEGER REFERENCES maturity_assessments(id) ON DELETE SET NULL;
+UPDATE maturity_assessments SET cycle_id = (SELECT MIN(id) FROM assessment_cycles) WHERE cycle_id IS NULL;
+ALTER TABLE maturity_assessments ALTER COLUMN cycle_id SET NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_maturity_assessments_cycle_id ON maturity_assessments(cycle_id);
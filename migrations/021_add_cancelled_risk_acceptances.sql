@@ -0,0 +1,5 @@
+-- Pending acceptance requests are cancelled when a risk's treatment option
+-- changes away from retain
+ALTER TABLE risk_acceptances DROP CONSTRAINT IF EXISTS risk_acceptances_status_check;
+ALTER TABLE risk_acceptances ADD CONSTRAINT risk_acceptances_status_check
+    CHECK (status IN ('pending', 'approved', 'rejected', 'cancelled'));